@@ -0,0 +1,120 @@
+package analyzers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/soypat/saleae"
+)
+
+// Analyzer is implemented by all protocol analyzers in this package so that
+// tools such as exporters, search and command line interfaces can work
+// with any protocol.
+type Analyzer interface {
+	// Channels returns the channel inputs of the analyzer.
+	Channels() []Channel
+	// Settings returns the settings of the analyzer with their current values.
+	Settings() []Setting
+	// Set sets a setting by name. The value is parsed from its text representation
+	// as returned by Settings.
+	Set(name, value string) error
+	// Analyze decodes the digital channels keyed by channel name into frames.
+	Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error)
+}
+
+// Channel is a named channel input of an analyzer.
+type Channel struct {
+	Name     string
+	Optional bool
+}
+
+// Setting is a named analyzer option.
+type Setting struct {
+	Name  string
+	Usage string
+	// Value is the current value of the setting formatted as text.
+	Value string
+}
+
+// Frame is a protocol agnostic decoded frame modelled after Logic 2's
+// high level analyzer frames.
+type Frame struct {
+	Interval
+	// Type of frame, i.e: "data", "address", "transaction".
+	Type string
+	// Data contains the decoded fields of the frame.
+	Data map[string]any
+	// Err is non-nil if the frame was decoded with errors.
+	Err error
+}
+
+// NewInterval returns an interval spanning start to end, in seconds.
+func NewInterval(start, end float64) Interval {
+	return Interval{start: start, end: end}
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]func() Analyzer)
+)
+
+// Register makes an analyzer available by name. It panics if Register
+// is called twice with the same name or if newAnalyzer is nil.
+func Register(name string, newAnalyzer func() Analyzer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if newAnalyzer == nil {
+		panic("analyzers: Register analyzer is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("analyzers: Register called twice for analyzer " + name)
+	}
+	registry[name] = newAnalyzer
+}
+
+// New returns a new analyzer with default settings registered under name.
+func New(name string) (Analyzer, error) {
+	registryMu.RLock()
+	newAnalyzer, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("analyzers: unknown analyzer %q", name)
+	}
+	return newAnalyzer(), nil
+}
+
+// Names returns a sorted list of the names of the registered analyzers.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inputs returns the digital files for the analyzer's channels in the order
+// returned by Channels. Missing optional channels are nil.
+func inputs(a Analyzer, channels map[string]*saleae.DigitalFile) ([]*saleae.DigitalFile, error) {
+	chs := a.Channels()
+	files := make([]*saleae.DigitalFile, len(chs))
+	for i, ch := range chs {
+		files[i] = channels[ch.Name]
+		if files[i] == nil && !ch.Optional {
+			return nil, fmt.Errorf("analyzers: missing required channel %q", ch.Name)
+		}
+	}
+	return files, nil
+}
+
+func errUnknownSetting(name string) error {
+	return fmt.Errorf("analyzers: unknown setting %q", name)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,100 @@
+package analyzers
+
+import (
+	"testing"
+
+	"github.com/soypat/saleae"
+)
+
+func TestRegistry(t *testing.T) {
+	for _, name := range Names() {
+		a, err := New(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Settings must round trip through Set.
+		for _, s := range a.Settings() {
+			if err := a.Set(s.Name, s.Value); err != nil {
+				t.Errorf("%s: setting %q to %q: %v", name, s.Name, s.Value, err)
+			}
+		}
+		if err := a.Set("not-a-setting", "1"); err == nil {
+			t.Errorf("%s: expected error for unknown setting", name)
+		}
+		if _, err := a.Analyze(nil); err == nil && len(a.Channels()) > 0 {
+			t.Errorf("%s: expected error for missing channels", name)
+		}
+	}
+	if _, err := New("not-an-analyzer"); err == nil {
+		t.Error("expected error for unknown analyzer")
+	}
+}
+
+func TestAnalyzeUART(t *testing.T) {
+	a, err := New("UART")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Set("baud", "115200"); err != nil {
+		t.Fatal(err)
+	}
+	rx := readDigitalFile(t, "../testdata/digital_uart.bin")
+	frames, err := a.Analyze(map[string]*saleae.DigitalFile{"rx": rx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) < 4 {
+		t.Fatalf("expected at least 4 frames, got %d", len(frames))
+	}
+	var got []byte
+	for _, f := range frames[:4] {
+		got = append(got, byte(f.Data["data"].(uint16)))
+	}
+	if string(got) != "init" {
+		t.Errorf("expected %q, got %q", "init", got)
+	}
+}
+
+func TestFromCapture(t *testing.T) {
+	var c saleae.Capture
+	for i, name := range []string{"spisdi", "spisdo", "spiclk", "spienable"} {
+		c.DigitalFiles = append(c.DigitalFiles, *readDigitalFile(t, "../testdata/digital_"+name+".bin"))
+		c.DigitalChannels = append(c.DigitalChannels, i)
+	}
+	// Channel settings match the SPI analyzer saved by Logic 2 in testdata/sx1278_pico.sal,
+	// whose digital files are in a version ReadCapture does not support. An analyzer
+	// with no counterpart in this package is added to check it is skipped.
+	c.Analyzers = []saleae.AnalyzerConfig{
+		{Name: "Async Serial", Type: "Async Serial"},
+		{Name: "SPI", Type: "SPI", Settings: []saleae.AnalyzerSetting{
+			{Title: "MOSI", Type: "Channel", Value: 1.0},
+			{Title: "MISO", Type: "Channel", Value: 0.0},
+			{Title: "Clock", Type: "Channel", Value: 2.0},
+			{Title: "Enable", Type: "Channel", Value: 3.0},
+			{Title: "Significant Bit", Type: "NumberList", Value: 0.0},
+			{Title: "Bits per Transfer", Type: "NumberList", Value: 8.0},
+		}},
+	}
+	cas, err := FromCapture(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cas) != 1 || cas[0].Name != "SPI" {
+		t.Fatalf("expected only SPI analyzer, got %+v", cas)
+	}
+	frames, err := cas[0].Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spi SPI
+	txs, _ := spi.Scan(c.Digital(2), c.Digital(3), c.Digital(1), c.Digital(0))
+	if len(frames) != len(txs) || len(frames) == 0 {
+		t.Fatalf("expected %d frames, got %d", len(txs), len(frames))
+	}
+
+	c.Analyzers[1].Settings[5].Value = 16.0
+	_, err = FromCapture(&c)
+	if err == nil {
+		t.Error("expected error for unsupported bits per transfer")
+	}
+}
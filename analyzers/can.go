@@ -0,0 +1,452 @@
+package analyzers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/soypat/saleae"
+)
+
+var (
+	errCANStuff      = errors.New("can: bit stuffing violation")
+	errCANCRC        = errors.New("can: CRC mismatch")
+	errCANForm       = errors.New("can: fixed form bit not recessive")
+	errCANStuffCount = errors.New("can: stuff count mismatch")
+	errCANIncomplete = errors.New("can: capture ends mid frame")
+)
+
+// canFDLengths maps CAN FD DLC values to data lengths.
+var canFDLengths = [16]uint8{0, 1, 2, 3, 4, 5, 6, 7, 8, 12, 16, 20, 24, 32, 48, 64}
+
+// canIdleBits is the number of recessive bits that must precede a start of frame.
+// It is the ACK delimiter, end of frame and intermission minus one bit of tolerance.
+const canIdleBits = 10
+
+// FrameCAN is a CAN or CAN FD frame.
+type FrameCAN struct {
+	Interval
+	// ID is the 11-bit base or 29-bit extended identifier.
+	ID       uint32
+	Extended bool
+	// Remote is set for classic CAN remote transmission requests.
+	Remote bool
+	// FD is set for CAN FD frames.
+	FD bool
+	// BRS is set if the data phase of a CAN FD frame was sent at the data bit rate.
+	BRS bool
+	// ESI is set if the transmitter of a CAN FD frame was error passive.
+	ESI  bool
+	DLC  uint8
+	Data []byte
+	// CRC is the received CRC sequence. It is 15, 17 or 21 bits long.
+	CRC uint32
+	// ACK is set if a receiver acknowledged the frame.
+	ACK bool
+	// ErrorFrame is set if the frame was interrupted by an error flag.
+	ErrorFrame bool
+	// Err is non-nil if the frame was malformed or a CRC mismatch was found.
+	Err error
+}
+
+// String returns the frame in the format used by the Linux can-utils
+// so that captures can be compared against candump logs.
+func (f FrameCAN) String() string {
+	var s string
+	if f.Extended {
+		s = fmt.Sprintf("%08X#", f.ID)
+	} else {
+		s = fmt.Sprintf("%03X#", f.ID)
+	}
+	switch {
+	case f.Remote:
+		s += "R"
+	case f.FD:
+		flags := 0
+		if f.BRS {
+			flags |= 1
+		}
+		if f.ESI {
+			flags |= 2
+		}
+		s += "#" + strconv.Itoa(flags) + strings.ToUpper(fmt.Sprintf("%x", f.Data))
+	default:
+		s += strings.ToUpper(fmt.Sprintf("%x", f.Data))
+	}
+	return s
+}
+
+// CAN can be used to analyze the CAN_RX or CAN_TX pin of a CAN transceiver
+// for CAN 2.0 and ISO CAN FD frames.
+type CAN struct {
+	// NominalBitrate is the arbitration phase bit rate in bits per second.
+	NominalBitrate float64
+	// DataBitrate is the CAN FD data phase bit rate. Zero uses the nominal bit rate.
+	DataBitrate float64
+	// SamplePoint is the position of the sample point as a fraction of the bit time.
+	// Zero means 0.8.
+	SamplePoint float64
+}
+
+// canReader samples CAN bits resynchronizing on every edge and removes stuff bits.
+type canReader struct {
+	df  *saleae.DigitalFile
+	end float64
+	// t is the start time of the next bit.
+	t, tb, sp float64
+	// last is the time of the last sample.
+	last     float64
+	stuffing bool
+	run      int
+	prev     bool
+	// stuffCount is the number of dynamic stuff bits read.
+	stuffCount int
+	// raw contains the bits including dynamic stuff bits. bits contains destuffed bits.
+	raw, bits []bool
+	err       error
+	// errorFlag is set if a stuffing violation was caused by dominant bits.
+	errorFlag bool
+}
+
+// sample returns the level at the sample point of the next bit on the wire.
+func (r *canReader) sample() bool {
+	if r.err != nil {
+		return true
+	}
+	if e := edgeAfter(r.df, r.last); e < r.t+r.sp*r.tb {
+		r.t = e // Resynchronize to the edge.
+	}
+	ts := r.t + r.sp*r.tb
+	if ts > r.end {
+		r.err = errCANIncomplete
+		return true
+	}
+	r.last = ts
+	r.t += r.tb
+	return levelAt(r.df, ts)
+}
+
+// bit returns the next bit removing stuff bits while stuffing is enabled.
+func (r *canReader) bit() bool {
+	if r.stuffing && r.run == 5 {
+		r.stuffBit()
+	}
+	v := r.sample()
+	if r.err != nil {
+		return true
+	}
+	if r.stuffing {
+		r.raw = append(r.raw, v)
+		if v == r.prev {
+			r.run++
+		} else {
+			r.prev, r.run = v, 1
+		}
+	}
+	r.bits = append(r.bits, v)
+	return v
+}
+
+// stuffBit reads a dynamic stuff bit which must complement the previous bits.
+func (r *canReader) stuffBit() {
+	v := r.sample()
+	if r.err != nil {
+		return
+	}
+	if v == r.prev {
+		r.err = errCANStuff
+		r.errorFlag = !v
+		return
+	}
+	r.raw = append(r.raw, v)
+	r.stuffCount++
+	r.prev, r.run = v, 1
+}
+
+// fixedBit reads a bit outside of the dynamically stuffed fields. If fixedStuff
+// is set the bit must complement the previous bit.
+func (r *canReader) fixedBit(fixedStuff bool) bool {
+	v := r.sample()
+	if r.err != nil {
+		return true
+	}
+	if fixedStuff && v == r.prev {
+		r.err = errCANStuff
+		r.errorFlag = !v
+	}
+	r.prev = v
+	return v
+}
+
+func (r *canReader) bitsN(n int) (v uint32) {
+	for i := 0; i < n; i++ {
+		v = v<<1 | uint32(b2u8(r.bit()))
+	}
+	return v
+}
+
+// switchRate changes the bit rate at the last sample point as done for the
+// CAN FD bit rate switch and CRC delimiter.
+func (r *canReader) switchRate(tb, sp float64) {
+	r.t = r.last + (1-sp)*tb
+	r.tb, r.sp = tb, sp
+}
+
+// canCRC computes the CAN CRC of the given width and polynomial over bits.
+func canCRC(bits []bool, width int, poly, init uint32) uint32 {
+	mask := uint32(1)<<width - 1
+	crc := init
+	for _, b := range bits {
+		next := b != (crc>>(width-1)&1 != 0)
+		crc = crc << 1 & mask
+		if next {
+			crc ^= poly
+		}
+	}
+	return crc
+}
+
+func (a *CAN) timing() (tbN, tbD, sp float64, err error) {
+	if a.NominalBitrate <= 0 {
+		return 0, 0, 0, errors.New("can: nominal bit rate not set")
+	}
+	sp = a.SamplePoint
+	if sp == 0 {
+		sp = 0.8
+	}
+	if sp <= 0 || sp >= 1 {
+		return 0, 0, 0, errors.New("can: sample point must be between 0 and 1")
+	}
+	tbN = 1 / a.NominalBitrate
+	tbD = tbN
+	if a.DataBitrate > 0 {
+		tbD = 1 / a.DataBitrate
+	}
+	return tbN, tbD, sp, nil
+}
+
+// Scan decodes all CAN frames found on rx. Frames start at a falling edge
+// preceded by at least 10 recessive bits.
+func (a *CAN) Scan(rx *saleae.DigitalFile) (frames []FrameCAN, err error) {
+	if rx == nil {
+		return nil, errors.New("can: got nil digital file")
+	}
+	tbN, tbD, sp, err := a.timing()
+	if err != nil {
+		return nil, err
+	}
+	end := rx.Header.End
+	if end <= 0 && len(rx.Data) > 0 {
+		end = rx.Data[len(rx.Data)-1] + 100*tbN
+	}
+	searchFrom := math.Inf(-1)
+	for i, t := range rx.Data {
+		if t < searchFrom || levelAt(rx, t) {
+			continue // Not a falling edge or inside the last frame.
+		}
+		if i > 0 && t-rx.Data[i-1] < canIdleBits*tbN {
+			continue
+		}
+		r := canReader{df: rx, end: end, t: t, tb: tbN, sp: sp, last: t}
+		f := a.decode(&r, tbN, tbD, sp)
+		frames = append(frames, f)
+		searchFrom = f.end
+	}
+	return frames, nil
+}
+
+// decode decodes a frame starting at the start of frame bit at r.t.
+func (a *CAN) decode(r *canReader, tbN, tbD, sp float64) (f FrameCAN) {
+	f.start = r.t
+	defer func() {
+		f.end = r.t
+		if r.err != nil && f.Err == nil {
+			f.Err = r.err
+			f.ErrorFrame = r.errorFlag
+		}
+	}()
+	r.stuffing = true
+	if r.bit() { // Start of frame.
+		r.err = errCANForm
+		return f
+	}
+	f.ID = r.bitsN(11)
+	rtr := r.bit() // RTR, SRR or RRS.
+	if r.bit() {   // IDE.
+		f.Extended = true
+		f.ID = f.ID<<18 | r.bitsN(18)
+		rtr = r.bit()
+	}
+	f.FD = r.bit()
+	if f.FD {
+		r.bit() // res.
+		f.BRS = r.bit()
+		if f.BRS {
+			r.switchRate(tbD, sp)
+		}
+		f.ESI = r.bit()
+	} else {
+		f.Remote = rtr
+		if f.Extended {
+			r.bit() // r0.
+		}
+	}
+	f.DLC = uint8(r.bitsN(4))
+	n := canFDLengths[f.DLC]
+	if !f.FD {
+		if n > 8 {
+			n = 8
+		}
+		if f.Remote {
+			n = 0
+		}
+	}
+	for i := 0; i < int(n) && r.err == nil; i++ {
+		f.Data = append(f.Data, byte(r.bitsN(8)))
+	}
+	if r.err != nil {
+		return f
+	}
+	var crc uint32
+	if f.FD {
+		crc = a.decodeFDCRC(r, &f)
+	} else {
+		crc = canCRC(r.bits, 15, 0x4599, 0)
+		f.CRC = r.bitsN(15)
+		if r.run == 5 {
+			r.stuffBit() // Stuff bit after the CRC sequence.
+		}
+	}
+	r.stuffing = false
+	if r.err != nil {
+		return f
+	}
+	// CRC delimiter.
+	if !r.fixedBit(false) {
+		f.Err = errCANForm
+	}
+	if f.BRS {
+		r.switchRate(tbN, sp)
+	}
+	f.ACK = !r.fixedBit(false)
+	if !r.fixedBit(false) && f.Err == nil { // ACK delimiter.
+		f.Err = errCANForm
+	}
+	for i := 0; i < 7 && r.err == nil; i++ { // End of frame.
+		if !r.fixedBit(false) && f.Err == nil {
+			f.Err = errCANForm
+		}
+	}
+	if f.Err == nil && r.err == nil && crc != f.CRC {
+		f.Err = errCANCRC
+	}
+	return f
+}
+
+// decodeFDCRC reads the stuff count and CRC of a CAN FD frame with their fixed
+// stuff bits and returns the CRC computed over the frame.
+func (a *CAN) decodeFDCRC(r *canReader, f *FrameCAN) uint32 {
+	r.stuffing = false
+	width, poly := 17, uint32(0x1685b)
+	if len(f.Data) > 16 {
+		width, poly = 21, 0x102899
+	}
+	var sc []bool
+	r.fixedBit(true)
+	for i := 0; i < 4; i++ {
+		sc = append(sc, r.fixedBit(false))
+	}
+	var gray, ones uint8
+	for i, b := range sc {
+		if b {
+			ones++
+			if i < 3 {
+				gray |= 1 << (2 - i)
+			}
+		}
+	}
+	count := uint8(r.stuffCount % 8)
+	if r.err == nil && (gray != count^count>>1 || ones%2 != 0) {
+		r.err = errCANStuffCount
+	}
+	for i := 0; i < width; i++ {
+		if i%4 == 0 {
+			r.fixedBit(true)
+		}
+		f.CRC = f.CRC<<1 | uint32(b2u8(r.fixedBit(false)))
+	}
+	return canCRC(append(r.raw, sc...), width, poly, 1<<(width-1))
+}
+
+func init() {
+	Register("CAN", func() Analyzer { return &CAN{NominalBitrate: 500e3} })
+}
+
+// Channels implements Analyzer.
+func (*CAN) Channels() []Channel { return []Channel{{Name: "rx"}} }
+
+// Settings implements Analyzer.
+func (a *CAN) Settings() []Setting {
+	return []Setting{
+		{Name: "bitrate", Usage: "nominal bit rate in bits per second", Value: formatFloat(a.NominalBitrate)},
+		{Name: "databitrate", Usage: "CAN FD data phase bit rate, 0 uses the nominal bit rate", Value: formatFloat(a.DataBitrate)},
+		{Name: "samplepoint", Usage: "sample point as a fraction of the bit time, 0 means 0.8", Value: formatFloat(a.SamplePoint)},
+	}
+}
+
+// Set implements Analyzer.
+func (a *CAN) Set(name, value string) (err error) {
+	switch name {
+	case "bitrate":
+		a.NominalBitrate, err = strconv.ParseFloat(value, 64)
+	case "databitrate":
+		a.DataBitrate, err = strconv.ParseFloat(value, 64)
+	case "samplepoint":
+		a.SamplePoint, err = strconv.ParseFloat(value, 64)
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Each frame is returned as a frame of type "data",
+// "remote" or "error" with the identifier, flags, DLC and data.
+func (a *CAN) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(a, channels)
+	if err != nil {
+		return nil, err
+	}
+	cframes, err := a.Scan(in[0])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(cframes))
+	for i, cf := range cframes {
+		typ := "data"
+		switch {
+		case cf.ErrorFrame:
+			typ = "error"
+		case cf.Remote:
+			typ = "remote"
+		}
+		frames[i] = Frame{
+			Interval: cf.Interval,
+			Type:     typ,
+			Data: map[string]any{
+				"id":       cf.ID,
+				"extended": cf.Extended,
+				"fd":       cf.FD,
+				"brs":      cf.BRS,
+				"esi":      cf.ESI,
+				"dlc":      cf.DLC,
+				"data":     cf.Data,
+				"crc":      cf.CRC,
+				"ack":      cf.ACK,
+			},
+			Err: cf.Err,
+		}
+	}
+	return frames, nil
+}
@@ -0,0 +1,240 @@
+package analyzers
+
+import (
+	"testing"
+
+	"github.com/soypat/saleae"
+)
+
+func TestCANCRC(t *testing.T) {
+	var bits []bool
+	for _, b := range []byte("123456789") {
+		for i := 7; i >= 0; i-- {
+			bits = append(bits, b&(1<<i) != 0)
+		}
+	}
+	for _, test := range []struct {
+		width int
+		poly  uint32
+		check uint32
+	}{
+		{width: 15, poly: 0x4599, check: 0x059e},
+		{width: 17, poly: 0x1685b, check: 0x04f03},
+		{width: 21, poly: 0x102899, check: 0x0ed841},
+	} {
+		if got := canCRC(bits, test.width, test.poly, 0); got != test.check {
+			t.Errorf("CRC%d: got %#x, want %#x", test.width, got, test.check)
+		}
+	}
+}
+
+// canWave builds a CAN bus waveform for tests. Recessive is high.
+type canWave struct {
+	t     float64
+	level bool
+	df    saleae.DigitalFile
+}
+
+func (w *canWave) bit(v bool, d float64) {
+	if v != w.level {
+		w.df.Data = append(w.df.Data, w.t)
+		w.level = v
+	}
+	w.t += d
+}
+
+func appendBits(bits []bool, v uint32, n int) []bool {
+	for i := n - 1; i >= 0; i-- {
+		bits = append(bits, v&(1<<i) != 0)
+	}
+	return bits
+}
+
+// frame appends f to the waveform. Bit times are given for the nominal and data phase.
+// If errorAfter is positive the frame is cut after that many bits by an error flag.
+func (w *canWave) frame(f FrameCAN, tbN, tbD, sp float64, errorAfter int) {
+	for i := 0; i < 11; i++ {
+		w.bit(true, tbN)
+	}
+	bits := []bool{false}
+	if f.Extended {
+		bits = appendBits(bits, f.ID>>18, 11)
+		bits = append(bits, true, true)
+		bits = appendBits(bits, f.ID, 18)
+		bits = append(bits, f.Remote)
+		if !f.FD {
+			bits = append(bits, false)
+		}
+	} else {
+		bits = appendBits(bits, f.ID, 11)
+		bits = append(bits, f.Remote, false)
+	}
+	brsIdx := -1
+	if f.FD {
+		bits = append(bits, true, false, f.BRS)
+		brsIdx = len(bits) - 1
+		bits = append(bits, f.ESI)
+	} else {
+		bits = append(bits, false)
+	}
+	bits = appendBits(bits, uint32(f.DLC), 4)
+	for _, b := range f.Data {
+		bits = appendBits(bits, uint32(b), 8)
+	}
+	if !f.FD {
+		bits = appendBits(bits, canCRC(bits, 15, 0x4599, 0)^f.CRC, 15)
+	}
+	// Dynamic bit stuffing.
+	var raw []bool
+	stuffed := 0
+	run, prev := 0, true
+	brsRaw := -1
+	for i, b := range bits {
+		raw = append(raw, b)
+		if i == brsIdx {
+			brsRaw = len(raw) - 1
+		}
+		if b == prev {
+			run++
+		} else {
+			prev, run = b, 1
+		}
+		if run == 5 && (i < len(bits)-1 || !f.FD) {
+			prev, run = !b, 1
+			raw = append(raw, prev)
+			stuffed++
+		}
+	}
+	dataPhase := false
+	emit := func(v bool) {
+		tb := tbN
+		if dataPhase {
+			tb = tbD
+		}
+		w.bit(v, tb)
+	}
+	for i, b := range raw {
+		if errorAfter > 0 && i == errorAfter {
+			for j := 0; j < 6; j++ {
+				w.bit(false, tbN)
+			}
+			for j := 0; j < 11; j++ {
+				w.bit(true, tbN)
+			}
+			return
+		}
+		if i == brsRaw && f.BRS {
+			w.bit(b, sp*tbN+(1-sp)*tbD)
+			dataPhase = true
+			continue
+		}
+		emit(b)
+	}
+	if f.FD {
+		width, poly := 17, uint32(0x1685b)
+		if len(f.Data) > 16 {
+			width, poly = 21, 0x102899
+		}
+		n := uint32(stuffed % 8)
+		gray := n ^ n>>1
+		sc := appendBits(nil, gray, 3)
+		parity := (gray&1 + gray>>1&1 + gray>>2&1) % 2
+		sc = append(sc, parity == 1)
+		crc := canCRC(append(raw, sc...), width, poly, 1<<(width-1)) ^ f.CRC
+		prev := raw[len(raw)-1]
+		fsb := func() {
+			prev = !prev
+			emit(prev)
+		}
+		fsb()
+		for _, b := range sc {
+			emit(b)
+			prev = b
+		}
+		for i, b := range appendBits(nil, crc, width) {
+			if i%4 == 0 {
+				fsb()
+			}
+			emit(b)
+			prev = b
+		}
+	}
+	// CRC delimiter, switching back to the nominal rate at its sample point.
+	if f.BRS {
+		w.bit(true, sp*tbD+(1-sp)*tbN)
+	} else {
+		w.bit(true, tbN)
+	}
+	w.bit(!f.ACK, tbN)
+	for i := 0; i < 8; i++ {
+		w.bit(true, tbN)
+	}
+}
+
+func TestCAN(t *testing.T) {
+	const (
+		bitrate  = 500e3
+		datarate = 2e6
+		sp       = 0.8
+	)
+	// Transmitter clock is 1% slow to exercise resynchronization.
+	tbN, tbD := 1.01/bitrate, 1.01/datarate
+	data20 := make([]byte, 20)
+	for i := range data20 {
+		data20[i] = byte(i)
+	}
+	frames := []FrameCAN{
+		{ID: 0x123, DLC: 4, Data: []byte{0xde, 0xad, 0xbe, 0xef}, ACK: true},
+		{ID: 0x1abcdef0, Extended: true, Remote: true, DLC: 2, ACK: true},
+		{ID: 0x7ff, FD: true, BRS: true, DLC: 11, Data: data20, ACK: true},
+		{ID: 0x18daf110, Extended: true, FD: true, ESI: true, DLC: 8, Data: make([]byte, 8), ACK: true},
+		{ID: 0x100, DLC: 1, Data: []byte{0x00}},
+		{ID: 0x42, DLC: 1, Data: []byte{0x55}, CRC: 1, ACK: true}, // Corrupted CRC.
+		{ID: 0x42, DLC: 8, Data: make([]byte, 8), ACK: true},      // Interrupted by error flag.
+	}
+	want := []struct {
+		s   string
+		err error
+	}{
+		{s: "123#DEADBEEF"},
+		{s: "1ABCDEF0#R"},
+		{s: "7FF##1000102030405060708090A0B0C0D0E0F10111213"},
+		{s: "18DAF110##20000000000000000"},
+		{s: "100#00"},
+		{s: "042#55", err: errCANCRC},
+		{err: errCANStuff},
+	}
+	var w canWave
+	w.level = true
+	w.df.Header.InitialState = 1
+	for i, f := range frames {
+		errorAfter := 0
+		if i == len(frames)-1 {
+			errorAfter = 20
+		}
+		w.frame(f, tbN, tbD, sp, errorAfter)
+	}
+	w.df.Header.End = w.t
+	can := CAN{NominalBitrate: bitrate, DataBitrate: datarate, SamplePoint: sp}
+	got, err := can.Scan(&w.df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d frames, want %d: %v", len(got), len(want), got)
+	}
+	for i, f := range got {
+		if f.Err != want[i].err {
+			t.Errorf("frame %d: got error %v, want %v", i, f.Err, want[i].err)
+		}
+		if want[i].s != "" && f.String() != want[i].s {
+			t.Errorf("frame %d: got %q, want %q", i, f.String(), want[i].s)
+		}
+		if f.ACK != frames[i].ACK && want[i].err == nil {
+			t.Errorf("frame %d: got ACK %v", i, f.ACK)
+		}
+	}
+	if !got[len(got)-1].ErrorFrame {
+		t.Error("expected error frame")
+	}
+}
@@ -0,0 +1,114 @@
+package analyzers
+
+import (
+	"fmt"
+
+	"github.com/soypat/saleae"
+)
+
+// CaptureAnalyzer is an analyzer configured from a protocol analyzer saved
+// in a capture by the Logic 2 software.
+type CaptureAnalyzer struct {
+	// Name given to the analyzer in the Logic 2 software.
+	Name     string
+	Analyzer Analyzer
+	// Channels contains the capture's digital channels keyed by analyzer channel name.
+	Channels map[string]*saleae.DigitalFile
+}
+
+// Analyze runs the analyzer over the capture's channels.
+func (ca *CaptureAnalyzer) Analyze() ([]Frame, error) {
+	return ca.Analyzer.Analyze(ca.Channels)
+}
+
+// logicAnalyzer describes how to configure an analyzer from a Logic 2 analyzer of the same protocol.
+type logicAnalyzer struct {
+	name string
+	// channels maps Logic 2 channel setting titles to analyzer channel names.
+	channels map[string]string
+	// configure applies the non-channel settings of the Logic 2 analyzer.
+	configure func(a Analyzer, cfg *saleae.AnalyzerConfig) error
+}
+
+// logicAnalyzers maps Logic 2 analyzer types to analyzers in this package.
+var logicAnalyzers = map[string]logicAnalyzer{
+	"SPI": {
+		name:      "SPI",
+		channels:  map[string]string{"MOSI": "mosi", "MISO": "miso", "Clock": "clock", "Enable": "enable"},
+		configure: configureSPI,
+	},
+	"I2C": {
+		name:     "I2C",
+		channels: map[string]string{"SDA": "sda", "SCL": "scl"},
+	},
+}
+
+// FromCapture returns the analyzers configured in the Logic 2 software for the capture
+// with their channels wired to the capture's digital channels. Logic 2 analyzers
+// with no counterpart in this package are skipped.
+func FromCapture(c *saleae.Capture) ([]CaptureAnalyzer, error) {
+	var cas []CaptureAnalyzer
+	for i := range c.Analyzers {
+		cfg := &c.Analyzers[i]
+		la, ok := logicAnalyzers[cfg.Type]
+		if !ok {
+			continue
+		}
+		a, err := New(la.name)
+		if err != nil {
+			return nil, err
+		}
+		ca := CaptureAnalyzer{
+			Name:     cfg.Name,
+			Analyzer: a,
+			Channels: make(map[string]*saleae.DigitalFile),
+		}
+		for _, setting := range cfg.Settings {
+			chName, ok := la.channels[setting.Title]
+			if !ok || setting.Disabled {
+				continue
+			}
+			channel, ok := setting.Int()
+			if !ok || channel < 0 {
+				continue // Channel not set.
+			}
+			df := c.Digital(channel)
+			if df == nil {
+				return nil, fmt.Errorf("analyzer %q: channel %d for %s not found in capture", cfg.Name, channel, setting.Title)
+			}
+			ca.Channels[chName] = df
+		}
+		if la.configure != nil {
+			err = la.configure(a, cfg)
+			if err != nil {
+				return nil, fmt.Errorf("analyzer %q: %w", cfg.Name, err)
+			}
+		}
+		cas = append(cas, ca)
+	}
+	return cas, nil
+}
+
+// configureSPI checks the Logic 2 SPI analyzer settings are supported by SPI.
+func configureSPI(a Analyzer, cfg *saleae.AnalyzerConfig) error {
+	for _, check := range []struct {
+		title  string
+		expect int
+	}{
+		{title: "Significant Bit", expect: 0},   // MSB first.
+		{title: "Bits per Transfer", expect: 8}, // 8 bits.
+		{title: "Clock State", expect: 0},       // CPOL=0.
+		{title: "Clock Phase", expect: 0},       // CPHA=0.
+		{title: "Enable Line", expect: 0},       // Active low.
+	} {
+		setting, ok := cfg.Setting(check.title)
+		if !ok {
+			continue
+		}
+		v, ok := setting.Int()
+		if ok && v != check.expect {
+			return fmt.Errorf("unsupported SPI setting %q value %d, only mode 0 MSB first 8 bit transfers with active low enable are supported", check.title, v)
+		}
+	}
+	return nil
+}
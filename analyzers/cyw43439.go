@@ -0,0 +1,378 @@
+package analyzers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/soypat/saleae"
+)
+
+// Function is a CYW43439 bus function number.
+type Function uint32
+
+const (
+	// All SPI-specific registers.
+	FuncBus Function = 0b00
+	// Registers and memories belonging to other blocks in the chip (64 bytes max).
+	FuncBackplane Function = 0b01
+	// DMA channel 1. WLAN packets up to 2048 bytes.
+	FuncDMA1 Function = 0b10
+	FuncWLAN          = FuncDMA1
+	// DMA channel 2 (optional). Packets up to 2048 bytes.
+	FuncDMA2 Function = 0b11
+)
+
+func (f Function) String() (s string) {
+	switch f {
+	case FuncBus:
+		s = "bus"
+	case FuncBackplane:
+		s = "backplane"
+	case FuncWLAN: // same as FuncDMA1
+		s = "wlan"
+	case FuncDMA2:
+		s = "dma2"
+	default:
+		s = "unknown"
+	}
+	return s
+}
+
+// CmdGSPI is a CYW43439 gSPI command word.
+type CmdGSPI struct {
+	Write   bool
+	AutoInc bool
+	Fn      Function
+	Addr    uint32
+	Size    uint32
+}
+
+// DecodeCmdGSPI decodes a 32-bit gSPI command word.
+func DecodeCmdGSPI(command uint32) (cmd CmdGSPI) {
+	cmd.Write = command&(1<<31) != 0
+	cmd.AutoInc = command&(1<<30) != 0
+	cmd.Fn = Function(command>>28) & 0b11
+	cmd.Addr = (command >> 11) & 0x1ffff
+	cmd.Size = command & ((1 << 11) - 1)
+	return cmd
+}
+
+func (cmd CmdGSPI) String() string {
+	return fmt.Sprintf("addr=%#7x  fn=%9s  sz=%4v write=%5v autoinc=%5v",
+		cmd.Addr, cmd.Fn.String(), cmd.Size, cmd.Write, cmd.AutoInc)
+}
+
+// length returns the number of bytes transferred in the data phase.
+// A size of zero means 2048 bytes for the DMA functions.
+func (cmd CmdGSPI) length() int {
+	if cmd.Size == 0 && cmd.Fn >= FuncDMA1 {
+		return 2048
+	}
+	return int(cmd.Size)
+}
+
+// StatusGSPI is the gSPI status word the CYW43439 appends to transactions when enabled.
+type StatusGSPI uint32
+
+// Status word bits.
+const (
+	StatusDataUnavailable StatusGSPI = 1 << 0
+	StatusUnderflow       StatusGSPI = 1 << 1
+	StatusOverflow        StatusGSPI = 1 << 2
+	StatusF2Interrupt     StatusGSPI = 1 << 3
+	StatusF3Interrupt     StatusGSPI = 1 << 4
+	StatusF2RxReady       StatusGSPI = 1 << 5
+	StatusF3RxReady       StatusGSPI = 1 << 6
+	StatusF2PktAvailable  StatusGSPI = 1 << 8
+	StatusF3PktAvailable  StatusGSPI = 1 << 20
+)
+
+// F2PacketLength returns the length of the packet available on the WLAN function.
+func (s StatusGSPI) F2PacketLength() int { return int(s>>9) & 0x7ff }
+
+// F3PacketLength returns the length of the packet available on DMA function 2.
+func (s StatusGSPI) F3PacketLength() int { return int(s>>21) & 0x7ff }
+
+func (s StatusGSPI) String() string {
+	var flags []string
+	for _, f := range []struct {
+		bit  StatusGSPI
+		name string
+	}{
+		{StatusDataUnavailable, "data_unavailable"},
+		{StatusUnderflow, "underflow"},
+		{StatusOverflow, "overflow"},
+		{StatusF2Interrupt, "f2_intr"},
+		{StatusF3Interrupt, "f3_intr"},
+		{StatusF2RxReady, "f2_rx_ready"},
+		{StatusF3RxReady, "f3_rx_ready"},
+	} {
+		if s&f.bit != 0 {
+			flags = append(flags, f.name)
+		}
+	}
+	if s&StatusF2PktAvailable != 0 {
+		flags = append(flags, "f2_pkt_len="+strconv.Itoa(s.F2PacketLength()))
+	}
+	if s&StatusF3PktAvailable != 0 {
+		flags = append(flags, "f3_pkt_len="+strconv.Itoa(s.F3PacketLength()))
+	}
+	return "status{" + strings.Join(flags, ",") + "}"
+}
+
+// SPI bus function registers that change how transactions are decoded.
+const (
+	gspiRegBusControl   = 0x0
+	gspiRegStatusEnable = 0x2
+	gspiRegRespDelayF1  = 0x1d // Padding bytes before backplane read data.
+	gspiWordLength32    = 1 << 0
+	gspiStatusEnable    = 1 << 0
+)
+
+// Backplane function registers which select the 32KB backplane window.
+const (
+	gspiRegBackplaneAddrLow  = 0x1000a
+	gspiRegBackplaneAddrMid  = 0x1000b
+	gspiRegBackplaneAddrHigh = 0x1000c
+	gspiBackplaneWindowMask  = 0x7fff
+)
+
+var errGSPIShort = errors.New("gspi: transaction shorter than command size")
+
+// TxGSPI is a decoded CYW43439 gSPI transaction.
+type TxGSPI struct {
+	Interval
+	Cmd CmdGSPI
+	// Data written to or read from the device. Backplane read padding,
+	// which is included in Cmd.Size, is removed.
+	Data []byte
+	// BackplaneAddr is the full 32-bit backplane address accessed
+	// when HasBackplaneAddr is set.
+	BackplaneAddr    uint32
+	HasBackplaneAddr bool
+	// Status is the status word returned by the device when HasStatus is set.
+	Status    StatusGSPI
+	HasStatus bool
+	// Err is non-nil if the transaction could not be fully decoded.
+	Err error
+}
+
+// Value returns the first 4 bytes of data as a little endian integer
+// as is used for register accesses.
+func (tx TxGSPI) Value() uint32 {
+	var buf [4]byte
+	copy(buf[:], tx.Data)
+	return binary.LittleEndian.Uint32(buf[:])
+}
+
+// GSPI decodes CYW43439 gSPI transactions on top of the SPI analyzer. The fields
+// describe the bus configuration at the start of the capture, which is then tracked
+// as the host writes to the SPI bus control registers and backplane window registers.
+// The zero value matches the CYW43439 state after reset.
+type GSPI struct {
+	SPI SPI
+	// SharedDataLine is set when SDO and SDI are the same line, as is the case for the
+	// half-duplex bus on the Raspberry Pi Pico W. Data read from the device is then
+	// decoded from SDO.
+	SharedDataLine bool
+	// WordLength32 is set if the bus is in 32-bit word mode. After reset
+	// the CYW43439 is in 16-bit word mode which swaps the bytes of each 16-bit word.
+	WordLength32 bool
+	// BackplaneReadDelay is the number of padding bytes preceding data in backplane reads
+	// as set in the SPI_RESP_DELAY_F1 bus register.
+	BackplaneReadDelay int
+	// StatusEnabled is set if the device appends a status word to transactions.
+	StatusEnabled bool
+	// BackplaneWindow is the base address of the backplane window.
+	BackplaneWindow uint32
+}
+
+// Scan decodes all gSPI transactions found on the SPI signals.
+func (g *GSPI) Scan(clock, enable, sdo, sdi *saleae.DigitalFile) ([]TxGSPI, error) {
+	txs, err := g.SPI.Scan(clock, enable, sdo, sdi)
+	if err != nil {
+		return nil, err
+	}
+	return g.Decode(txs)
+}
+
+// Decode decodes SPI transactions into gSPI transactions. The bus configuration
+// starts from g and is tracked as writes to configuration registers are decoded.
+// g itself is not modified, so decoding the same capture again gives the same result.
+func (g *GSPI) Decode(txs []TxSPI) (gtxs []TxGSPI, err error) {
+	st := *g // Bus state during the capture.
+	for _, tx := range txs {
+		if len(tx.SDO) < 4 {
+			continue // Too short to be a command.
+		}
+		gtx := TxGSPI{Interval: NewInterval(tx.StartTime(), tx.EndTime())}
+		out := st.normalize(tx.SDO)
+		in := out
+		if !st.SharedDataLine {
+			in = st.normalize(tx.SDI)
+		}
+		gtx.Cmd = DecodeCmdGSPI(binary.LittleEndian.Uint32(out))
+		n := gtx.Cmd.length()
+		var data []byte
+		off := 4
+		if gtx.Cmd.Write {
+			data = out[off:]
+		} else {
+			if gtx.Cmd.Fn == FuncBackplane {
+				// The command size includes the padding bytes.
+				off += st.BackplaneReadDelay
+				n -= st.BackplaneReadDelay
+				if n < 0 {
+					n = 0
+				}
+			}
+			if off < len(in) {
+				data = in[off:]
+			}
+		}
+		if len(data) < n {
+			gtx.Err = errGSPIShort
+			n = len(data)
+		}
+		gtx.Data = data[:n:n]
+		if st.StatusEnabled {
+			// Status follows the data phase which is padded to a word boundary.
+			off += st.wordAlign(n)
+			if off+4 <= len(in) {
+				gtx.HasStatus = true
+				gtx.Status = StatusGSPI(binary.LittleEndian.Uint32(in[off:]))
+			}
+		}
+		if gtx.Cmd.Fn == FuncBackplane && gtx.Cmd.Addr < 0x10000 {
+			gtx.HasBackplaneAddr = true
+			gtx.BackplaneAddr = st.BackplaneWindow&^gspiBackplaneWindowMask | gtx.Cmd.Addr&gspiBackplaneWindowMask
+		}
+		if gtx.Cmd.Write {
+			st.track(gtx.Cmd, gtx.Data)
+		}
+		gtxs = append(gtxs, gtx)
+	}
+	return gtxs, nil
+}
+
+// normalize returns the transaction bytes in little endian 32-bit word order.
+func (g *GSPI) normalize(b []byte) []byte {
+	if g.WordLength32 {
+		return b
+	}
+	swapped := make([]byte, len(b))
+	copy(swapped, b)
+	for i := 0; i+1 < len(swapped); i += 2 {
+		swapped[i], swapped[i+1] = swapped[i+1], swapped[i]
+	}
+	return swapped
+}
+
+func (g *GSPI) wordAlign(n int) int {
+	word := 2
+	if g.WordLength32 {
+		word = 4
+	}
+	return (n + word - 1) / word * word
+}
+
+// track updates the bus configuration with data written to the device.
+func (g *GSPI) track(cmd CmdGSPI, data []byte) {
+	for i, b := range data {
+		addr := cmd.Addr
+		if cmd.AutoInc {
+			addr += uint32(i)
+		}
+		switch {
+		case cmd.Fn == FuncBus && addr == gspiRegBusControl:
+			g.WordLength32 = b&gspiWordLength32 != 0
+		case cmd.Fn == FuncBus && addr == gspiRegRespDelayF1:
+			g.BackplaneReadDelay = int(b)
+		case cmd.Fn == FuncBus && addr == gspiRegStatusEnable:
+			g.StatusEnabled = b&gspiStatusEnable != 0
+		case cmd.Fn == FuncBackplane && addr == gspiRegBackplaneAddrLow:
+			g.BackplaneWindow = g.BackplaneWindow&^0xff00 | uint32(b)<<8
+		case cmd.Fn == FuncBackplane && addr == gspiRegBackplaneAddrMid:
+			g.BackplaneWindow = g.BackplaneWindow&^0xff0000 | uint32(b)<<16
+		case cmd.Fn == FuncBackplane && addr == gspiRegBackplaneAddrHigh:
+			g.BackplaneWindow = g.BackplaneWindow&^0xff000000 | uint32(b)<<24
+		}
+	}
+}
+
+func init() {
+	Register("gSPI", func() Analyzer { return &GSPI{} })
+}
+
+// Channels implements Analyzer.
+func (g *GSPI) Channels() []Channel {
+	return g.SPI.Channels()
+}
+
+// Settings implements Analyzer.
+func (g *GSPI) Settings() []Setting {
+	return []Setting{
+		{Name: "shared", Usage: "SDO and SDI are the same half-duplex line", Value: strconv.FormatBool(g.SharedDataLine)},
+		{Name: "word32", Usage: "bus starts in 32-bit word mode", Value: strconv.FormatBool(g.WordLength32)},
+		{Name: "delay", Usage: "backplane read padding bytes at start", Value: strconv.Itoa(g.BackplaneReadDelay)},
+		{Name: "status", Usage: "status word enabled at start", Value: strconv.FormatBool(g.StatusEnabled)},
+		{Name: "window", Usage: "backplane window base address at start", Value: "0x" + strconv.FormatUint(uint64(g.BackplaneWindow), 16)},
+	}
+}
+
+// Set implements Analyzer.
+func (g *GSPI) Set(name, value string) (err error) {
+	switch name {
+	case "shared":
+		g.SharedDataLine, err = strconv.ParseBool(value)
+	case "word32":
+		g.WordLength32, err = strconv.ParseBool(value)
+	case "delay":
+		g.BackplaneReadDelay, err = strconv.Atoi(value)
+	case "status":
+		g.StatusEnabled, err = strconv.ParseBool(value)
+	case "window":
+		var v uint64
+		v, err = strconv.ParseUint(value, 0, 32)
+		g.BackplaneWindow = uint32(v)
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Each transaction is returned as a frame with the
+// decoded command fields and data. Backplane addresses and status words are
+// included when available.
+func (g *GSPI) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(g, channels)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := g.Scan(in[0], in[1], in[2], in[3])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(txs))
+	for i, tx := range txs {
+		data := map[string]any{
+			"write":    tx.Cmd.Write,
+			"autoinc":  tx.Cmd.AutoInc,
+			"function": tx.Cmd.Fn.String(),
+			"address":  tx.Cmd.Addr,
+			"size":     tx.Cmd.Size,
+			"data":     tx.Data,
+		}
+		if tx.HasBackplaneAddr {
+			data["backplane_address"] = tx.BackplaneAddr
+		}
+		if tx.HasStatus {
+			data["status"] = uint32(tx.Status)
+		}
+		frames[i] = Frame{Interval: tx.Interval, Type: "command", Data: data, Err: tx.Err}
+	}
+	return frames, nil
+}
@@ -0,0 +1,145 @@
+package analyzers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestGSPIDecode(t *testing.T) {
+	word := func(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+	cat := func(b ...[]byte) []byte { return bytes.Join(b, nil) }
+	cmd := func(write bool, fn Function, addr, size uint32) []byte {
+		c := uint32(1<<30) | uint32(fn)<<28 | addr<<11 | size
+		if write {
+			c |= 1 << 31
+		}
+		return word(c)
+	}
+	zeros := make([]byte, 16)
+	const status = uint32(StatusF2RxReady | StatusF2PktAvailable | 128<<9)
+	txs := []TxSPI{
+		// Set backplane window to 0x18008000 in a single 3 byte write.
+		{SDO: cat(cmd(true, FuncBackplane, gspiRegBackplaneAddrLow, 3), []byte{0x80, 0x00, 0x18, 0}), SDI: cat(zeros[:8], word(status))},
+		// 4 byte backplane read with 4 bytes of padding.
+		{SDO: cat(cmd(false, FuncBackplane, 0x8804, 8), zeros), SDI: cat(zeros[:8], word(0xdeadbeef), word(status))},
+	}
+	g := GSPI{WordLength32: true, StatusEnabled: true, BackplaneReadDelay: 4}
+	gtxs, err := g.Decode(txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.BackplaneWindow != 0 {
+		t.Fatalf("expected start of capture window to be unchanged, got %#x", g.BackplaneWindow)
+	}
+	again, err := g.Decode(txs)
+	if err != nil || len(again) != 2 || again[1].BackplaneAddr != 0x18008804 {
+		t.Fatalf("second decode differs: %v", err)
+	}
+	read := gtxs[1]
+	if read.Err != nil || read.Value() != 0xdeadbeef || len(read.Data) != 4 {
+		t.Errorf("bad read data % x: %v", read.Data, read.Err)
+	}
+	if !read.HasBackplaneAddr || read.BackplaneAddr != 0x18008804 {
+		t.Errorf("expected backplane address 0x18008804, got %#x", read.BackplaneAddr)
+	}
+	for _, gtx := range gtxs {
+		if !gtx.HasStatus || gtx.Status.F2PacketLength() != 128 || gtx.Status&StatusF2RxReady == 0 {
+			t.Errorf("bad status %v", gtx.Status)
+		}
+	}
+}
+
+func TestGSPIResponseDelay(t *testing.T) {
+	word := func(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+	cat := func(b ...[]byte) []byte { return bytes.Join(b, nil) }
+	cmd := func(write bool, fn Function, addr, size uint32) []byte {
+		c := uint32(1<<30) | uint32(fn)<<28 | addr<<11 | size
+		if write {
+			c |= 1 << 31
+		}
+		return word(c)
+	}
+	zeros := make([]byte, 16)
+	const status = uint32(StatusF2RxReady)
+	txs := []TxSPI{
+		// Bus control with 8 in the general response delay register 0x1.
+		{SDO: cat(cmd(true, FuncBus, gspiRegBusControl, 4), []byte{gspiWordLength32, 8, gspiStatusEnable, 0}), SDI: cat(zeros[:8], word(status))},
+		// Backplane read padding of 4 bytes.
+		{SDO: cat(cmd(true, FuncBus, gspiRegRespDelayF1, 1), []byte{4, 0, 0, 0}), SDI: cat(zeros[:8], word(status))},
+		{SDO: cat(cmd(false, FuncBackplane, 0x10, 8), zeros[:8]), SDI: cat(zeros[:8], word(0xcafe0001), word(status))},
+	}
+	g := GSPI{WordLength32: true, StatusEnabled: true}
+	gtxs, err := g.Decode(txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gtxs) != 3 {
+		t.Fatalf("expected 3 transactions, got %d", len(gtxs))
+	}
+	if read := gtxs[2]; read.Err != nil || len(read.Data) != 4 || read.Value() != 0xcafe0001 {
+		t.Errorf("expected 4 padding bytes from SPI_RESP_DELAY_F1, got data % x: %v", read.Data, read.Err)
+	}
+}
+
+func TestSDPCMDecode(t *testing.T) {
+	sdpcm := func(seq uint8, ch ChannelSDPCM, payload []byte) []byte {
+		size := uint16(sdpcmHeaderSize + len(payload))
+		hdr := binary.LittleEndian.AppendUint16(nil, size)
+		hdr = binary.LittleEndian.AppendUint16(hdr, ^size)
+		hdr = append(hdr, seq, byte(ch), 0, sdpcmHeaderSize, 0, seq+8, 0, 0)
+		return append(hdr, payload...)
+	}
+	cdc := func(cmd IoctlCmd, flags uint32, status int32, payload []byte) []byte {
+		b := binary.LittleEndian.AppendUint32(nil, uint32(cmd))
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(payload)))
+		b = binary.LittleEndian.AppendUint32(b, flags)
+		b = binary.LittleEndian.AppendUint32(b, uint32(status))
+		return append(b, payload...)
+	}
+	event := func(typ EventType, status uint32) []byte {
+		b := make([]byte, bdcHeaderSize+eventHeaderSize)
+		msg := b[bdcHeaderSize+14+10:]
+		binary.BigEndian.PutUint32(msg[4:], uint32(typ))
+		binary.BigEndian.PutUint32(msg[8:], status)
+		copy(msg[30:], "wl0")
+		return b
+	}
+	const id = 7
+	req := sdpcm(1, SDPCMControl, cdc(IoctlSetVar, id<<16|1<<1, 0, []byte("bus:txglom\x00\x00\x00\x00\x00")))
+	resp := sdpcm(2, SDPCMControl, cdc(IoctlSetVar, id<<16, 0, []byte{0, 0, 0, 0}))
+	evt := sdpcm(3, SDPCMEvent, event(0, 0))
+	f2 := func(write bool, data []byte) TxGSPI {
+		return TxGSPI{Cmd: CmdGSPI{Write: write, Fn: FuncWLAN, Size: uint32(len(data))}, Data: data}
+	}
+	txs := []TxGSPI{
+		f2(true, req[:20]), // Request split across two transfers.
+		{Cmd: CmdGSPI{Fn: FuncBus}},
+		f2(true, req[20:]),
+		f2(false, append(resp, 0, 0, 0, 0)), // Padded transfer.
+		f2(false, evt),
+	}
+	var s SDPCM
+	frames, err := s.Decode(txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 {
+		t.Fatalf("expected 3 frames, got %d: %v", len(frames), frames)
+	}
+	for _, f := range frames {
+		if f.Err != nil {
+			t.Errorf("frame %v", f)
+		}
+	}
+	if frames[0].Iovar != "bus:txglom" || frames[0].CDC.Cmd != IoctlSetVar || !frames[0].CDC.Set() {
+		t.Errorf("bad request %v", frames[0])
+	}
+	if ev := frames[2].Event; ev == nil || ev.Type.String() != "SET_SSID" || ev.IfName != "wl0" {
+		t.Errorf("bad event %v", frames[2])
+	}
+	ioctls := PairIoctls(frames)
+	if len(ioctls) != 1 || !ioctls[0].HasResponse || ioctls[0].ID != id || len(ioctls[0].Request) != 4 {
+		t.Errorf("bad ioctl pairing %v", ioctls)
+	}
+}
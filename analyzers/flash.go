@@ -0,0 +1,365 @@
+package analyzers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/soypat/saleae"
+)
+
+// FlashCmd is a SPI NOR flash command opcode of the JEDEC standard command set
+// implemented by W25Q, MX25 and most other serial flash devices.
+type FlashCmd uint8
+
+// Flash command opcodes.
+const (
+	FlashWriteStatus      FlashCmd = 0x01
+	FlashPageProgram      FlashCmd = 0x02
+	FlashRead             FlashCmd = 0x03
+	FlashWriteDisable     FlashCmd = 0x04
+	FlashReadStatus       FlashCmd = 0x05
+	FlashWriteEnable      FlashCmd = 0x06
+	FlashFastRead         FlashCmd = 0x0b
+	FlashFastRead4B       FlashCmd = 0x0c
+	FlashPageProgram4B    FlashCmd = 0x12
+	FlashRead4B           FlashCmd = 0x13
+	FlashSectorErase      FlashCmd = 0x20
+	FlashSectorErase4B    FlashCmd = 0x21
+	FlashReadStatus2      FlashCmd = 0x35
+	FlashBlockErase32K    FlashCmd = 0x52
+	FlashReadSFDP         FlashCmd = 0x5a
+	FlashChipErase        FlashCmd = 0x60
+	FlashResetEnable      FlashCmd = 0x66
+	FlashReset            FlashCmd = 0x99
+	FlashReadID           FlashCmd = 0x9f
+	FlashReleasePowerDown FlashCmd = 0xab
+	FlashEnter4Byte       FlashCmd = 0xb7
+	FlashPowerDown        FlashCmd = 0xb9
+	FlashChipErase2       FlashCmd = 0xc7
+	FlashBlockErase64K    FlashCmd = 0xd8
+	FlashBlockErase4B     FlashCmd = 0xdc
+	FlashExit4Byte        FlashCmd = 0xe9
+)
+
+// flashCmdInfo describes the framing of a flash command.
+type flashCmdInfo struct {
+	name string
+	// addr is the number of address bytes. -1 means 3 or 4 bytes depending on the address mode.
+	addr int
+	// dummy is the number of dummy bytes between address and data.
+	dummy int
+	// write is set for commands which require the write enable latch to be set.
+	write bool
+	// busy is the maximum time in seconds the flash is busy after a write command,
+	// taken from the W25Q128JV datasheet.
+	busy float64
+}
+
+var flashCmds = map[FlashCmd]flashCmdInfo{
+	FlashWriteStatus:      {name: "WRSR", write: true, busy: 15e-3},
+	FlashPageProgram:      {name: "PAGE_PROGRAM", addr: -1, write: true, busy: 3e-3},
+	FlashRead:             {name: "READ", addr: -1},
+	FlashWriteDisable:     {name: "WRDI"},
+	FlashReadStatus:       {name: "RDSR"},
+	FlashWriteEnable:      {name: "WREN"},
+	FlashFastRead:         {name: "FAST_READ", addr: -1, dummy: 1},
+	FlashFastRead4B:       {name: "FAST_READ4B", addr: 4, dummy: 1},
+	FlashPageProgram4B:    {name: "PAGE_PROGRAM4B", addr: 4, write: true, busy: 3e-3},
+	FlashRead4B:           {name: "READ4B", addr: 4},
+	FlashSectorErase:      {name: "SECTOR_ERASE", addr: -1, write: true, busy: 400e-3},
+	FlashSectorErase4B:    {name: "SECTOR_ERASE4B", addr: 4, write: true, busy: 400e-3},
+	FlashReadStatus2:      {name: "RDSR2"},
+	FlashBlockErase32K:    {name: "BLOCK_ERASE_32K", addr: -1, write: true, busy: 1.6},
+	FlashReadSFDP:         {name: "RDSFDP", addr: 3, dummy: 1},
+	FlashChipErase:        {name: "CHIP_ERASE", write: true, busy: 200},
+	FlashResetEnable:      {name: "RSTEN"},
+	FlashReset:            {name: "RST"},
+	FlashReadID:           {name: "RDID"},
+	FlashReleasePowerDown: {name: "RDP"},
+	FlashEnter4Byte:       {name: "EN4B"},
+	FlashPowerDown:        {name: "DP"},
+	FlashChipErase2:       {name: "CHIP_ERASE", write: true, busy: 200},
+	FlashBlockErase64K:    {name: "BLOCK_ERASE_64K", addr: -1, write: true, busy: 2},
+	FlashBlockErase4B:     {name: "BLOCK_ERASE4B", addr: 4, write: true, busy: 2},
+	FlashExit4Byte:        {name: "EX4B"},
+}
+
+func (c FlashCmd) String() string {
+	info, ok := flashCmds[c]
+	if !ok {
+		return "cmd(" + strconv.FormatUint(uint64(c), 16) + ")"
+	}
+	return info.name
+}
+
+// StatusFlash is the first status register of a SPI NOR flash.
+type StatusFlash uint8
+
+// Status register bits common to most flash devices. The remaining
+// bits hold the block protection bits whose meaning is vendor specific.
+const (
+	FlashStatusWIP  StatusFlash = 1 << 0 // Write in progress.
+	FlashStatusWEL  StatusFlash = 1 << 1 // Write enable latch.
+	FlashStatusSRWD StatusFlash = 1 << 7 // Status register write disable.
+)
+
+// BlockProtect returns the block protection bits 2 to 5 of the status register.
+func (s StatusFlash) BlockProtect() uint8 { return uint8(s>>2) & 0xf }
+
+func (s StatusFlash) String() string {
+	var flags []string
+	if s&FlashStatusWIP != 0 {
+		flags = append(flags, "wip")
+	}
+	if s&FlashStatusWEL != 0 {
+		flags = append(flags, "wel")
+	}
+	if bp := s.BlockProtect(); bp != 0 {
+		flags = append(flags, "bp="+strconv.Itoa(int(bp)))
+	}
+	if s&FlashStatusSRWD != 0 {
+		flags = append(flags, "srwd")
+	}
+	return "status{" + strings.Join(flags, ",") + "}"
+}
+
+var (
+	errFlashShort       = errors.New("flash: transaction too short for command")
+	errFlashNoWREN      = errors.New("flash: write command without preceding WREN")
+	errFlashBusy        = errors.New("flash: command issued while write in progress")
+	errFlashPageWrap    = errors.New("flash: page program wraps around page boundary")
+	errFlashUnknown     = errors.New("flash: unknown command")
+	errFlashProgramData = errors.New("flash: page program without data")
+)
+
+// TxFlash is a decoded SPI NOR flash command.
+type TxFlash struct {
+	Interval
+	Cmd FlashCmd
+	// Addr is the address of read, program and erase commands.
+	Addr    uint32
+	HasAddr bool
+	// Data is the data read or written after the address and dummy bytes.
+	Data []byte
+	// Status is the status register value read by RDSR or written by WRSR.
+	Status    StatusFlash
+	HasStatus bool
+	// Err is non-nil if the command was malformed or issued out of sequence.
+	Err error
+}
+
+func (tx TxFlash) String() string {
+	s := tx.Cmd.String()
+	if tx.HasAddr {
+		s += fmt.Sprintf(" addr=%#x", tx.Addr)
+	}
+	switch {
+	case tx.HasStatus:
+		s += " " + tx.Status.String()
+	case tx.Cmd == FlashReadID && len(tx.Data) >= 3:
+		s += fmt.Sprintf(" manufacturer=%#02x device=%#04x", tx.Data[0], uint16(tx.Data[1])<<8|uint16(tx.Data[2]))
+	case len(tx.Data) > 0:
+		s += " len=" + strconv.Itoa(len(tx.Data))
+	}
+	if tx.Err != nil {
+		s += " err=" + tx.Err.Error()
+	}
+	return s
+}
+
+// Flash decodes SPI NOR flash commands. The write enable latch, busy state and
+// address mode are tracked across commands to flag protocol mistakes. The flash
+// is busy after a program or erase until a status read shows the cycle completed
+// or the maximum time for the command has passed. Commands issued while busy are
+// flagged and ignored.
+type Flash struct {
+	SPI SPI
+	// Addr4Byte is set if the flash is in 4-byte address mode at the start of the capture.
+	Addr4Byte bool
+	// PageSize is the page program page size. Zero means 256 bytes.
+	PageSize int
+}
+
+// Scan decodes all flash commands found on the SPI signals.
+func (f *Flash) Scan(clock, enable, sdo, sdi *saleae.DigitalFile) ([]TxFlash, error) {
+	txs, err := f.SPI.Scan(clock, enable, sdo, sdi)
+	if err != nil {
+		return nil, err
+	}
+	return f.Decode(txs)
+}
+
+// Decode decodes SPI transactions into flash commands. Each transaction
+// is expected to hold a single command. f is not modified by decoding.
+func (f *Flash) Decode(txs []TxSPI) ([]TxFlash, error) {
+	pageSize := f.PageSize
+	if pageSize == 0 {
+		pageSize = 256
+	}
+	var (
+		addr4   = f.Addr4Byte
+		wel     bool    // Write enable latch.
+		busy    bool    // Program or erase in progress.
+		busyEnd float64 // Time at which the program or erase must have completed.
+	)
+	var ftxs []TxFlash
+	for _, tx := range txs {
+		if len(tx.SDO) == 0 || len(tx.SDI) != len(tx.SDO) {
+			continue
+		}
+		ftx := TxFlash{
+			Interval: NewInterval(tx.StartTime(), tx.EndTime()),
+			Cmd:      FlashCmd(tx.SDO[0]),
+		}
+		info, ok := flashCmds[ftx.Cmd]
+		if !ok {
+			ftx.Err = errFlashUnknown
+			ftxs = append(ftxs, ftx)
+			continue
+		}
+		addrLen := info.addr
+		if addrLen < 0 {
+			addrLen = 3
+			if addr4 {
+				addrLen = 4
+			}
+		}
+		n := 1 + addrLen + info.dummy
+		if len(tx.SDO) < n {
+			ftx.Err = errFlashShort
+			ftxs = append(ftxs, ftx)
+			continue
+		}
+		if addrLen > 0 {
+			ftx.HasAddr = true
+			for _, b := range tx.SDO[1 : 1+addrLen] {
+				ftx.Addr = ftx.Addr<<8 | uint32(b)
+			}
+		}
+		switch ftx.Cmd {
+		case FlashRead, FlashFastRead, FlashRead4B, FlashFastRead4B, FlashReadSFDP,
+			FlashReadStatus, FlashReadStatus2, FlashReadID:
+			ftx.Data = tx.SDI[n:]
+		default:
+			ftx.Data = tx.SDO[n:]
+		}
+
+		// Check command sequence before updating state.
+		if busy && ftx.start > busyEnd {
+			busy = false // Cycle completed without the status being polled.
+		}
+		switch {
+		case busy && ftx.Cmd != FlashReadStatus && ftx.Cmd != FlashReadStatus2 &&
+			ftx.Cmd != FlashResetEnable && ftx.Cmd != FlashReset:
+			ftx.Err = errFlashBusy
+			ftxs = append(ftxs, ftx)
+			continue // Ignored by the flash.
+		case info.write && !wel:
+			ftx.Err = errFlashNoWREN
+		}
+		switch ftx.Cmd {
+		case FlashReadStatus:
+			if len(ftx.Data) == 0 {
+				ftx.Err = errFlashShort
+				break
+			}
+			// Status is read continuously while the clock runs, keep the last value.
+			ftx.Status = StatusFlash(ftx.Data[len(ftx.Data)-1])
+			ftx.HasStatus = true
+			busy = ftx.Status&FlashStatusWIP != 0
+			wel = ftx.Status&FlashStatusWEL != 0
+		case FlashWriteStatus:
+			if len(ftx.Data) == 0 {
+				ftx.Err = errFlashShort
+				break
+			}
+			ftx.Status = StatusFlash(ftx.Data[0])
+			ftx.HasStatus = true
+		case FlashWriteEnable:
+			wel = true
+		case FlashWriteDisable:
+			wel = false
+		case FlashEnter4Byte:
+			addr4 = true
+		case FlashExit4Byte:
+			addr4 = false
+		case FlashReset:
+			wel, busy, addr4 = false, false, false
+		case FlashPageProgram, FlashPageProgram4B:
+			if len(ftx.Data) == 0 {
+				ftx.Err = errFlashProgramData
+			} else if int(ftx.Addr)%pageSize+len(ftx.Data) > pageSize && ftx.Err == nil {
+				ftx.Err = errFlashPageWrap
+			}
+		}
+		if info.write {
+			// Writes accepted with the latch set start a program or erase cycle,
+			// which clears the write enable latch when it completes.
+			if wel {
+				busy = true
+				busyEnd = ftx.end + info.busy
+			}
+			wel = false
+		}
+		ftxs = append(ftxs, ftx)
+	}
+	return ftxs, nil
+}
+
+func init() {
+	Register("Flash", func() Analyzer { return &Flash{} })
+}
+
+// Channels implements Analyzer.
+func (f *Flash) Channels() []Channel { return f.SPI.Channels() }
+
+// Settings implements Analyzer.
+func (f *Flash) Settings() []Setting {
+	return []Setting{
+		{Name: "addr4", Usage: "flash is in 4-byte address mode at start", Value: strconv.FormatBool(f.Addr4Byte)},
+		{Name: "page", Usage: "page program page size, 0 means 256", Value: strconv.Itoa(f.PageSize)},
+	}
+}
+
+// Set implements Analyzer.
+func (f *Flash) Set(name, value string) (err error) {
+	switch name {
+	case "addr4":
+		f.Addr4Byte, err = strconv.ParseBool(value)
+	case "page":
+		f.PageSize, err = strconv.Atoi(value)
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Each flash command is returned as a frame of type "command".
+func (f *Flash) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(f, channels)
+	if err != nil {
+		return nil, err
+	}
+	ftxs, err := f.Scan(in[0], in[1], in[2], in[3])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(ftxs))
+	for i, tx := range ftxs {
+		data := map[string]any{
+			"command": tx.Cmd.String(),
+			"opcode":  uint8(tx.Cmd),
+			"data":    tx.Data,
+		}
+		if tx.HasAddr {
+			data["address"] = tx.Addr
+		}
+		if tx.HasStatus {
+			data["status"] = uint8(tx.Status)
+		}
+		frames[i] = Frame{Interval: tx.Interval, Type: "command", Data: data, Err: tx.Err}
+	}
+	return frames, nil
+}
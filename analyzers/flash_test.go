@@ -0,0 +1,102 @@
+package analyzers
+
+import "testing"
+
+func TestFlashDecode(t *testing.T) {
+	tx := func(sdo []byte, sdi ...byte) TxSPI {
+		in := make([]byte, len(sdo))
+		copy(in[len(sdo)-len(sdi):], sdi)
+		return TxSPI{SDO: sdo, SDI: in}
+	}
+	page := make([]byte, 16)
+	txs := []TxSPI{
+		tx([]byte{0x9f, 0, 0, 0}, 0xef, 0x40, 0x18),
+		tx([]byte{0x03, 0x01, 0x02, 0x03, 0, 0}, 0xaa, 0xbb),
+		tx(append([]byte{0x02, 0, 0x10, 0}, page...)), // No WREN.
+		tx([]byte{0x06}),
+		tx([]byte{0x20, 0, 0x10, 0}),
+		tx([]byte{0x05, 0}, 0x03),
+		tx([]byte{0x03, 0, 0, 0, 0}), // Read while busy.
+		tx([]byte{0x05, 0, 0}, 0x03, 0x00),
+		tx([]byte{0xb7}),
+		tx([]byte{0x0b, 0x01, 0x00, 0x00, 0x00, 0xff, 0}, 0x5a),
+		tx([]byte{0x06}),
+		tx(append([]byte{0x02, 0, 0, 0, 0xf8}, page...)), // Wraps page in 4-byte mode.
+		tx([]byte{0x03, 0, 0, 0, 0, 0}),                  // Read while programming.
+	}
+	var f Flash
+	ftxs, err := f.Decode(txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		s   string
+		err error
+	}{
+		{s: "RDID manufacturer=0xef device=0x4018"},
+		{s: "READ addr=0x10203 len=2"},
+		{s: "PAGE_PROGRAM addr=0x1000 len=16 err=" + errFlashNoWREN.Error(), err: errFlashNoWREN},
+		{s: "WREN"},
+		{s: "SECTOR_ERASE addr=0x1000"},
+		{s: "RDSR status{wip,wel}"},
+		{s: "READ addr=0x0 len=1 err=" + errFlashBusy.Error(), err: errFlashBusy},
+		{s: "RDSR status{}"},
+		{s: "EN4B"},
+		{s: "FAST_READ addr=0x1000000 len=1"},
+		{s: "WREN"},
+		{s: "PAGE_PROGRAM addr=0xf8 len=16 err=" + errFlashPageWrap.Error(), err: errFlashPageWrap},
+		{s: "READ addr=0x0 len=1 err=" + errFlashBusy.Error(), err: errFlashBusy},
+	}
+	if len(ftxs) != len(want) {
+		t.Fatalf("got %d commands, want %d", len(ftxs), len(want))
+	}
+	for i, ftx := range ftxs {
+		if got := ftx.String(); got != want[i].s {
+			t.Errorf("command %d: got %q, want %q", i, got, want[i].s)
+		}
+		if ftx.Err != want[i].err {
+			t.Errorf("command %d: got error %v, want %v", i, ftx.Err, want[i].err)
+		}
+	}
+	// Decoding again must start from the same state and not in 4-byte mode.
+	again, err := f.Decode(txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range again {
+		if again[i].String() != ftxs[i].String() {
+			t.Errorf("command %d: second decode got %q, want %q", i, again[i].String(), ftxs[i].String())
+		}
+	}
+}
+
+func TestFlashBusyTime(t *testing.T) {
+	tx := func(start float64, sdo ...byte) TxSPI {
+		return TxSPI{SDO: sdo, SDI: make([]byte, len(sdo)), timings: []Interval{{start: start, end: start + 10e-6}}}
+	}
+	txs := []TxSPI{
+		tx(0, 0x06),
+		tx(1e-3, 0x02, 0, 0, 0, 0xaa),
+		tx(2e-3, 0x03, 0, 0, 0, 0), // Program may still be in progress.
+		tx(5e-3, 0x03, 0, 0, 0, 0), // Program completed without polling.
+		tx(6e-3, 0x06),
+		tx(7e-3, 0x20, 0, 0x10, 0),
+		tx(8e-3, 0x06), // Ignored while erasing.
+		tx(9e-3, 0x05, 0),
+		tx(10e-3, 0x02, 0, 0, 0, 0xaa),
+	}
+	var f Flash
+	ftxs, err := f.Decode(txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []error{nil, nil, errFlashBusy, nil, nil, nil, errFlashBusy, nil, errFlashNoWREN}
+	if len(ftxs) != len(want) {
+		t.Fatalf("got %d commands, want %d", len(ftxs), len(want))
+	}
+	for i, ftx := range ftxs {
+		if ftx.Err != want[i] {
+			t.Errorf("command %d %v: got error %v, want %v", i, ftx, ftx.Err, want[i])
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package analyzers
+
+import "strings"
+
+// clockWave builds the bit strings of a clock and of data lines sampled on its
+// rising edge, one clock cycle per call to cycle. Data lines change while the
+// clock is low. The strings are turned into digital files with digitalFromBits.
+type clockWave struct {
+	clk   strings.Builder
+	lines []strings.Builder
+}
+
+// cycle adds a clock cycle with the data lines at levels.
+func (w *clockWave) cycle(levels ...bool) {
+	if w.lines == nil {
+		w.lines = make([]strings.Builder, len(levels))
+	} else if len(levels) != len(w.lines) {
+		panic("clockWave: wrong number of line levels")
+	}
+	w.clk.WriteString("01")
+	for i, v := range levels {
+		w.lines[i].WriteString(strings.Repeat(string('0'+b2u8(v)), 2))
+	}
+}
+
+// line returns the bit string of data line i.
+func (w *clockWave) line(i int) string { return w.lines[i].String() }
@@ -0,0 +1,277 @@
+package analyzers
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/soypat/saleae"
+)
+
+var (
+	errI2CMissingStop = errors.New("i2c: missing stop condition")
+	errI2CMisplaced   = errors.New("i2c: SDA changed while SCL high during byte transfer")
+)
+
+// TxI2C is an I2C transaction. It begins with a start or repeated start condition
+// and ends with a stop condition or the next repeated start condition.
+type TxI2C struct {
+	Interval
+	// RepeatedStart is set if the transaction began with a repeated start condition.
+	RepeatedStart bool
+	// Stop is set if the transaction ended with a stop condition.
+	Stop bool
+	// Address of the target. For 10-bit reads following a 10-bit write to the
+	// same target the full address is recovered from the previous transaction.
+	Address uint16
+	TenBit  bool
+	Read    bool
+	// AddressACK is set if the target acknowledged its address.
+	AddressACK bool
+	Data       []byte
+	// ACK[i] is set if Data[i] was acknowledged.
+	ACK []bool
+	// Stretched is set if a target held SCL low for longer than the stretch threshold.
+	Stretched bool
+	// Longest time SCL was held low during the transaction.
+	MaxLowTime float64
+	// Err is non-nil if a bus error was detected during the transaction.
+	Err     error
+	timings []Interval
+}
+
+// ByteInterval returns the interval during which the i'th data byte and its ACK bit were transferred.
+func (t TxI2C) ByteInterval(i int) Interval {
+	return t.timings[i]
+}
+
+// I2C can be used to analyze a pair of digital signals for I2C transactions.
+type I2C struct {
+	// StretchThreshold is the time SCL must be held low during a transaction
+	// for it to be considered clock stretching. Zero value uses three
+	// times the median SCL low time of the capture.
+	StretchThreshold float64
+}
+
+// Scan decodes all I2C transactions found on scl and sda.
+func (a *I2C) Scan(scl, sda *saleae.DigitalFile) (txs []TxI2C, err error) {
+	if scl == nil || sda == nil {
+		return nil, errors.New("i2c: got nil digital file")
+	}
+	threshold := a.StretchThreshold
+	if threshold <= 0 {
+		threshold = 3 * medianLowTime(scl)
+	}
+	var (
+		sclState = scl.Header.InitialState != 0
+		sdaState = sda.Header.InitialState != 0
+		iscl     int
+		isda     int
+
+		inTx      bool
+		tx        TxI2C
+		bits      []bool
+		byteStart float64
+		bitCount  int
+		pending   bool         // SDA level sampled on last SCL rising edge.
+		tLow      = math.NaN() // Time of last SCL falling edge.
+		last10bit uint16       // Last 10-bit address written to.
+	)
+	finish := func(end float64, stop bool) {
+		tx.end = end
+		tx.Stop = stop
+		if bitCount != 0 && tx.Err == nil {
+			tx.Err = errI2CMisplaced
+		}
+		decodeI2CBytes(&tx, bits, &last10bit)
+		txs = append(txs, tx)
+		bits = nil
+		bitCount = 0
+	}
+	for iscl < len(scl.Data) || isda < len(sda.Data) {
+		tscl := math.Inf(1)
+		tsda := math.Inf(1)
+		if iscl < len(scl.Data) {
+			tscl = scl.Data[iscl]
+		}
+		if isda < len(sda.Data) {
+			tsda = sda.Data[isda]
+		}
+		if tsda < tscl {
+			isda++
+			sdaState = !sdaState
+			if !sclState {
+				continue // Regular data change.
+			}
+			if !sdaState {
+				// Start condition.
+				if inTx {
+					finish(tsda, false)
+				}
+				tx = TxI2C{RepeatedStart: inTx}
+				tx.start = tsda
+				byteStart = math.NaN()
+				inTx = true
+			} else if inTx {
+				// Stop condition.
+				finish(tsda, true)
+				inTx = false
+			}
+			continue
+		}
+		iscl++
+		sclState = !sclState
+		if !inTx {
+			continue
+		}
+		if sclState {
+			// SCL rising edge: sample SDA. The bit is committed on the falling
+			// edge since a start or stop condition may follow while SCL is high.
+			if lowTime := tscl - tLow; lowTime > tx.MaxLowTime {
+				tx.MaxLowTime = lowTime
+				tx.Stretched = tx.Stretched || lowTime > threshold
+			}
+			if bitCount == 0 {
+				byteStart = tscl
+			}
+			pending = sdaState
+			continue
+		}
+		tLow = tscl
+		if math.IsNaN(byteStart) {
+			continue // First SCL falling edge after start condition.
+		}
+		bits = append(bits, pending)
+		bitCount++
+		if bitCount == 9 {
+			tx.timings = append(tx.timings, Interval{start: byteStart, end: tscl})
+			bitCount = 0
+			byteStart = math.NaN()
+		}
+	}
+	if inTx {
+		end := math.Max(scl.Header.End, sda.Header.End)
+		finish(end, false)
+		txs[len(txs)-1].Err = errI2CMissingStop
+	}
+	return txs, nil
+}
+
+// decodeI2CBytes decodes the complete 9-bit words in bits into the
+// address and data fields of tx.
+func decodeI2CBytes(tx *TxI2C, bits []bool, last10bit *uint16) {
+	nbytes := len(bits) / 9
+	if nbytes == 0 {
+		tx.timings = nil
+		return
+	}
+	data := make([]byte, nbytes)
+	ack := make([]bool, nbytes)
+	for i := range data {
+		for j := 0; j < 8; j++ {
+			data[i] |= b2u8(bits[i*9+j]) << (7 - j)
+		}
+		ack[i] = !bits[i*9+8] // ACK is SDA low.
+	}
+	first := data[0]
+	tx.Read = first&1 != 0
+	tx.AddressACK = ack[0]
+	tx.Address = uint16(first >> 1)
+	skip := 1
+	if first&0xf8 == 0xf0 {
+		tx.TenBit = true
+		high := uint16(first>>1) & 0b11
+		switch {
+		case !tx.Read && nbytes > 1:
+			tx.Address = high<<8 | uint16(data[1])
+			tx.AddressACK = ack[0] && ack[1]
+			*last10bit = tx.Address
+			skip = 2
+		case tx.Read && *last10bit>>8 == high:
+			tx.Address = *last10bit
+		default:
+			tx.Address = high << 8
+		}
+	}
+	tx.Data = data[skip:]
+	tx.ACK = ack[skip:]
+	tx.timings = tx.timings[skip:]
+}
+
+// medianLowTime returns the median time the signal spends low.
+func medianLowTime(d *saleae.DigitalFile) float64 {
+	var lows []float64
+	i := 0
+	if d.Header.InitialState == 0 {
+		i = 1 // First transition is a rising edge.
+	}
+	for ; i+1 < len(d.Data); i += 2 {
+		lows = append(lows, d.Data[i+1]-d.Data[i])
+	}
+	if len(lows) == 0 {
+		return math.Inf(1)
+	}
+	sort.Float64s(lows)
+	return lows[len(lows)/2]
+}
+
+func init() {
+	Register("I2C", func() Analyzer { return &I2C{} })
+}
+
+// Channels implements Analyzer.
+func (*I2C) Channels() []Channel {
+	return []Channel{{Name: "scl"}, {Name: "sda"}}
+}
+
+// Settings implements Analyzer.
+func (a *I2C) Settings() []Setting {
+	return []Setting{
+		{Name: "stretch", Usage: "SCL low time in seconds considered clock stretching, 0 for automatic", Value: formatFloat(a.StretchThreshold)},
+	}
+}
+
+// Set implements Analyzer.
+func (a *I2C) Set(name, value string) (err error) {
+	switch name {
+	case "stretch":
+		a.StretchThreshold, err = strconv.ParseFloat(value, 64)
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Each transaction is returned as a frame with
+// the "address", "read", "data" and "ack" fields of the transaction.
+func (a *I2C) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(a, channels)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := a.Scan(in[0], in[1])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(txs))
+	for i, tx := range txs {
+		frames[i] = Frame{
+			Interval: tx.Interval,
+			Type:     "transaction",
+			Data: map[string]any{
+				"address":        tx.Address,
+				"tenbit":         tx.TenBit,
+				"read":           tx.Read,
+				"address_ack":    tx.AddressACK,
+				"data":           tx.Data,
+				"ack":            tx.ACK,
+				"repeated_start": tx.RepeatedStart,
+				"stop":           tx.Stop,
+				"stretched":      tx.Stretched,
+			},
+			Err: tx.Err,
+		}
+	}
+	return frames, nil
+}
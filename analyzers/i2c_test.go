@@ -0,0 +1,211 @@
+package analyzers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/soypat/saleae"
+)
+
+func TestI2C(t *testing.T) {
+	var b i2cBuilder
+	// Register read from 7-bit target 0x50 with clock stretching.
+	b.start()
+	b.byte(0x50<<1, true)
+	b.byte(0x10, true)
+	b.stretch(20)
+	b.start()
+	b.byte(0x50<<1|1, true)
+	b.byte(0xca, true)
+	b.byte(0xfe, false)
+	b.stop()
+	// 10-bit write to 0x2a5 followed by a missing stop.
+	b.idle(4)
+	b.start()
+	b.byte(0xf0|0x2<<1, true)
+	b.byte(0xa5, true)
+	b.byte(0x01, false)
+	scl, sda := b.files(2.5e-6) // 100kHz.
+
+	var i2c I2C
+	txs, err := i2c.Scan(scl, sda)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 3 {
+		t.Fatalf("expected 3 transactions, got %d", len(txs))
+	}
+	w, r, tenbit := txs[0], txs[1], txs[2]
+	if w.Address != 0x50 || w.Read || w.Stop || w.RepeatedStart || !w.AddressACK || !bytes.Equal(w.Data, []byte{0x10}) || w.Err != nil {
+		t.Errorf("bad write transaction %+v", w)
+	}
+	if !w.Stretched || r.Stretched {
+		t.Errorf("expected only first transaction to be stretched: %v %v", w.Stretched, r.Stretched)
+	}
+	if r.Address != 0x50 || !r.Read || !r.Stop || !r.RepeatedStart || !bytes.Equal(r.Data, []byte{0xca, 0xfe}) || r.Err != nil {
+		t.Errorf("bad read transaction %+v", r)
+	}
+	if !r.ACK[0] || r.ACK[1] {
+		t.Errorf("expected ACK then NACK, got %v", r.ACK)
+	}
+	if r.ByteInterval(1).StartTime() <= r.ByteInterval(0).EndTime() {
+		t.Errorf("byte intervals out of order")
+	}
+	if !tenbit.TenBit || tenbit.Address != 0x2a5 || !bytes.Equal(tenbit.Data, []byte{0x01}) || tenbit.Err != errI2CMissingStop {
+		t.Errorf("bad 10-bit transaction %+v", tenbit)
+	}
+}
+
+func TestI2CMisplacedCondition(t *testing.T) {
+	var b i2cBuilder
+	b.start()
+	b.byte(0x3c<<1, true)
+	b.bit(true)
+	b.bit(false)
+	b.stop()
+	scl, sda := b.files(2.5e-6) // 100kHz.
+	var i2c I2C
+	txs, err := i2c.Scan(scl, sda)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 1 || txs[0].Err != errI2CMisplaced || txs[0].Address != 0x3c {
+		t.Fatalf("expected misplaced stop error, got %+v", txs)
+	}
+}
+
+func TestI2CTimingSpec(t *testing.T) {
+	var b i2cBuilder
+	b.start()
+	b.byte(0x50<<1, true)
+	b.byte(0x10, true)
+	b.start()
+	b.byte(0x50<<1|1, true)
+	b.byte(0xca, false)
+	b.stop()
+	b.idle(1)
+	b.start()
+	b.byte(0x50<<1, false)
+	b.stop()
+	scl, sda := b.files(2.5e-6) // 100kHz.
+	// Start hold and stop set-up times last a quarter period and violate Standard-mode.
+	violations, err := I2CStandardMode.Check(scl, sda)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := make(map[I2CParam]int)
+	for _, v := range violations {
+		count[v.Param]++
+		if v.Measured >= v.Limit || v.EndTime()-v.StartTime() != v.Measured {
+			t.Errorf("bad violation %+v", v)
+		}
+	}
+	if len(count) != 2 || count[I2CTHdSta] != 3 || count[I2CTSuSto] != 2 {
+		t.Errorf("unexpected violations %v", count)
+	}
+	// Data hold time exceeds the Fast-mode maximum at 100kHz.
+	violations, err = I2CFastMode.Check(scl, sda)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One violation for each of the 27 SDA changes while SCL is low.
+	if len(violations) != 27 {
+		t.Fatalf("expected 27 Fast-mode violations, got %d: %+v", len(violations), violations)
+	}
+	for _, v := range violations {
+		if v.Param != I2CTHdDat || !v.AboveMax {
+			t.Errorf("unexpected Fast-mode violation %+v", v)
+		}
+	}
+	scl, sda = b.files(0.7e-6) // 357kHz.
+	violations, err = I2CFastMode.Check(scl, sda)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 0 {
+		t.Errorf("expected no Fast-mode violations, got %+v", violations)
+	}
+	// SCL is high for 2 periods during bits and 3 during the repeated start.
+	var spec I2CTimingSpec
+	spec.Max[I2CTHigh] = 2.5 * 1e-6
+	scl, sda = b.files(1e-6)
+	violations, err = spec.Check(scl, sda)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 0 {
+		t.Errorf("expected no tHIGH violation for the repeated start, got %+v", violations)
+	}
+}
+
+// i2cBuilder builds SCL and SDA signals in steps of a quarter clock period.
+type i2cBuilder struct {
+	scl, sda []byte
+}
+
+func (b *i2cBuilder) add(scl, sda byte) {
+	if len(b.scl) == 0 {
+		b.scl, b.sda = []byte{'1', '1'}, []byte{'1', '1'}
+	}
+	b.scl = append(b.scl, scl)
+	b.sda = append(b.sda, sda)
+}
+
+func (b *i2cBuilder) lastSDA() byte {
+	if len(b.sda) == 0 {
+		return '1'
+	}
+	return b.sda[len(b.sda)-1]
+}
+
+func (b *i2cBuilder) start() {
+	if len(b.scl) > 0 && b.scl[len(b.scl)-1] == '0' {
+		// Repeated start.
+		b.add('0', '1')
+		b.add('1', '1')
+	}
+	b.add('1', '1')
+	b.add('1', '0')
+	b.add('0', '0')
+}
+
+func (b *i2cBuilder) stop() {
+	b.add('0', '0')
+	b.add('1', '0')
+	b.add('1', '1')
+}
+
+func (b *i2cBuilder) bit(v bool) {
+	l := byte('0')
+	if v {
+		l = '1'
+	}
+	b.add('0', l)
+	b.add('1', l)
+	b.add('1', l)
+	b.add('0', l)
+}
+
+func (b *i2cBuilder) byte(v byte, ack bool) {
+	for i := 7; i >= 0; i-- {
+		b.bit(v&(1<<i) != 0)
+	}
+	b.bit(!ack)
+}
+
+func (b *i2cBuilder) stretch(n int) {
+	for i := 0; i < n; i++ {
+		b.add('0', b.lastSDA())
+	}
+}
+
+func (b *i2cBuilder) idle(n int) {
+	for i := 0; i < n; i++ {
+		b.add('1', '1')
+	}
+}
+
+// files returns the built signals with the given quarter clock period.
+func (b *i2cBuilder) files(quarter float64) (scl, sda *saleae.DigitalFile) {
+	return digitalFromBits(string(b.scl), quarter), digitalFromBits(string(b.sda), quarter)
+}
@@ -0,0 +1,204 @@
+package analyzers
+
+import (
+	"errors"
+	"math"
+
+	"github.com/soypat/saleae"
+)
+
+// I2CParam is an I2C bus timing parameter as defined by the I2C-bus specification (UM10204).
+type I2CParam uint8
+
+const (
+	// LOW period of the SCL clock.
+	I2CTLow I2CParam = iota
+	// HIGH period of the SCL clock.
+	I2CTHigh
+	// Set-up time for a repeated start condition.
+	I2CTSuSta
+	// Hold time for a (repeated) start condition.
+	I2CTHdSta
+	// Data set-up time.
+	I2CTSuDat
+	// Data hold time.
+	I2CTHdDat
+	// Set-up time for stop condition.
+	I2CTSuSto
+	// Bus free time between a stop and start condition.
+	I2CTBuf
+	numI2CParams
+)
+
+func (p I2CParam) String() (s string) {
+	switch p {
+	case I2CTLow:
+		s = "tLOW"
+	case I2CTHigh:
+		s = "tHIGH"
+	case I2CTSuSta:
+		s = "tSU;STA"
+	case I2CTHdSta:
+		s = "tHD;STA"
+	case I2CTSuDat:
+		s = "tSU;DAT"
+	case I2CTHdDat:
+		s = "tHD;DAT"
+	case I2CTSuSto:
+		s = "tSU;STO"
+	case I2CTBuf:
+		s = "tBUF"
+	default:
+		s = "unknown"
+	}
+	return s
+}
+
+// I2CTimingSpec contains the timing limits of an I2C bus mode in seconds.
+type I2CTimingSpec struct {
+	Name string
+	// Min contains the minimum value for each parameter.
+	Min [numI2CParams]float64
+	// Max contains the maximum value for each parameter. Zero means no maximum.
+	Max [numI2CParams]float64
+}
+
+var (
+	// Standard-mode, up to 100kHz.
+	I2CStandardMode = I2CTimingSpec{
+		Name: "Standard-mode",
+		Min: [numI2CParams]float64{
+			I2CTLow: 4.7e-6, I2CTHigh: 4.0e-6, I2CTSuSta: 4.7e-6, I2CTHdSta: 4.0e-6,
+			I2CTSuDat: 250e-9, I2CTHdDat: 0, I2CTSuSto: 4.0e-6, I2CTBuf: 4.7e-6,
+		},
+		Max: [numI2CParams]float64{I2CTHdDat: 3.45e-6},
+	}
+	// Fast-mode, up to 400kHz.
+	I2CFastMode = I2CTimingSpec{
+		Name: "Fast-mode",
+		Min: [numI2CParams]float64{
+			I2CTLow: 1.3e-6, I2CTHigh: 0.6e-6, I2CTSuSta: 0.6e-6, I2CTHdSta: 0.6e-6,
+			I2CTSuDat: 100e-9, I2CTHdDat: 0, I2CTSuSto: 0.6e-6, I2CTBuf: 1.3e-6,
+		},
+		Max: [numI2CParams]float64{I2CTHdDat: 0.9e-6},
+	}
+	// Fast-mode Plus, up to 1MHz.
+	I2CFastModePlus = I2CTimingSpec{
+		Name: "Fast-mode Plus",
+		Min: [numI2CParams]float64{
+			I2CTLow: 0.5e-6, I2CTHigh: 0.26e-6, I2CTSuSta: 0.26e-6, I2CTHdSta: 0.26e-6,
+			I2CTSuDat: 50e-9, I2CTHdDat: 0, I2CTSuSto: 0.26e-6, I2CTBuf: 0.5e-6,
+		},
+	}
+)
+
+// I2CViolation is a measured I2C timing parameter outside of the specification limits.
+// The interval spans the measured time.
+type I2CViolation struct {
+	Interval
+	Param I2CParam
+	// Measured value of the parameter in seconds.
+	Measured float64
+	// Limit that was violated.
+	Limit float64
+	// Set if the measurement exceeded a maximum limit.
+	AboveMax bool
+}
+
+// Check measures the timing parameters of the I2C bus given by scl and sda
+// and returns all violations of the specification limits.
+func (spec *I2CTimingSpec) Check(scl, sda *saleae.DigitalFile) (violations []I2CViolation, err error) {
+	if scl == nil || sda == nil {
+		return nil, errors.New("i2c: got nil digital file")
+	}
+	var (
+		sclState = scl.Header.InitialState != 0
+		sdaState = sda.Header.InitialState != 0
+		iscl     int
+		isda     int
+
+		nan          = math.NaN()
+		lastSCLRise  = nan
+		lastSCLFall  = nan
+		lastStart    = nan
+		lastStop     = nan
+		lastSDA      = nan // Last SDA change while SCL low.
+		startPending bool  // Start condition without SCL falling edge yet.
+		sdaChanged   bool  // SDA changed during current SCL low period.
+	)
+	check := func(param I2CParam, start, end float64) {
+		if math.IsNaN(start) {
+			return
+		}
+		v := I2CViolation{Param: param, Measured: end - start}
+		v.start = start
+		v.end = end
+		if min := spec.Min[param]; v.Measured < min {
+			v.Limit = min
+			violations = append(violations, v)
+		} else if max := spec.Max[param]; max > 0 && v.Measured > max {
+			v.Limit = max
+			v.AboveMax = true
+			violations = append(violations, v)
+		}
+	}
+	for iscl < len(scl.Data) || isda < len(sda.Data) {
+		tscl := math.Inf(1)
+		tsda := math.Inf(1)
+		if iscl < len(scl.Data) {
+			tscl = scl.Data[iscl]
+		}
+		if isda < len(sda.Data) {
+			tsda = sda.Data[isda]
+		}
+		if tsda < tscl {
+			isda++
+			sdaState = !sdaState
+			switch {
+			case !sclState:
+				if !sdaChanged {
+					check(I2CTHdDat, lastSCLFall, tsda)
+					sdaChanged = true
+				}
+				lastSDA = tsda
+			case !sdaState:
+				// Start condition.
+				if !math.IsNaN(lastStop) && lastStop > lastSCLFall {
+					check(I2CTBuf, lastStop, tsda)
+				} else {
+					check(I2CTSuSta, lastSCLRise, tsda)
+				}
+				lastStart = tsda
+				startPending = true
+			default:
+				// Stop condition.
+				check(I2CTSuSto, lastSCLRise, tsda)
+				lastStop = tsda
+			}
+			continue
+		}
+		iscl++
+		sclState = !sclState
+		if sclState {
+			check(I2CTLow, lastSCLFall, tscl)
+			if sdaChanged {
+				check(I2CTSuDat, lastSDA, tscl)
+			}
+			lastSCLRise = tscl
+			continue
+		}
+		repeatedStart := startPending && lastStart > lastSCLRise
+		if !math.IsNaN(lastSCLRise) && (math.IsNaN(lastStop) || lastStop < lastSCLRise) && !repeatedStart {
+			// Idle bus periods between stop and start are not clock HIGH periods, and
+			// periods holding a repeated start are checked against tSU;STA and tHD;STA.
+			check(I2CTHigh, lastSCLRise, tscl)
+		}
+		if startPending {
+			check(I2CTHdSta, lastStart, tscl)
+			startPending = false
+		}
+		lastSCLFall = tscl
+		sdaChanged = false
+	}
+	return violations, nil
+}
@@ -0,0 +1,322 @@
+package analyzers
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/soypat/saleae"
+)
+
+// AudioFormat is the framing of a serial audio stream.
+type AudioFormat uint8
+
+const (
+	// AudioI2S is Philips I2S. Data starts one BCLK after an LRCLK edge, left channel while LRCLK is low.
+	AudioI2S AudioFormat = iota
+	// AudioLeftJustified data starts at the LRCLK edge, left channel while LRCLK is high.
+	AudioLeftJustified
+	// AudioRightJustified data ends at the LRCLK edge, left channel while LRCLK is high.
+	AudioRightJustified
+	// AudioTDM frames start at the rising edge of a frame sync on LRCLK and contain
+	// Slots slots of SlotBits bits each.
+	AudioTDM
+)
+
+func (f AudioFormat) String() (s string) {
+	switch f {
+	case AudioI2S:
+		s = "i2s"
+	case AudioLeftJustified:
+		s = "lj"
+	case AudioRightJustified:
+		s = "rj"
+	case AudioTDM:
+		s = "tdm"
+	default:
+		s = "unknown"
+	}
+	return s
+}
+
+// SampleI2S is an audio sample of one channel.
+type SampleI2S struct {
+	Interval
+	Channel int
+	// Value is the sign extended sample.
+	Value int32
+}
+
+// I2S can be used to decode serial audio streams from the bit clock, word
+// select (or frame sync) and data lines. Data is sampled MSB first on the
+// rising edge of BCLK unless BCLKInverted is set.
+type I2S struct {
+	Format AudioFormat
+	// SampleBits is the number of bits per sample in range 1..32. Zero means 16.
+	SampleBits int
+	// SlotBits is the number of BCLK cycles per channel slot in TDM format.
+	// Zero means SampleBits. Other formats take the slot length from LRCLK.
+	SlotBits int
+	// Slots is the number of channel slots per TDM frame. Zero means 2.
+	Slots int
+	// FrameSyncDelay is the number of BCLK cycles between the frame sync
+	// edge and the first bit of a TDM frame, 1 for DSP mode A and 0 for mode B.
+	FrameSyncDelay int
+	// BCLKInverted is set if data is sampled on the falling edge of BCLK.
+	BCLKInverted bool
+	// LRCLKInverted is set if the word select or frame sync polarity is inverted.
+	LRCLKInverted bool
+}
+
+func (a *I2S) sampleBits() int {
+	if a.SampleBits == 0 {
+		return 16
+	}
+	return a.SampleBits
+}
+
+func (a *I2S) validate() error {
+	if n := a.sampleBits(); n < 1 || n > 32 {
+		return errors.New("i2s: sample bits must be in range 1..32")
+	}
+	if a.Format > AudioTDM {
+		return errors.New("i2s: invalid format")
+	}
+	if a.SlotBits < 0 || a.Slots < 0 || a.FrameSyncDelay < 0 {
+		return errors.New("i2s: negative slot bits, slots or frame sync delay")
+	}
+	if a.Format == AudioTDM && a.SlotBits != 0 && a.SlotBits < a.sampleBits() {
+		return errors.New("i2s: slot bits less than sample bits")
+	}
+	return nil
+}
+
+// Scan decodes all samples found on the audio lines. Samples are returned in the
+// order they were transferred.
+func (a *I2S) Scan(bclk, lrclk, sd *saleae.DigitalFile) (samples []SampleI2S, err error) {
+	if bclk == nil || lrclk == nil || sd == nil {
+		return nil, errors.New("i2s: got nil digital file")
+	}
+	if err = a.validate(); err != nil {
+		return nil, err
+	}
+	// Sample word select and data on every active clock edge.
+	var (
+		clk    = newSignal(bclk)
+		ws     = newSignal(lrclk)
+		data   = newSignal(sd)
+		active = !a.BCLKInverted
+		bits   []bool
+		wss    []bool
+		times  []float64
+		t      = math.Inf(-1)
+	)
+	for {
+		t = clk.nextTo(t, active)
+		if math.IsInf(t, 1) {
+			break
+		}
+		bits = append(bits, data.at(t))
+		wss = append(wss, ws.at(t) != a.LRCLKInverted)
+		times = append(times, t)
+	}
+	// Word boundaries are the first bits sampled after an LRCLK edge.
+	var bounds []int
+	for i := 1; i < len(wss); i++ {
+		if wss[i] != wss[i-1] && (a.Format != AudioTDM || wss[i]) {
+			bounds = append(bounds, i)
+		}
+	}
+	n := a.sampleBits()
+	word := func(start, ch int) {
+		if start < 0 || start+n > len(bits) {
+			return
+		}
+		s := SampleI2S{Channel: ch}
+		s.start = times[start]
+		s.end = times[start+n-1]
+		var v uint32
+		for _, b := range bits[start : start+n] {
+			v = v<<1 | uint32(b2u8(b))
+		}
+		s.Value = int32(v<<(32-n)) >> (32 - n)
+		samples = append(samples, s)
+	}
+	for j, b := range bounds {
+		switch a.Format {
+		case AudioTDM:
+			slot := a.SlotBits
+			if slot == 0 {
+				slot = n
+			}
+			slots := a.Slots
+			if slots == 0 {
+				slots = 2
+			}
+			for ch := 0; ch < slots; ch++ {
+				word(b+a.FrameSyncDelay+ch*slot, ch)
+			}
+		case AudioRightJustified:
+			if j == 0 {
+				// Word ending at the first LRCLK edge.
+				word(b-n, int(b2u8(!wss[b-1])))
+			}
+			if j+1 < len(bounds) {
+				word(bounds[j+1]-n, int(b2u8(!wss[b])))
+			} else if j > 0 && 2*b-bounds[j-1] <= len(bits) {
+				// Trailing word is complete if its slot is as long as the previous one.
+				word(2*b-bounds[j-1]-n, int(b2u8(!wss[b])))
+			}
+		case AudioLeftJustified:
+			word(b, int(b2u8(!wss[b])))
+		case AudioI2S:
+			word(b+1, int(b2u8(wss[b])))
+		}
+	}
+	return samples, nil
+}
+
+// SampleRate estimates the sample rate of channel ch from the median time
+// between its samples. It returns zero if there are less than two samples.
+func SampleRate(samples []SampleI2S, ch int) float64 {
+	var diffs []float64
+	last := math.NaN()
+	for _, s := range samples {
+		if s.Channel != ch {
+			continue
+		}
+		if !math.IsNaN(last) {
+			diffs = append(diffs, s.start-last)
+		}
+		last = s.start
+	}
+	if len(diffs) == 0 {
+		return 0
+	}
+	sort.Float64s(diffs)
+	return 1 / diffs[len(diffs)/2]
+}
+
+// WriteWAV writes the samples of channel ch as a mono PCM WAV file with
+// the sample rate estimated from the sample timestamps. Samples are stored
+// in the smallest whole number of bytes that fits the sample width,
+// left aligned as required by the WAV format. At least two samples of the
+// channel are needed to estimate the sample rate.
+func (a *I2S) WriteWAV(w io.Writer, samples []SampleI2S, ch int) error {
+	n := a.sampleBits()
+	container := (n + 7) / 8
+	var pcm []byte
+	for _, s := range samples {
+		if s.Channel != ch {
+			continue
+		}
+		v := uint32(s.Value) << (container*8 - n)
+		if container == 1 {
+			v += 0x80 // 8-bit WAV samples are unsigned.
+		}
+		for i := 0; i < container; i++ {
+			pcm = append(pcm, byte(v>>(8*i)))
+		}
+	}
+	if len(pcm) == 0 {
+		return errors.New("i2s: no samples for channel " + strconv.Itoa(ch))
+	}
+	rate := uint32(math.Round(SampleRate(samples, ch)))
+	if rate == 0 {
+		return errors.New("i2s: cannot estimate sample rate of channel " + strconv.Itoa(ch))
+	}
+	var hdr [44]byte
+	copy(hdr[0:], "RIFF")
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(36+len(pcm)))
+	copy(hdr[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(hdr[16:], 16)
+	binary.LittleEndian.PutUint16(hdr[20:], 1) // PCM.
+	binary.LittleEndian.PutUint16(hdr[22:], 1) // Mono.
+	binary.LittleEndian.PutUint32(hdr[24:], rate)
+	binary.LittleEndian.PutUint32(hdr[28:], rate*uint32(container))
+	binary.LittleEndian.PutUint16(hdr[32:], uint16(container))
+	binary.LittleEndian.PutUint16(hdr[34:], uint16(container*8))
+	copy(hdr[36:], "data")
+	binary.LittleEndian.PutUint32(hdr[40:], uint32(len(pcm)))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(pcm)
+	return err
+}
+
+func init() {
+	Register("I2S", func() Analyzer { return &I2S{} })
+}
+
+// Channels implements Analyzer.
+func (*I2S) Channels() []Channel {
+	return []Channel{{Name: "bclk"}, {Name: "lrclk"}, {Name: "sd"}}
+}
+
+// Settings implements Analyzer.
+func (a *I2S) Settings() []Setting {
+	return []Setting{
+		{Name: "format", Usage: "i2s, lj, rj or tdm", Value: a.Format.String()},
+		{Name: "bits", Usage: "bits per sample, 0 means 16", Value: strconv.Itoa(a.SampleBits)},
+		{Name: "slot", Usage: "TDM slot length in bits, 0 means sample bits", Value: strconv.Itoa(a.SlotBits)},
+		{Name: "slots", Usage: "TDM slots per frame, 0 means 2", Value: strconv.Itoa(a.Slots)},
+		{Name: "delay", Usage: "TDM frame sync to first bit delay in BCLK cycles", Value: strconv.Itoa(a.FrameSyncDelay)},
+		{Name: "bclkinv", Usage: "sample data on falling BCLK edge", Value: strconv.FormatBool(a.BCLKInverted)},
+		{Name: "lrclkinv", Usage: "invert LRCLK polarity", Value: strconv.FormatBool(a.LRCLKInverted)},
+	}
+}
+
+// Set implements Analyzer.
+func (a *I2S) Set(name, value string) (err error) {
+	switch name {
+	case "format":
+		for f := AudioI2S; f <= AudioTDM; f++ {
+			if f.String() == value {
+				a.Format = f
+				return nil
+			}
+		}
+		err = errors.New("i2s: unknown format " + strconv.Quote(value))
+	case "bits":
+		a.SampleBits, err = strconv.Atoi(value)
+	case "slot":
+		a.SlotBits, err = strconv.Atoi(value)
+	case "slots":
+		a.Slots, err = strconv.Atoi(value)
+	case "delay":
+		a.FrameSyncDelay, err = strconv.Atoi(value)
+	case "bclkinv":
+		a.BCLKInverted, err = strconv.ParseBool(value)
+	case "lrclkinv":
+		a.LRCLKInverted, err = strconv.ParseBool(value)
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Each sample is returned as a frame of type
+// "sample" with its "channel" and sign extended "value".
+func (a *I2S) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(a, channels)
+	if err != nil {
+		return nil, err
+	}
+	samples, err := a.Scan(in[0], in[1], in[2])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(samples))
+	for i, s := range samples {
+		frames[i] = Frame{
+			Interval: s.Interval,
+			Type:     "sample",
+			Data:     map[string]any{"channel": s.Channel, "value": s.Value},
+		}
+	}
+	return frames, nil
+}
@@ -0,0 +1,183 @@
+package analyzers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/soypat/saleae"
+)
+
+// i2sWave builds BCLK, LRCLK and SD waveforms one clock cycle at a time.
+// LRCLK and SD change on the falling edge of BCLK.
+type i2sWave struct {
+	period       float64
+	n            int
+	bclk, ws, sd saleae.DigitalFile
+	wsl, sdl     bool
+}
+
+func (w *i2sWave) cycle(ws, sd bool) {
+	t := float64(w.n) * w.period
+	if w.n > 0 {
+		w.bclk.Data = append(w.bclk.Data, t)
+	}
+	w.bclk.Data = append(w.bclk.Data, t+w.period/2)
+	if ws != w.wsl {
+		w.ws.Data = append(w.ws.Data, t)
+		w.wsl = ws
+	}
+	if sd != w.sdl {
+		w.sd.Data = append(w.sd.Data, t)
+		w.sdl = sd
+	}
+	w.n++
+}
+
+func TestI2SFormats(t *testing.T) {
+	const (
+		bits = 16
+		slot = 32
+	)
+	left := []int32{1000, -1000, 32767, -32768}
+	right := []int32{-1, 0, 0x1234, -0x1234}
+	for _, format := range []AudioFormat{AudioI2S, AudioLeftJustified, AudioRightJustified, AudioTDM} {
+		w := i2sWave{period: 1 / (48000.0 * 2 * slot)}
+		// Bits of each slot, MSB first and positioned per format.
+		slotBits := func(v int32) []bool {
+			b := make([]bool, slot)
+			off := 0
+			if format == AudioRightJustified {
+				off = slot - bits
+			}
+			for i := 0; i < bits; i++ {
+				b[off+i] = v>>(bits-1-i)&1 != 0
+			}
+			return b
+		}
+		// Idle frame first so that the first word boundary is seen.
+		for i := 0; i < 2*slot; i++ {
+			w.cycle(format == AudioI2S, false)
+		}
+		var prevLSB bool
+		for i := range left {
+			for ch, v := range []int32{left[i], right[i]} {
+				b := slotBits(v)
+				for j := 0; j < slot; j++ {
+					var ws, sd bool
+					switch format {
+					case AudioI2S:
+						ws = ch == 1
+						sd = prevLSB
+						if j > 0 {
+							sd = b[j-1]
+						}
+					case AudioLeftJustified, AudioRightJustified:
+						ws, sd = ch == 0, b[j]
+					case AudioTDM:
+						ws, sd = ch == 0 && j == 0, b[j]
+					}
+					w.cycle(ws, sd)
+				}
+				prevLSB = b[slot-1]
+			}
+		}
+		// Trailing frame so that the last right justified word is bounded.
+		for i := 0; i < 2*slot; i++ {
+			w.cycle(format == AudioRightJustified || format == AudioTDM && i == 0, false)
+		}
+		a := I2S{Format: format, SampleBits: bits, SlotBits: slot}
+		samples, err := a.Scan(&w.bclk, &w.ws, &w.sd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got [2][]int32
+		for _, s := range samples {
+			got[s.Channel] = append(got[s.Channel], s.Value)
+		}
+		// Skip samples from idle frames.
+		for ch, want := range [2][]int32{left, right} {
+			g := got[ch]
+			for len(g) > len(want) && g[0] == 0 {
+				g = g[1:]
+			}
+			if len(g) < len(want) || string(i32bytes(g[:len(want)])) != string(i32bytes(want)) {
+				t.Errorf("%v channel %d: got %v, want %v", format, ch, got[ch], want)
+			}
+		}
+		if format != AudioI2S {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := a.WriteWAV(&buf, samples, 0); err != nil {
+			t.Fatal(err)
+		}
+		wav := buf.Bytes()
+		if string(wav[0:4]) != "RIFF" || string(wav[8:16]) != "WAVEfmt " {
+			t.Fatalf("bad WAV header %q", wav[:16])
+		}
+		if rate := binary.LittleEndian.Uint32(wav[24:]); rate != 48000 {
+			t.Errorf("got sample rate %d", rate)
+		}
+		if bps := binary.LittleEndian.Uint16(wav[34:]); bps != 16 {
+			t.Errorf("got %d bits per sample", bps)
+		}
+		if n := binary.LittleEndian.Uint32(wav[40:]); int(n) != 2*len(got[0]) || len(wav) != 44+int(n) {
+			t.Errorf("got data size %d for %d samples", n, len(got[0]))
+		}
+		buf.Reset()
+		if err := a.WriteWAV(&buf, samples[:1], 0); err == nil || buf.Len() != 0 {
+			t.Errorf("expected error writing a single sample WAV, wrote %d bytes", buf.Len())
+		}
+	}
+}
+
+func TestI2SRightJustifiedEdges(t *testing.T) {
+	const (
+		bits = 16
+		slot = 24
+	)
+	// Capture starts with a right channel slot and ends right after a left channel
+	// slot, so neither has an LRCLK edge on both sides.
+	want := []struct {
+		ch int
+		v  int32
+	}{{1, -2}, {0, 100}, {1, 0x7fff}, {0, -0x8000}}
+	w := i2sWave{period: 1e-6}
+	for _, s := range want {
+		for j := 0; j < slot; j++ {
+			i := j - (slot - bits)
+			w.cycle(s.ch == 0, i >= 0 && s.v>>(bits-1-i)&1 != 0)
+		}
+	}
+	a := I2S{Format: AudioRightJustified, SampleBits: bits}
+	samples, err := a.Scan(&w.bclk, &w.ws, &w.sd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != len(want) {
+		t.Fatalf("got %d samples, want %d: %v", len(samples), len(want), samples)
+	}
+	for i, s := range samples {
+		if s.Channel != want[i].ch || s.Value != want[i].v {
+			t.Errorf("sample %d: got channel %d value %d, want channel %d value %d", i, s.Channel, s.Value, want[i].ch, want[i].v)
+		}
+	}
+	// Trailing slot one bit short is incomplete.
+	w.bclk.Data = w.bclk.Data[:len(w.bclk.Data)-2]
+	samples, err = a.Scan(&w.bclk, &w.ws, &w.sd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != len(want)-1 {
+		t.Errorf("got %d samples with incomplete trailing slot, want %d", len(samples), len(want)-1)
+	}
+}
+
+func i32bytes(v []int32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], uint32(x))
+	}
+	return b
+}
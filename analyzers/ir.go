@@ -0,0 +1,400 @@
+package analyzers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/soypat/saleae"
+)
+
+// IRProtocol is an infrared remote control protocol.
+type IRProtocol uint8
+
+const (
+	IRUnknown IRProtocol = iota
+	IRNEC
+	IRRC5
+	IRRC6
+	IRSIRC
+)
+
+func (p IRProtocol) String() (s string) {
+	switch p {
+	case IRUnknown:
+		s = "unknown"
+	case IRNEC:
+		s = "nec"
+	case IRRC5:
+		s = "rc5"
+	case IRRC6:
+		s = "rc6"
+	case IRSIRC:
+		s = "sirc"
+	default:
+		s = "unknown"
+	}
+	return s
+}
+
+// Protocol timings in seconds.
+const (
+	irNECLeaderMark    = 9e-3
+	irNECLeaderSpace   = 4.5e-3
+	irNECRepeatSpace   = 2.25e-3
+	irNECUnit          = 562.5e-6
+	irSIRCLeaderMark   = 2.4e-3
+	irSIRCUnit         = 0.6e-3
+	irRC5Half          = 889e-6
+	irRC6Unit          = 444.4e-6
+	irRC6LeaderMark    = 6 * irRC6Unit
+	irRC6LeaderSpace   = 2 * irRC6Unit
+	irFrameGap         = 8e-3
+	irRepeatWithin     = 150e-3
+	irDefaultTolerance = 0.3
+)
+
+var (
+	errIRUnknown = errors.New("ir: unknown protocol")
+	errIRTiming  = errors.New("ir: pulse timing out of tolerance")
+	errIRBits    = errors.New("ir: unexpected number of bits")
+	errIRCheck   = errors.New("ir: inverted command check failed")
+	errIRCoding  = errors.New("ir: Manchester coding violation")
+)
+
+// FrameIR is a decoded infrared remote control frame.
+type FrameIR struct {
+	Interval
+	Protocol IRProtocol
+	// Address is the device address. It is 16 bits for extended NEC and
+	// 13 bits for 20-bit SIRC where the extended byte is in the upper bits.
+	Address uint16
+	// Command is the key code. RC5 field bit inverted is command bit 6 (RC5X).
+	Command uint16
+	// Toggle is the RC5 or RC6 toggle bit which changes on each key press.
+	Toggle bool
+	// Mode is the RC6 mode.
+	Mode uint8
+	// Bits is the number of data bits received.
+	Bits int
+	// Repeat is set for NEC repeat codes and for frames repeating the previous
+	// frame of the same protocol while a key is held down.
+	Repeat bool
+	Err    error
+}
+
+// IR can be used to decode demodulated infrared remote control signals
+// such as the output of an IR receiver module. Frames are recognized by
+// their leader, RC5 frames have none.
+type IR struct {
+	// Tolerance is the maximum relative deviation of pulse lengths. Zero means 0.3.
+	Tolerance float64
+	// ActiveHigh is set if marks (carrier present) are high. IR receiver
+	// modules output marks as low.
+	ActiveHigh bool
+}
+
+// irBurst is a sequence of alternating mark and space lengths starting and ending with a mark.
+type irBurst struct {
+	start  float64
+	pulses []float64
+	tol    float64
+}
+
+// near returns true if pulse i is within tolerance of want.
+func (b *irBurst) near(i int, want float64) bool {
+	return i < len(b.pulses) && math.Abs(b.pulses[i]-want) <= b.tol*want
+}
+
+func (b *irBurst) end() float64 {
+	t := b.start
+	for _, p := range b.pulses {
+		t += p
+	}
+	return t
+}
+
+// Scan decodes all IR frames found on d.
+func (ir *IR) Scan(d *saleae.DigitalFile) (frames []FrameIR, err error) {
+	if d == nil {
+		return nil, errors.New("ir: got nil digital file")
+	}
+	tol := ir.Tolerance
+	if tol == 0 {
+		tol = irDefaultTolerance
+	}
+	if tol < 0 || tol >= 0.5 {
+		return nil, errors.New("ir: tolerance must be in range 0..0.5")
+	}
+	mark := ir.ActiveHigh
+	e := d.Data
+	for i := 0; i < len(e); i++ {
+		if levelAt(d, e[i]) != mark {
+			continue // Not a mark start.
+		}
+		b := irBurst{start: e[i], tol: tol}
+		j := i + 1
+		for ; j < len(e); j++ {
+			if levelAt(d, e[j]) == mark && e[j]-e[j-1] > irFrameGap {
+				break
+			}
+			b.pulses = append(b.pulses, e[j]-e[j-1])
+		}
+		if len(b.pulses)%2 == 0 {
+			// Capture ends during a mark.
+			i = j - 1
+			continue
+		}
+		f := ir.decode(&b)
+		f.start, f.end = b.start, b.end()
+		if len(frames) > 0 && f.Err == nil && f.Protocol != IRNEC {
+			last := frames[len(frames)-1]
+			f.Repeat = last.Err == nil && last.Protocol == f.Protocol && last.Address == f.Address &&
+				last.Command == f.Command && last.Toggle == f.Toggle && f.start-last.end < irRepeatWithin
+		}
+		if f.Repeat && f.Protocol == IRNEC && len(frames) > 0 && frames[len(frames)-1].Protocol == IRNEC {
+			last := frames[len(frames)-1]
+			f.Address, f.Command = last.Address, last.Command
+		}
+		frames = append(frames, f)
+		i = j - 1
+	}
+	return frames, nil
+}
+
+func (ir *IR) decode(b *irBurst) (f FrameIR) {
+	switch {
+	case b.near(0, irNECLeaderMark):
+		f = b.nec()
+	case b.near(0, irSIRCLeaderMark) && b.near(1, irSIRCUnit):
+		f = b.sirc()
+	case b.near(0, irRC6LeaderMark) && b.near(1, irRC6LeaderSpace):
+		f = b.rc6()
+	case b.near(0, irRC5Half) || b.near(0, 2*irRC5Half):
+		f = b.rc5()
+	default:
+		f.Err = errIRUnknown
+	}
+	return f
+}
+
+func (b *irBurst) nec() (f FrameIR) {
+	f.Protocol = IRNEC
+	if b.near(1, irNECRepeatSpace) && len(b.pulses) == 3 {
+		f.Repeat = true
+		return f
+	}
+	if !b.near(1, irNECLeaderSpace) {
+		f.Err = errIRTiming
+		return f
+	}
+	var v uint32
+	for i := 2; i+1 < len(b.pulses); i += 2 {
+		switch {
+		case !b.near(i, irNECUnit):
+			f.Err = errIRTiming
+		case b.near(i+1, 3*irNECUnit):
+			v |= 1 << f.Bits
+		case !b.near(i+1, irNECUnit):
+			f.Err = errIRTiming
+		}
+		f.Bits++
+	}
+	if f.Err != nil {
+		return f
+	}
+	if f.Bits != 32 {
+		f.Err = errIRBits
+		return f
+	}
+	f.Address = uint16(v & 0xff)
+	if byte(v>>8) != ^byte(v) {
+		f.Address = uint16(v) // Extended NEC.
+	}
+	f.Command = uint16(v >> 16 & 0xff)
+	if byte(v>>24) != ^byte(v>>16) {
+		f.Err = errIRCheck
+	}
+	return f
+}
+
+func (b *irBurst) sirc() (f FrameIR) {
+	f.Protocol = IRSIRC
+	var v uint32
+	for i := 2; i < len(b.pulses); i += 2 {
+		switch {
+		case b.near(i, 2*irSIRCUnit):
+			v |= 1 << f.Bits
+		case !b.near(i, irSIRCUnit):
+			f.Err = errIRTiming
+		}
+		if i+1 < len(b.pulses) && !b.near(i+1, irSIRCUnit) {
+			f.Err = errIRTiming
+		}
+		f.Bits++
+	}
+	if f.Err != nil {
+		return f
+	}
+	switch f.Bits {
+	case 12, 15, 20:
+	default:
+		f.Err = errIRBits
+		return f
+	}
+	f.Command = uint16(v & 0x7f)
+	f.Address = uint16(v >> 7)
+	return f
+}
+
+// halves returns the burst from pulse i on as a sequence of mark levels, one
+// per unit of time. Pulses must be whole multiples of unit.
+func (b *irBurst) halves(i int, unit float64) (levels []bool, ok bool) {
+	for ; i < len(b.pulses); i++ {
+		n := math.Round(b.pulses[i] / unit)
+		if n < 1 || math.Abs(b.pulses[i]-n*unit) > b.tol*unit {
+			return levels, false
+		}
+		for k := 0; k < int(n); k++ {
+			levels = append(levels, i%2 == 0)
+		}
+	}
+	return levels, true
+}
+
+// manchesterIR decodes bits from pairs of levels, MSB first. A one is a
+// mark then space if markFirst, else a space then mark.
+func manchesterIR(levels []bool, markFirst bool) (v uint32, n int, ok bool) {
+	for ; 2*n+1 < len(levels); n++ {
+		a, b := levels[2*n], levels[2*n+1]
+		if a == b {
+			return v, n, false
+		}
+		v = v<<1 | uint32(b2u8(a == markFirst))
+	}
+	return v, n, true
+}
+
+func (b *irBurst) rc5() (f FrameIR) {
+	f.Protocol = IRRC5
+	levels, ok := b.halves(0, irRC5Half)
+	if !ok {
+		f.Err = errIRTiming
+		return f
+	}
+	// The first half of the start bit and the last half of a zero are space.
+	levels = append([]bool{false}, levels...)
+	if len(levels)%2 == 1 {
+		levels = append(levels, false)
+	}
+	v, n, ok := manchesterIR(levels, false)
+	f.Bits = n
+	switch {
+	case !ok:
+		f.Err = errIRCoding
+		return f
+	case n != 14:
+		f.Err = errIRBits
+		return f
+	}
+	f.Command = uint16(v&0x3f) | uint16(^v>>12&1)<<6
+	f.Address = uint16(v >> 6 & 0x1f)
+	f.Toggle = v>>11&1 != 0
+	return f
+}
+
+func (b *irBurst) rc6() (f FrameIR) {
+	f.Protocol = IRRC6
+	levels, ok := b.halves(2, irRC6Unit)
+	if !ok {
+		f.Err = errIRTiming
+		return f
+	}
+	if len(levels)%2 == 1 {
+		levels = append(levels, false)
+	}
+	// Start bit and 3 mode bits, trailer bit of double length and data bits.
+	const trailer = 2 * 4
+	if len(levels) < trailer+4 {
+		f.Err = errIRBits
+		return f
+	}
+	hdr, _, ok := manchesterIR(levels[:trailer], true)
+	tr := levels[trailer : trailer+4]
+	if !ok || hdr>>3 != 1 || tr[0] != tr[1] || tr[2] != tr[3] || tr[0] == tr[2] {
+		f.Err = errIRCoding
+		return f
+	}
+	f.Mode = uint8(hdr & 7)
+	f.Toggle = tr[0]
+	v, n, ok := manchesterIR(levels[trailer+4:], true)
+	f.Bits = n
+	switch {
+	case !ok:
+		f.Err = errIRCoding
+	case n == 16:
+		f.Address, f.Command = uint16(v>>8), uint16(v&0xff)
+	case n == 20, n == 24, n == 32:
+		f.Address, f.Command = uint16(v>>16), uint16(v)
+	default:
+		f.Err = errIRBits
+	}
+	return f
+}
+
+func init() {
+	Register("IR", func() Analyzer { return &IR{} })
+}
+
+// Channels implements Analyzer.
+func (*IR) Channels() []Channel { return []Channel{{Name: "data"}} }
+
+// Settings implements Analyzer.
+func (ir *IR) Settings() []Setting {
+	return []Setting{
+		{Name: "tolerance", Usage: "relative pulse length tolerance, 0 means 0.3", Value: formatFloat(ir.Tolerance)},
+		{Name: "activehigh", Usage: "marks are high", Value: strconv.FormatBool(ir.ActiveHigh)},
+	}
+}
+
+// Set implements Analyzer.
+func (ir *IR) Set(name, value string) (err error) {
+	switch name {
+	case "tolerance":
+		ir.Tolerance, err = strconv.ParseFloat(value, 64)
+	case "activehigh":
+		ir.ActiveHigh, err = strconv.ParseBool(value)
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Each IR frame is returned as a frame of type "key"
+// with the "protocol", "address", "command", "toggle" and "repeat" fields.
+func (ir *IR) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(ir, channels)
+	if err != nil {
+		return nil, err
+	}
+	irFrames, err := ir.Scan(in[0])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(irFrames))
+	for i, f := range irFrames {
+		frames[i] = Frame{
+			Interval: f.Interval,
+			Type:     "key",
+			Data: map[string]any{
+				"protocol": f.Protocol.String(),
+				"address":  f.Address,
+				"command":  f.Command,
+				"toggle":   f.Toggle,
+				"repeat":   f.Repeat,
+			},
+			Err: f.Err,
+		}
+	}
+	return frames, nil
+}
@@ -0,0 +1,140 @@
+package analyzers
+
+import (
+	"testing"
+
+	"github.com/soypat/saleae"
+)
+
+// irWave builds the active low output of an IR receiver from mark and space lengths.
+type irWave struct {
+	t  float64
+	df saleae.DigitalFile
+}
+
+func (w *irWave) pulses(p ...float64) {
+	for _, d := range p {
+		w.df.Data = append(w.df.Data, w.t)
+		w.t += d
+	}
+}
+
+// gap ends a burst with a mark end followed by a space.
+func (w *irWave) gap(d float64) {
+	w.df.Data = append(w.df.Data, w.t)
+	w.t += d
+}
+
+func (w *irWave) nec(addr, cmd byte) {
+	v := uint32(addr) | uint32(^addr)<<8 | uint32(cmd)<<16 | uint32(^cmd)<<24
+	w.pulses(irNECLeaderMark, irNECLeaderSpace)
+	for i := 0; i < 32; i++ {
+		space := irNECUnit
+		if v>>i&1 != 0 {
+			space *= 3
+		}
+		w.pulses(irNECUnit*1.1, space*0.95) // Receiver mark stretching.
+	}
+	w.pulses(irNECUnit)
+	w.gap(40e-3)
+}
+
+func (w *irWave) sirc(v uint32, bits int) {
+	w.pulses(irSIRCLeaderMark, irSIRCUnit)
+	for i := 0; i < bits; i++ {
+		mark := irSIRCUnit
+		if v>>i&1 != 0 {
+			mark *= 2
+		}
+		if i > 0 {
+			w.pulses(irSIRCUnit)
+		}
+		w.pulses(mark)
+	}
+	w.gap(25e-3)
+}
+
+// manchester writes bits MSB first as half bit levels with unit length, merging
+// equal adjacent levels into pulses. Leading and trailing space is dropped.
+func (w *irWave) manchester(unit float64, halves []bool) {
+	for len(halves) > 0 && !halves[0] {
+		halves = halves[1:]
+	}
+	for len(halves) > 0 && !halves[len(halves)-1] {
+		halves = halves[:len(halves)-1]
+	}
+	for i := 0; i < len(halves); {
+		j := i
+		for j < len(halves) && halves[j] == halves[i] {
+			j++
+		}
+		w.pulses(float64(j-i) * unit)
+		i = j
+	}
+	w.gap(90e-3)
+}
+
+func irHalves(v uint32, n int, markFirst bool) (h []bool) {
+	for i := n - 1; i >= 0; i-- {
+		one := v>>i&1 != 0
+		h = append(h, one == markFirst, one != markFirst)
+	}
+	return h
+}
+
+func TestIR(t *testing.T) {
+	w := irWave{t: 10e-3}
+	w.df.Header.InitialState = 1 // Idle high.
+	w.nec(0x04, 0x08)
+	w.pulses(irNECLeaderMark, irNECRepeatSpace, irNECUnit)
+	w.gap(95e-3)
+	// RC5 address 5, command 0x35, toggle set, sent twice.
+	rc5 := uint32(1<<13 | 1<<12 | 1<<11 | 5<<6 | 0x35)
+	w.manchester(irRC5Half, irHalves(rc5, 14, false))
+	w.manchester(irRC5Half, irHalves(rc5, 14, false))
+	// RC6 mode 0, address 0x12, command 0x34, toggle clear.
+	var rc6 []bool
+	for i := 0; i < 6; i++ {
+		rc6 = append(rc6, true)
+	}
+	rc6 = append(rc6, false, false)
+	rc6 = append(rc6, irHalves(0b1000, 4, true)...)
+	rc6 = append(rc6, false, false, true, true)
+	rc6 = append(rc6, irHalves(0x1234, 16, true)...)
+	w.manchester(irRC6Unit, rc6)
+	// SIRC 12-bit and 20-bit.
+	w.sirc(0x15|0x01<<7, 12)
+	w.sirc(0x2a|0x1a<<7|0x3c<<12, 20)
+	// NEC with a corrupted command check.
+	w.pulses(irNECLeaderMark, irNECLeaderSpace)
+	for i := 0; i < 32; i++ {
+		w.pulses(irNECUnit, irNECUnit)
+	}
+	w.pulses(irNECUnit)
+	w.gap(40e-3)
+
+	var ir IR
+	frames, err := ir.Scan(&w.df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FrameIR{
+		{Protocol: IRNEC, Address: 0x04, Command: 0x08, Bits: 32},
+		{Protocol: IRNEC, Address: 0x04, Command: 0x08, Repeat: true},
+		{Protocol: IRRC5, Address: 5, Command: 0x35, Toggle: true, Bits: 14},
+		{Protocol: IRRC5, Address: 5, Command: 0x35, Toggle: true, Bits: 14, Repeat: true},
+		{Protocol: IRRC6, Address: 0x12, Command: 0x34, Bits: 16},
+		{Protocol: IRSIRC, Address: 0x01, Command: 0x15, Bits: 12},
+		{Protocol: IRSIRC, Address: 0x1a | 0x3c<<5, Command: 0x2a, Bits: 20},
+		{Protocol: IRNEC, Bits: 32, Err: errIRCheck},
+	}
+	if len(frames) != len(want) {
+		t.Fatalf("expected %d frames, got %d: %+v", len(want), len(frames), frames)
+	}
+	for i, f := range frames {
+		f.Interval = Interval{}
+		if f != want[i] {
+			t.Errorf("frame %d: expected %+v, got %+v", i, want[i], f)
+		}
+	}
+}
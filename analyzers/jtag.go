@@ -0,0 +1,312 @@
+package analyzers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/soypat/saleae"
+)
+
+// TAPState is a state of the JTAG TAP controller.
+type TAPState uint8
+
+const (
+	TAPTestLogicReset TAPState = iota
+	TAPRunTestIdle
+	TAPSelectDRScan
+	TAPCaptureDR
+	TAPShiftDR
+	TAPExit1DR
+	TAPPauseDR
+	TAPExit2DR
+	TAPUpdateDR
+	TAPSelectIRScan
+	TAPCaptureIR
+	TAPShiftIR
+	TAPExit1IR
+	TAPPauseIR
+	TAPExit2IR
+	TAPUpdateIR
+)
+
+// tapNext holds the next state for TMS low and high.
+var tapNext = [16][2]TAPState{
+	TAPTestLogicReset: {TAPRunTestIdle, TAPTestLogicReset},
+	TAPRunTestIdle:    {TAPRunTestIdle, TAPSelectDRScan},
+	TAPSelectDRScan:   {TAPCaptureDR, TAPSelectIRScan},
+	TAPCaptureDR:      {TAPShiftDR, TAPExit1DR},
+	TAPShiftDR:        {TAPShiftDR, TAPExit1DR},
+	TAPExit1DR:        {TAPPauseDR, TAPUpdateDR},
+	TAPPauseDR:        {TAPPauseDR, TAPExit2DR},
+	TAPExit2DR:        {TAPShiftDR, TAPUpdateDR},
+	TAPUpdateDR:       {TAPRunTestIdle, TAPSelectDRScan},
+	TAPSelectIRScan:   {TAPCaptureIR, TAPTestLogicReset},
+	TAPCaptureIR:      {TAPShiftIR, TAPExit1IR},
+	TAPShiftIR:        {TAPShiftIR, TAPExit1IR},
+	TAPExit1IR:        {TAPPauseIR, TAPUpdateIR},
+	TAPPauseIR:        {TAPPauseIR, TAPExit2IR},
+	TAPExit2IR:        {TAPShiftIR, TAPUpdateIR},
+	TAPUpdateIR:       {TAPRunTestIdle, TAPSelectDRScan},
+}
+
+// Next returns the state the TAP controller transitions to on a rising
+// edge of TCK with TMS at level tms.
+func (s TAPState) Next(tms bool) TAPState {
+	return tapNext[s&0xf][b2u8(tms)]
+}
+
+func (s TAPState) String() (str string) {
+	switch s {
+	case TAPTestLogicReset:
+		str = "Test-Logic-Reset"
+	case TAPRunTestIdle:
+		str = "Run-Test/Idle"
+	case TAPSelectDRScan:
+		str = "Select-DR-Scan"
+	case TAPCaptureDR:
+		str = "Capture-DR"
+	case TAPShiftDR:
+		str = "Shift-DR"
+	case TAPExit1DR:
+		str = "Exit1-DR"
+	case TAPPauseDR:
+		str = "Pause-DR"
+	case TAPExit2DR:
+		str = "Exit2-DR"
+	case TAPUpdateDR:
+		str = "Update-DR"
+	case TAPSelectIRScan:
+		str = "Select-IR-Scan"
+	case TAPCaptureIR:
+		str = "Capture-IR"
+	case TAPShiftIR:
+		str = "Shift-IR"
+	case TAPExit1IR:
+		str = "Exit1-IR"
+	case TAPPauseIR:
+		str = "Pause-IR"
+	case TAPExit2IR:
+		str = "Exit2-IR"
+	case TAPUpdateIR:
+		str = "Update-IR"
+	default:
+		str = "unknown"
+	}
+	return str
+}
+
+// IDCODE is a JTAG device identification register value.
+type IDCODE uint32
+
+// Version returns the 4-bit part version.
+func (id IDCODE) Version() uint8 { return uint8(id >> 28) }
+
+// Part returns the 16-bit part number.
+func (id IDCODE) Part() uint16 { return uint16(id >> 12) }
+
+// Manufacturer returns the JEP106 continuation code count (bank) and
+// identity code of the manufacturer.
+func (id IDCODE) Manufacturer() (bank, code uint8) {
+	return uint8(id>>8) & 0xf, uint8(id>>1) & 0x7f
+}
+
+func (id IDCODE) String() string {
+	bank, code := id.Manufacturer()
+	return "idcode{ver=" + strconv.Itoa(int(id.Version())) +
+		" part=0x" + strconv.FormatUint(uint64(id.Part()), 16) +
+		" mfr=" + strconv.Itoa(int(bank)) + ":0x" + strconv.FormatUint(uint64(code), 16) + "}"
+}
+
+// ScanJTAG is a Shift-IR or Shift-DR scan. TDI and TDO hold the shifted bits
+// packed least significant (first shifted) bit first.
+type ScanJTAG struct {
+	Interval
+	// IR is set for instruction register scans.
+	IR bool
+	// Length is the number of bits shifted.
+	Length int
+	TDI    []byte
+	TDO    []byte
+	// Instruction is the least significant 64 bits of the last IR scan,
+	// valid for DR scans after an IR scan.
+	Instruction uint64
+	// IDCODEs are the device identification codes read from TDO on a DR scan
+	// after a TAP reset or with the IDCODE instruction loaded, in chain order
+	// starting from the device closest to TDO. Devices in BYPASS are skipped.
+	IDCODEs []IDCODE
+}
+
+// bits returns n bits of v starting at bit i, first shifted bit least significant.
+func (s ScanJTAG) bits(v []byte, i, n int) (w uint64) {
+	for j := 0; j < n; j++ {
+		w |= uint64(v[(i+j)/8]>>((i+j)%8)&1) << j
+	}
+	return w
+}
+
+// decodeIDCODEs walks the TDO bits of a DR scan. Devices holding IDCODE shift
+// out a 32-bit value with its least significant bit set, devices in BYPASS a
+// single zero. An all ones value marks the end of the chain.
+func (s *ScanJTAG) decodeIDCODEs() {
+	for i := 0; i < s.Length; {
+		if s.bits(s.TDO, i, 1) == 0 {
+			i++
+			continue
+		}
+		if i+32 > s.Length {
+			break
+		}
+		id := IDCODE(s.bits(s.TDO, i, 32))
+		if id == 0xffffffff {
+			break
+		}
+		s.IDCODEs = append(s.IDCODEs, id)
+		i += 32
+	}
+}
+
+// JTAG can be used to analyze the JTAG TAP controller signals. TMS, TDI and
+// TDO are sampled on the rising edge of TCK.
+type JTAG struct {
+	// InitialState is the TAP controller state at the start of the capture.
+	InitialState TAPState
+	// IDCODEInstruction is the instruction register value that selects the
+	// IDCODE register. Zero decodes IDCODEs only on DR scans following a TAP reset.
+	IDCODEInstruction uint64
+}
+
+// Scan decodes all Shift-IR and Shift-DR scans. trst is optional and is active low.
+func (j *JTAG) Scan(tck, tms, tdi, tdo, trst *saleae.DigitalFile) (scans []ScanJTAG, err error) {
+	if tck == nil || tms == nil || tdi == nil || tdo == nil {
+		return nil, errors.New("jtag: got nil digital file")
+	}
+	if j.InitialState > TAPUpdateIR {
+		return nil, errors.New("jtag: invalid initial state")
+	}
+	var (
+		clk        = newSignal(tck)
+		mode       = newSignal(tms)
+		in         = newSignal(tdi)
+		out        = newSignal(tdo)
+		state      = j.InitialState
+		t, last    = math.Inf(-1), math.Inf(-1)
+		scan       *ScanJTAG
+		instr      uint64
+		hasInstr   bool
+		afterReset = state == TAPTestLogicReset
+	)
+	for {
+		t = clk.nextTo(t, true)
+		if math.IsInf(t, 1) {
+			break
+		}
+		if trst != nil && (!levelAt(trst, t) || edgeToAfter(trst, last, false) < t) {
+			state, scan = TAPTestLogicReset, nil
+		}
+		last = t
+		if state == TAPTestLogicReset {
+			afterReset, hasInstr, instr = true, false, 0
+		}
+		if state == TAPShiftDR || state == TAPShiftIR {
+			if scan == nil {
+				scans = append(scans, ScanJTAG{IR: state == TAPShiftIR, Instruction: instr})
+				scan = &scans[len(scans)-1]
+				scan.start = t
+			}
+			if scan.Length%8 == 0 {
+				scan.TDI = append(scan.TDI, 0)
+				scan.TDO = append(scan.TDO, 0)
+			}
+			scan.TDI[scan.Length/8] |= b2u8(in.at(t)) << (scan.Length % 8)
+			scan.TDO[scan.Length/8] |= b2u8(out.at(t)) << (scan.Length % 8)
+			scan.Length++
+			scan.end = t
+		}
+		state = state.Next(mode.at(t))
+		switch {
+		case state == TAPTestLogicReset:
+			scan = nil
+		case scan != nil && (state == TAPUpdateDR || state == TAPUpdateIR):
+			// Scans paused in Pause-xR continue until Update-xR.
+			if scan.IR {
+				instr, hasInstr = scan.bits(scan.TDI, 0, scanBits64(scan.Length)), true
+				afterReset = false
+			} else if afterReset && !hasInstr || j.IDCODEInstruction != 0 && hasInstr && instr == j.IDCODEInstruction {
+				scan.decodeIDCODEs()
+			}
+			scan = nil
+		}
+	}
+	return scans, nil
+}
+
+func scanBits64(n int) int {
+	if n > 64 {
+		return 64
+	}
+	return n
+}
+
+func init() {
+	Register("JTAG", func() Analyzer { return &JTAG{} })
+}
+
+// Channels implements Analyzer.
+func (*JTAG) Channels() []Channel {
+	return []Channel{{Name: "tck"}, {Name: "tms"}, {Name: "tdi"}, {Name: "tdo"}, {Name: "trst", Optional: true}}
+}
+
+// Settings implements Analyzer.
+func (j *JTAG) Settings() []Setting {
+	return []Setting{
+		{Name: "state", Usage: "TAP state at capture start", Value: j.InitialState.String()},
+		{Name: "idcode", Usage: "IDCODE instruction, 0 decodes IDCODEs after reset only", Value: "0x" + strconv.FormatUint(j.IDCODEInstruction, 16)},
+	}
+}
+
+// Set implements Analyzer.
+func (j *JTAG) Set(name, value string) (err error) {
+	switch name {
+	case "state":
+		for s := TAPTestLogicReset; s <= TAPUpdateIR; s++ {
+			if s.String() == value {
+				j.InitialState = s
+				return nil
+			}
+		}
+		err = errors.New("jtag: unknown TAP state " + strconv.Quote(value))
+	case "idcode":
+		j.IDCODEInstruction, err = strconv.ParseUint(value, 0, 64)
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Each scan is returned as a frame of type "ir" or "dr"
+// with its "length" and shifted "tdi" and "tdo" bits. DR scans carry decoded "idcodes".
+func (j *JTAG) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(j, channels)
+	if err != nil {
+		return nil, err
+	}
+	scans, err := j.Scan(in[0], in[1], in[2], in[3], in[4])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(scans))
+	for i, s := range scans {
+		frames[i] = Frame{
+			Interval: s.Interval,
+			Type:     "dr",
+			Data:     map[string]any{"length": s.Length, "tdi": s.TDI, "tdo": s.TDO},
+		}
+		if s.IR {
+			frames[i].Type = "ir"
+		} else {
+			frames[i].Data["idcodes"] = s.IDCODEs
+		}
+	}
+	return frames, nil
+}
@@ -0,0 +1,100 @@
+package analyzers
+
+import "testing"
+
+// jtagWave builds TCK, TMS, TDI, TDO and TRST bit strings, one TCK cycle per
+// call to cycle.
+type jtagWave struct {
+	clockWave
+}
+
+func (w *jtagWave) cycle(tms, tdi, tdo, trst bool) {
+	w.clockWave.cycle(tms, tdi, tdo, trst)
+}
+
+// shift moves from Run-Test/Idle through a scan of n bits back to Run-Test/Idle.
+// If pause is positive the scan is paused after that many bits.
+func (w *jtagWave) shift(ir bool, n, pause int, tdi, tdo uint64) {
+	w.cycle(true, false, false, true) // Select-DR-Scan.
+	if ir {
+		w.cycle(true, false, false, true) // Select-IR-Scan.
+	}
+	w.cycle(false, false, false, true) // Capture.
+	w.cycle(false, false, false, true) // Shift.
+	for i := 0; i < n; i++ {
+		if i > 0 && i == pause {
+			w.cycle(false, false, false, true) // Pause.
+			w.cycle(true, false, false, true)  // Exit2.
+			w.cycle(false, false, false, true) // Shift.
+		}
+		last := i == n-1 || i == pause-1
+		w.cycle(last, tdi>>i&1 != 0, i >= 64 || tdo>>i&1 != 0, true)
+	}
+	w.cycle(true, false, false, true)  // Update.
+	w.cycle(false, false, false, true) // Run-Test/Idle.
+}
+
+func TestJTAG(t *testing.T) {
+	const (
+		idcode = 0x4ba00477
+		instr  = 0xe
+	)
+	var w jtagWave
+	for i := 0; i < 5; i++ {
+		w.cycle(true, false, false, true)
+	}
+	w.cycle(false, false, false, true)
+	// IDCODE of the first device followed by a device in BYPASS and TDI ones.
+	w.shift(false, 64, 0, 0, idcode|0xfffffffe<<32)
+	w.shift(true, 4, 2, instr, 0b0001)
+	w.shift(false, 32, 0, 0, idcode)
+	w.shift(false, 8, 0, 0xa5, 0x5a)
+	w.cycle(false, false, false, false) // TRST.
+	w.cycle(false, false, false, true)
+	w.shift(false, 33, 0, 0, idcode)
+
+	const period = 1e-6
+	jtag := JTAG{IDCODEInstruction: instr}
+	scans, err := jtag.Scan(
+		digitalFromBits(w.clk.String(), period/2),
+		digitalFromBits(w.line(0), period/2),
+		digitalFromBits(w.line(1), period/2),
+		digitalFromBits(w.line(2), period/2),
+		digitalFromBits(w.line(3), period/2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		ir      bool
+		length  int
+		tdi     uint64
+		idcodes int
+	}{
+		{length: 64, idcodes: 1},
+		{ir: true, length: 4, tdi: instr},
+		{length: 32, idcodes: 1},
+		{length: 8, tdi: 0xa5},
+		{length: 33, idcodes: 1},
+	}
+	if len(scans) != len(want) {
+		t.Fatalf("expected %d scans, got %d: %+v", len(want), len(scans), scans)
+	}
+	for i, s := range scans {
+		w := want[i]
+		if s.IR != w.ir || s.Length != w.length || s.bits(s.TDI, 0, scanBits64(s.Length)) != w.tdi || len(s.IDCODEs) != w.idcodes {
+			t.Errorf("scan %d: expected %+v, got %+v", i, w, s)
+		}
+		for _, id := range s.IDCODEs {
+			if id != idcode {
+				t.Errorf("scan %d: expected idcode %#x, got %#x", i, idcode, uint32(id))
+			}
+		}
+	}
+	if scans[3].Instruction != instr {
+		t.Errorf("expected instruction %#x, got %#x", instr, scans[3].Instruction)
+	}
+	if id := IDCODE(idcode); id.String() != "idcode{ver=4 part=0xba00 mfr=4:0x3b}" {
+		t.Errorf("unexpected IDCODE string %s", id)
+	}
+}
@@ -0,0 +1,314 @@
+package analyzers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/soypat/saleae"
+)
+
+// LINChecksum is the LIN checksum model.
+type LINChecksum uint8
+
+const (
+	// LINChecksumAuto accepts enhanced and classic checksums. Diagnostic frames
+	// with IDs 0x3c and 0x3d always use the classic checksum.
+	LINChecksumAuto LINChecksum = iota
+	// LINChecksumClassic covers the data bytes only as used by LIN 1.x.
+	LINChecksumClassic
+	// LINChecksumEnhanced covers the protected identifier and data bytes as used by LIN 2.x.
+	LINChecksumEnhanced
+)
+
+func (c LINChecksum) String() (s string) {
+	switch c {
+	case LINChecksumAuto:
+		s = "auto"
+	case LINChecksumClassic:
+		s = "classic"
+	case LINChecksumEnhanced:
+		s = "enhanced"
+	default:
+		s = "unknown"
+	}
+	return s
+}
+
+var (
+	errLINBreak      = errors.New("lin: break field shorter than 13 bits")
+	errLINSync       = errors.New("lin: sync byte is not 0x55")
+	errLINParity     = errors.New("lin: protected identifier parity error")
+	errLINFraming    = errors.New("lin: framing error")
+	errLINNoResponse = errors.New("lin: no response")
+	errLINChecksum   = errors.New("lin: checksum mismatch")
+)
+
+// linBreakDetect is the minimum dominant time in bits detected as a break field.
+const linBreakDetect = 11
+
+// FrameLIN is a LIN frame consisting of a header sent by the commander and
+// an optional response.
+type FrameLIN struct {
+	Interval
+	// BaudRate measured from the sync byte.
+	BaudRate float64
+	// BreakBits is the length of the break field in bits.
+	BreakBits float64
+	// PID is the protected identifier. The frame ID is in the 6 least significant bits.
+	PID uint8
+	// Data is the response data without the checksum.
+	Data     []byte
+	Checksum byte
+	// Enhanced is set if the response used the enhanced checksum.
+	Enhanced bool
+	// HeaderErr is non-nil if the break, sync or protected identifier were malformed.
+	HeaderErr error
+	// ResponseErr is non-nil if the response was missing, malformed or had a bad checksum.
+	ResponseErr error
+	timings     []Interval
+}
+
+// ID returns the 6-bit frame identifier.
+func (f FrameLIN) ID() uint8 { return f.PID & 0x3f }
+
+// ByteInterval returns the interval during which the i'th data byte was transferred.
+func (f FrameLIN) ByteInterval(i int) Interval {
+	return f.timings[i]
+}
+
+// LINParity returns the protected identifier for the frame identifier id.
+func LINParity(id uint8) uint8 {
+	bit := func(n uint) uint8 { return id >> n & 1 }
+	p0 := bit(0) ^ bit(1) ^ bit(2) ^ bit(4)
+	p1 := ^(bit(1) ^ bit(3) ^ bit(4) ^ bit(5)) & 1
+	return id&0x3f | p0<<6 | p1<<7
+}
+
+// LINChecksumOf returns the LIN checksum of data. For enhanced
+// checksums the protected identifier pid is included.
+func LINChecksumOf(pid uint8, data []byte, enhanced bool) byte {
+	var sum uint16
+	if enhanced {
+		sum = uint16(pid)
+	}
+	for _, b := range data {
+		sum += uint16(b)
+		if sum > 0xff {
+			sum -= 0xff
+		}
+	}
+	return ^byte(sum)
+}
+
+// LIN can be used to analyze a LIN bus. The baud rate of each frame is
+// measured from its sync byte.
+type LIN struct {
+	// BaudRate used to decode the frame bytes. Zero uses the rate measured from each sync byte.
+	BaudRate float64
+	Checksum LINChecksum
+}
+
+// linByte reads a 8N1 byte from rx whose start bit begins at start.
+func linByte(rx *saleae.DigitalFile, start, tb float64) (b byte, framingErr bool) {
+	if levelAt(rx, start+tb/2) {
+		return 0, true
+	}
+	for i := 0; i < 8; i++ {
+		if levelAt(rx, start+(float64(i)+1.5)*tb) {
+			b |= 1 << i
+		}
+	}
+	return b, !levelAt(rx, start+9.5*tb)
+}
+
+// Scan decodes all LIN frames found on rx.
+func (l *LIN) Scan(rx *saleae.DigitalFile) (frames []FrameLIN, err error) {
+	if rx == nil {
+		return nil, errors.New("lin: got nil digital file")
+	}
+	t := math.Inf(-1)
+	for {
+		f, ok := l.decode(rx, t)
+		if !ok {
+			break
+		}
+		frames = append(frames, f)
+		t = f.end
+	}
+	return frames, nil
+}
+
+// breakAt returns the sync byte start and measured bit time if a break field
+// starts at the falling edge fall.
+func (l *LIN) breakAt(rx *saleae.DigitalFile, fall float64) (syncStart, tb float64, ok bool) {
+	rise := edgeToAfter(rx, fall, true)
+	syncStart = edgeToAfter(rx, rise, false)
+	// The sync byte 0x55 has falling edges at the start bit and bits 1, 3, 5 and 7.
+	var falls [5]float64
+	falls[0] = syncStart
+	for i := 1; i < len(falls); i++ {
+		falls[i] = edgeToAfter(rx, falls[i-1], false)
+	}
+	if math.IsInf(falls[4], 1) {
+		return 0, 0, false
+	}
+	tb = (falls[4] - falls[0]) / 8
+	for i := 1; i < len(falls); i++ {
+		if d := falls[i] - falls[i-1]; d < 1.5*tb || d > 2.5*tb {
+			return 0, 0, false // Not a sync byte.
+		}
+	}
+	if l.BaudRate > 0 {
+		tb = 1 / l.BaudRate
+	}
+	return syncStart, tb, rise-fall >= linBreakDetect*tb
+}
+
+// decode decodes the first frame whose break field starts after t.
+func (l *LIN) decode(rx *saleae.DigitalFile, t float64) (f FrameLIN, ok bool) {
+	var syncStart, tb float64
+	fall := t
+	for !ok {
+		fall = edgeToAfter(rx, fall, false)
+		if math.IsInf(fall, 1) {
+			return f, false
+		}
+		syncStart, tb, ok = l.breakAt(rx, fall)
+	}
+	rise := edgeToAfter(rx, fall, true)
+	f.start = fall
+	f.BreakBits = (rise - fall) / tb
+	f.BaudRate = 1 / tb
+	if f.BreakBits < 13 {
+		f.HeaderErr = errLINBreak
+	}
+	sync, framing := linByte(rx, syncStart, tb)
+	if (sync != 0x55 || framing) && f.HeaderErr == nil {
+		f.HeaderErr = errLINSync
+	}
+	pidStart := edgeToAfter(rx, syncStart+9.5*tb, false)
+	f.end = syncStart + 10*tb
+	if math.IsInf(pidStart, 1) {
+		f.HeaderErr = errLINFraming
+		return f, true
+	}
+	f.PID, framing = linByte(rx, pidStart, tb)
+	f.end = pidStart + 10*tb
+	switch {
+	case f.HeaderErr != nil:
+	case framing:
+		f.HeaderErr = errLINFraming
+	case LINParity(f.ID()) != f.PID:
+		f.HeaderErr = errLINParity
+	}
+	// Response bytes follow until the next break field, at most 8 data bytes and checksum.
+	var response []byte
+	next := pidStart + 9.5*tb
+	for len(response) < 9 {
+		start := edgeToAfter(rx, next, false)
+		if math.IsInf(start, 1) {
+			break
+		}
+		if _, _, isBreak := l.breakAt(rx, start); isBreak {
+			break
+		}
+		b, framing := linByte(rx, start, tb)
+		if framing && f.ResponseErr == nil {
+			f.ResponseErr = errLINFraming
+		}
+		response = append(response, b)
+		f.timings = append(f.timings, Interval{start: start, end: start + 10*tb})
+		next = start + 9.5*tb
+		f.end = start + 10*tb
+	}
+	if len(response) < 2 {
+		// A response has at least one data byte and the checksum.
+		f.ResponseErr = errLINNoResponse
+		f.Data = response
+		return f, true
+	}
+	f.Data = response[:len(response)-1]
+	f.Checksum = response[len(response)-1]
+	f.timings = f.timings[:len(f.Data)]
+	classic := LINChecksumOf(f.PID, f.Data, false) == f.Checksum
+	enhanced := LINChecksumOf(f.PID, f.Data, true) == f.Checksum
+	f.Enhanced = enhanced
+	switch {
+	case f.ResponseErr != nil:
+	case l.Checksum == LINChecksumClassic && !classic,
+		l.Checksum == LINChecksumEnhanced && !enhanced,
+		l.Checksum == LINChecksumAuto && !enhanced && !classic,
+		l.Checksum == LINChecksumAuto && f.ID() >= 0x3c && f.ID() <= 0x3d && !classic:
+		f.ResponseErr = errLINChecksum
+	}
+	return f, true
+}
+
+func init() {
+	Register("LIN", func() Analyzer { return &LIN{} })
+}
+
+// Channels implements Analyzer.
+func (*LIN) Channels() []Channel { return []Channel{{Name: "rx"}} }
+
+// Settings implements Analyzer.
+func (l *LIN) Settings() []Setting {
+	return []Setting{
+		{Name: "baud", Usage: "baud rate, 0 measures it from each sync byte", Value: formatFloat(l.BaudRate)},
+		{Name: "checksum", Usage: "checksum model: auto, classic or enhanced", Value: l.Checksum.String()},
+	}
+}
+
+// Set implements Analyzer.
+func (l *LIN) Set(name, value string) (err error) {
+	switch name {
+	case "baud":
+		l.BaudRate, err = strconv.ParseFloat(value, 64)
+	case "checksum":
+		for c := LINChecksumAuto; c <= LINChecksumEnhanced; c++ {
+			if c.String() == value {
+				l.Checksum = c
+				return nil
+			}
+		}
+		err = errors.New("lin: unknown checksum model " + strconv.Quote(value))
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Each LIN frame is returned as a frame of type "frame"
+// with the frame "id", "pid", "data", "checksum" and measured "baud".
+func (l *LIN) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(l, channels)
+	if err != nil {
+		return nil, err
+	}
+	lframes, err := l.Scan(in[0])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(lframes))
+	for i, lf := range lframes {
+		ferr := lf.HeaderErr
+		if ferr == nil {
+			ferr = lf.ResponseErr
+		}
+		frames[i] = Frame{
+			Interval: lf.Interval,
+			Type:     "frame",
+			Data: map[string]any{
+				"id":       lf.ID(),
+				"pid":      lf.PID,
+				"data":     lf.Data,
+				"checksum": lf.Checksum,
+				"enhanced": lf.Enhanced,
+				"baud":     lf.BaudRate,
+			},
+			Err: ferr,
+		}
+	}
+	return frames, nil
+}
@@ -0,0 +1,82 @@
+package analyzers
+
+import (
+	"strings"
+	"testing"
+)
+
+// linBits returns the 8N1 bit string of data bytes.
+func linBits(data ...byte) string {
+	var sb strings.Builder
+	for _, b := range data {
+		sb.WriteByte('0')
+		for i := 0; i < 8; i++ {
+			sb.WriteByte('0' + b>>i&1)
+		}
+		sb.WriteByte('1')
+	}
+	return sb.String()
+}
+
+func linHeader(breakBits int, id uint8) string {
+	return strings.Repeat("0", breakBits) + "1" + linBits(0x55, LINParity(id))
+}
+
+func TestLINParity(t *testing.T) {
+	// Reference protected identifiers from the LIN specification.
+	for id, pid := range map[uint8]uint8{0x00: 0x80, 0x01: 0xc1, 0x3c: 0x3c, 0x3d: 0x7d, 0x10: 0x50} {
+		if got := LINParity(id); got != pid {
+			t.Errorf("id %#x: got PID %#x, want %#x", id, got, pid)
+		}
+	}
+}
+
+func TestLIN(t *testing.T) {
+	data := []byte{0x12, 0x34, 0x00, 0xff}
+	pid := LINParity(0x10)
+	bits := strings.Repeat("1", 20) +
+		// Enhanced checksum frame.
+		linHeader(13, 0x10) + "111" + linBits(data...) + linBits(LINChecksumOf(pid, data, true)) + strings.Repeat("1", 30) +
+		// Diagnostic frame with classic checksum.
+		linHeader(14, 0x3c) + linBits(data...) + linBits(LINChecksumOf(0x3c, data, false)) + strings.Repeat("1", 30) +
+		// Header without response followed by a short break and bad checksum.
+		linHeader(13, 0x20) + strings.Repeat("1", 40) +
+		linHeader(11, 0x10) + linBits(0x01, 0x02) + strings.Repeat("1", 30) +
+		// Bad parity.
+		strings.Repeat("0", 13) + "1" + linBits(0x55, 0x10) + linBits(0x01, 0xfe) + strings.Repeat("1", 30)
+	const baud = 19200
+	rx := digitalFromBits(bits, 1/(baud*0.98))
+	var l LIN
+	frames, err := l.Scan(rx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		id       uint8
+		n        int
+		enhanced bool
+		hdrErr   error
+		respErr  error
+	}{
+		{id: 0x10, n: 4, enhanced: true},
+		{id: 0x3c, n: 4},
+		{id: 0x20, respErr: errLINNoResponse},
+		{id: 0x10, n: 1, hdrErr: errLINBreak, respErr: errLINChecksum},
+		{id: 0x10, n: 1, hdrErr: errLINParity},
+	}
+	if len(frames) != len(want) {
+		t.Fatalf("got %d frames, want %d: %+v", len(frames), len(want), frames)
+	}
+	for i, f := range frames {
+		w := want[i]
+		if f.ID() != w.id || len(f.Data) != w.n || f.HeaderErr != w.hdrErr || f.ResponseErr != w.respErr || (w.n > 0 && f.Enhanced != w.enhanced) {
+			t.Errorf("frame %d: got %+v, want %+v", i, f, w)
+		}
+		if f.BaudRate < baud*0.97 || f.BaudRate > baud*0.99 {
+			t.Errorf("frame %d: got baud rate %v", i, f.BaudRate)
+		}
+	}
+	if string(frames[0].Data) != string(data) {
+		t.Errorf("got data %x", frames[0].Data)
+	}
+}
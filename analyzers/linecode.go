@@ -0,0 +1,263 @@
+package analyzers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/soypat/saleae"
+)
+
+// Coding is a self-clocking line code.
+type Coding uint8
+
+const (
+	// CodingManchesterIEEE is Manchester as in IEEE 802.3. A one is a
+	// low to high transition at mid-bit, a zero a high to low transition.
+	CodingManchesterIEEE Coding = iota
+	// CodingManchesterThomas is Manchester as in G.E. Thomas. A one is a
+	// high to low transition at mid-bit, a zero a low to high transition.
+	CodingManchesterThomas
+	// CodingDiffManchester has a transition at every mid-bit. A zero has an
+	// additional transition at the start of the bit.
+	CodingDiffManchester
+	// CodingBiphaseMark has a transition at the start of every bit. A one has
+	// an additional transition at mid-bit.
+	CodingBiphaseMark
+)
+
+func (c Coding) String() (s string) {
+	switch c {
+	case CodingManchesterIEEE:
+		s = "ieee"
+	case CodingManchesterThomas:
+		s = "thomas"
+	case CodingDiffManchester:
+		s = "diff"
+	case CodingBiphaseMark:
+		s = "bmc"
+	default:
+		s = "unknown"
+	}
+	return s
+}
+
+var errLineCodeViolation = errors.New("linecode: coding violation")
+
+// BitLineCode is a bit recovered from a line coded signal.
+type BitLineCode struct {
+	Interval
+	Value bool
+	// Start is set for the first bit after an idle line or a coding violation.
+	Start bool
+	// Violation is set if the expected clock transition is missing or out of
+	// place. The interval spans from the last clock transition to the offending
+	// transition and Value is meaningless.
+	Violation bool
+}
+
+// LineCode can be used to recover the bitstream of Manchester, differential
+// Manchester and biphase mark coded signals. The bit clock is recovered from
+// the transition guaranteed every bit, the mid-bit transition for Manchester
+// codes and the bit boundary transition for biphase mark.
+type LineCode struct {
+	Coding Coding
+	// BitPeriod in seconds. Zero detects it with DetectLineCodePeriod.
+	BitPeriod float64
+	// Tolerance is the maximum deviation of a transition from its expected
+	// time as a fraction of the bit period. Zero means 0.2.
+	Tolerance float64
+}
+
+// lineCodeIdleBits is the transition free time in bit periods that ends a burst.
+const lineCodeIdleBits = 2
+
+// DetectLineCodePeriod estimates the bit period of a line coded signal from its
+// shortest pulse cluster, which is taken to be half a bit. Signals whose pulses
+// are all one bit long, such as biphase mark coded zeros, are ambiguous and
+// need the bit period set explicitly.
+func DetectLineCodePeriod(d *saleae.DigitalFile) (float64, error) {
+	if d == nil {
+		return 0, errors.New("linecode: got nil digital file")
+	}
+	if len(d.Data) < 4 {
+		return 0, errors.New("linecode: not enough transitions to detect bit period")
+	}
+	widths := make([]float64, len(d.Data)-1)
+	for i := range widths {
+		widths[i] = d.Data[i+1] - d.Data[i]
+	}
+	if half := shortestPulseCluster(widths, 1.3); half > 0 {
+		return 2 * half, nil
+	}
+	return 0, errors.New("linecode: no pulse cluster found")
+}
+
+// Scan recovers all bits found on d.
+func (lc *LineCode) Scan(d *saleae.DigitalFile) (bits []BitLineCode, err error) {
+	if d == nil {
+		return nil, errors.New("linecode: got nil digital file")
+	}
+	if lc.Coding > CodingBiphaseMark {
+		return nil, errors.New("linecode: invalid coding")
+	}
+	T := lc.BitPeriod
+	if T == 0 {
+		T, err = DetectLineCodePeriod(d)
+		if err != nil {
+			return nil, err
+		}
+	}
+	tol := lc.Tolerance
+	if tol == 0 {
+		tol = 0.2
+	}
+	if T < 0 || tol < 0 || tol >= 0.25 {
+		return nil, errors.New("linecode: bit period must be positive and tolerance below 0.25")
+	}
+	// Decode bursts of transitions separated by idle line.
+	e := d.Data
+	for i := 0; i < len(e); {
+		j := i + 1
+		for j < len(e) && e[j]-e[j-1] <= lineCodeIdleBits*T {
+			j++
+		}
+		bits = lc.burst(bits, d, e[i:j], T, tol)
+		i = j
+	}
+	return bits, nil
+}
+
+// burst appends the bits of a burst of transitions e to bits.
+func (lc *LineCode) burst(bits []BitLineCode, d *saleae.DigitalFile, e []float64, T, tol float64) []BitLineCode {
+	near := func(dt, want float64) bool { return math.Abs(dt-want) <= tol*T }
+	manchester := lc.Coding == CodingManchesterIEEE || lc.Coding == CodingManchesterThomas
+	for len(e) > 0 {
+		// Clock transitions are one bit apart. Up to the first bit long interval
+		// transitions alternate between clock and data transitions which fixes
+		// the phase. Without a bit long interval the first transition is taken as clock.
+		k := 0
+		for k+1 < len(e) && near(e[k+1]-e[k], T/2) {
+			k++
+		}
+		first := 0
+		if k+1 < len(e) && near(e[k+1]-e[k], T) {
+			first = k % 2
+		}
+		start := len(bits)
+		emit := func(b BitLineCode) {
+			b.Start = len(bits) == start
+			bits = append(bits, b)
+		}
+		// clock emits the bit ending or centered at clock transition c.
+		clock := func(prev, c float64, mid bool) {
+			var b BitLineCode
+			switch {
+			case manchester:
+				b.Value = levelAt(d, c) == (lc.Coding == CodingManchesterIEEE)
+				b.start, b.end = c-T/2, c+T/2
+			case lc.Coding == CodingDiffManchester:
+				b.Value = !mid
+				b.start, b.end = c-T/2, c+T/2
+			default:
+				if math.IsNaN(prev) {
+					return // Biphase mark bits end at the next clock transition.
+				}
+				b.Value = mid
+				b.start, b.end = prev, c
+			}
+			emit(b)
+		}
+		c := e[first]
+		clock(math.NaN(), c, first == 1)
+		j := first + 1
+		for j < len(e) {
+			dt := e[j] - c
+			switch {
+			case near(dt, T):
+				clock(c, e[j], false)
+				c = e[j]
+				j++
+				continue
+			case near(dt, T/2) && j+1 < len(e) && near(e[j+1]-c, T):
+				clock(c, e[j+1], true)
+				c = e[j+1]
+				j += 2
+				continue
+			case near(dt, T/2) && j+1 == len(e):
+				// Trailing data transition before idle.
+				if lc.Coding == CodingBiphaseMark {
+					emit(BitLineCode{Interval: Interval{start: c, end: c + T}, Value: true})
+				}
+				j++
+				continue
+			}
+			if near(dt, T/2) {
+				j++ // Valid data transition, the clock transition after it is missing.
+			}
+			bits = append(bits, BitLineCode{Interval: Interval{start: c, end: e[j]}, Violation: true})
+			break
+		}
+		e = e[j:]
+	}
+	return bits
+}
+
+func init() {
+	Register("LineCode", func() Analyzer { return &LineCode{} })
+}
+
+// Channels implements Analyzer.
+func (*LineCode) Channels() []Channel { return []Channel{{Name: "data"}} }
+
+// Settings implements Analyzer.
+func (lc *LineCode) Settings() []Setting {
+	return []Setting{
+		{Name: "coding", Usage: "line code: ieee, thomas, diff or bmc", Value: lc.Coding.String()},
+		{Name: "period", Usage: "bit period in seconds, 0 detects it", Value: formatFloat(lc.BitPeriod)},
+		{Name: "tolerance", Usage: "transition time tolerance in bit periods, 0 means 0.2", Value: formatFloat(lc.Tolerance)},
+	}
+}
+
+// Set implements Analyzer.
+func (lc *LineCode) Set(name, value string) (err error) {
+	switch name {
+	case "coding":
+		for c := CodingManchesterIEEE; c <= CodingBiphaseMark; c++ {
+			if c.String() == value {
+				lc.Coding = c
+				return nil
+			}
+		}
+		err = errors.New("linecode: unknown coding " + strconv.Quote(value))
+	case "period":
+		lc.BitPeriod, err = strconv.ParseFloat(value, 64)
+	case "tolerance":
+		lc.Tolerance, err = strconv.ParseFloat(value, 64)
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Each bit is returned as a frame of type "bit" with
+// its "value" and whether it "start"s a burst. Coding violations are returned as
+// frames of type "violation".
+func (lc *LineCode) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(lc, channels)
+	if err != nil {
+		return nil, err
+	}
+	bits, err := lc.Scan(in[0])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(bits))
+	for i, b := range bits {
+		frames[i] = Frame{Interval: b.Interval, Type: "bit", Data: map[string]any{"value": b.Value, "start": b.Start}}
+		if b.Violation {
+			frames[i] = Frame{Interval: b.Interval, Type: "violation", Err: errLineCodeViolation}
+		}
+	}
+	return frames, nil
+}
@@ -0,0 +1,124 @@
+package analyzers
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// lineCodeHalfBits returns the half bit levels of bits encoded with coding
+// starting from a low line.
+func lineCodeHalfBits(coding Coding, bits []bool) string {
+	var sb strings.Builder
+	level := false
+	put := func() {
+		if level {
+			sb.WriteByte('1')
+		} else {
+			sb.WriteByte('0')
+		}
+	}
+	for _, b := range bits {
+		switch coding {
+		case CodingManchesterIEEE, CodingManchesterThomas:
+			level = b != (coding == CodingManchesterIEEE)
+			put()
+			level = !level
+			put()
+		case CodingDiffManchester:
+			if !b {
+				level = !level
+			}
+			put()
+			level = !level
+			put()
+		case CodingBiphaseMark:
+			level = !level
+			put()
+			if b {
+				level = !level
+			}
+			put()
+		}
+	}
+	return sb.String()
+}
+
+func TestLineCode(t *testing.T) {
+	const period = 1e-4
+	rng := rand.New(rand.NewSource(1))
+	for coding := CodingManchesterIEEE; coding <= CodingBiphaseMark; coding++ {
+		frames := [][]bool{make([]bool, 40), make([]bool, 40)}
+		for _, f := range frames {
+			for i := range f {
+				f[i] = rng.Intn(2) == 1
+			}
+			// Start and end with a one so that every coding has a transition
+			// to recover the first and last bit from after idle.
+			f[0], f[len(f)-1] = true, true
+		}
+		idle := "00000000"
+		// Stretch a half bit pulse in the middle of the second frame to 1.5 bits,
+		// a missing clock transition that keeps the following bits in phase.
+		second := lineCodeHalfBits(coding, frames[1])
+		p := len(second) / 2
+		for second[p-1] == second[p] || second[p+1] == second[p] {
+			p++
+		}
+		second = second[:p] + strings.Repeat(second[p:p+1], 2) + second[p:]
+		w := idle + lineCodeHalfBits(coding, frames[0]) + idle + idle + second + idle
+		lc := LineCode{Coding: coding}
+		bits, err := lc.Scan(digitalFromBits(w, period/2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []bool
+		violations, starts := 0, 0
+		for _, b := range bits {
+			if b.Violation {
+				violations++
+				continue
+			}
+			if b.Start {
+				starts++
+			}
+			got = append(got, b.Value)
+		}
+		if violations != 1 || starts != 3 {
+			t.Errorf("%v: expected 1 violation and 3 starts, got %d and %d", coding, violations, starts)
+		}
+		if len(got) < 55 || !equalBools(got[:40], frames[0]) {
+			t.Fatalf("%v: first frame mismatch", coding)
+		}
+		// Bits after the violation are recovered in phase.
+		if !equalBools(got[len(got)-15:], frames[1][25:]) {
+			t.Errorf("%v: second frame tail mismatch", coding)
+		}
+	}
+}
+
+func equalBools(a, b []bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDetectLineCodePeriod(t *testing.T) {
+	bits := make([]bool, 64)
+	for i := range bits {
+		bits[i] = i%3 == 0
+	}
+	T, err := DetectLineCodePeriod(digitalFromBits("00"+lineCodeHalfBits(CodingManchesterIEEE, bits), 5e-6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if T < 0.99e-5 || T > 1.01e-5 {
+		t.Errorf("expected bit period 10µs, got %g", T)
+	}
+}
@@ -0,0 +1,367 @@
+package analyzers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/soypat/saleae"
+)
+
+// MDIOClause is the IEEE 802.3 clause defining the management frame format.
+type MDIOClause uint8
+
+const (
+	// MDIOClause22 frames address up to 32 registers of 32 PHYs.
+	MDIOClause22 MDIOClause = iota
+	// MDIOClause45 frames address 65536 registers in each of 32 MMDs of 32 ports.
+	MDIOClause45
+)
+
+func (c MDIOClause) String() (s string) {
+	switch c {
+	case MDIOClause22:
+		s = "22"
+	case MDIOClause45:
+		s = "45"
+	default:
+		s = "unknown"
+	}
+	return s
+}
+
+// MDIOOp is a management frame operation.
+type MDIOOp uint8
+
+const (
+	MDIOOpWrite MDIOOp = iota
+	MDIOOpRead
+	// MDIOOpAddress sets the register address of a Clause 45 MMD.
+	MDIOOpAddress
+	// MDIOOpReadInc is a Clause 45 read that increments the register address afterwards.
+	MDIOOpReadInc
+)
+
+func (op MDIOOp) String() (s string) {
+	switch op {
+	case MDIOOpWrite:
+		s = "write"
+	case MDIOOpRead:
+		s = "read"
+	case MDIOOpAddress:
+		s = "address"
+	case MDIOOpReadInc:
+		s = "read-inc"
+	default:
+		s = "unknown"
+	}
+	return s
+}
+
+const (
+	mdioPreambleBits = 32
+	// Clause 22 registers used to access MMD registers indirectly.
+	mdioRegMMDControl = 13
+	mdioRegMMDData    = 14
+)
+
+var (
+	errMDIOPreamble   = errors.New("mdio: preamble shorter than minimum")
+	errMDIOOp         = errors.New("mdio: invalid Clause 22 operation")
+	errMDIOTurnaround = errors.New("mdio: invalid turnaround on write")
+	errMDIONoResponse = errors.New("mdio: no PHY response to read")
+	errMDIOShort      = errors.New("mdio: capture ends within frame")
+)
+
+// TxMDIO is a management frame on the MDIO bus.
+type TxMDIO struct {
+	Interval
+	Clause MDIOClause
+	Op     MDIOOp
+	// PHY is the PHYAD of Clause 22 frames and the PRTAD of Clause 45 frames.
+	PHY uint8
+	// Reg is the REGAD of Clause 22 frames and the DEVAD of Clause 45 frames.
+	Reg uint8
+	// Data is the register value, or the register address of Clause 45 address frames.
+	Data uint16
+	// Dev and Addr are the MMD and register accessed by Clause 45 frames and by
+	// Clause 22 frames through the MMD access registers, when HasAddr is set.
+	Dev     uint8
+	Addr    uint16
+	HasAddr bool
+	// Preamble is the number of preamble bits preceding the frame.
+	Preamble int
+	Err      error
+}
+
+// Register returns the IEEE 802.3 name of the accessed register, or an empty
+// string for vendor specific registers and unknown addresses.
+func (tx TxMDIO) Register() string {
+	if tx.HasAddr {
+		return mmdRegisterName(tx.Dev, tx.Addr)
+	}
+	if tx.Clause == MDIOClause22 {
+		return mdioC22Names[tx.Reg&0x1f]
+	}
+	return ""
+}
+
+// mdioC22Names are the Clause 22 basic and extended register names.
+var mdioC22Names = [32]string{
+	0:  "BMCR",
+	1:  "BMSR",
+	2:  "PHYID1",
+	3:  "PHYID2",
+	4:  "ANAR",
+	5:  "ANLPAR",
+	6:  "ANER",
+	7:  "ANNPTR",
+	8:  "ANLPNPR",
+	9:  "CTRL1000",
+	10: "STAT1000",
+	11: "PSE_CTRL",
+	12: "PSE_STAT",
+	13: "MMDCTRL",
+	14: "MMDDATA",
+	15: "ESTATUS",
+}
+
+// mmdNames are the names of MDIO manageable devices by DEVAD.
+var mmdNames = [32]string{
+	1: "PMA/PMD",
+	2: "WIS",
+	3: "PCS",
+	4: "PHY_XS",
+	5: "DTE_XS",
+	6: "TC",
+	7: "AN",
+}
+
+// mmdCommonNames are the registers present in every standard MMD.
+var mmdCommonNames = map[uint16]string{
+	0:  "CTRL1",
+	1:  "STAT1",
+	2:  "DEVID1",
+	3:  "DEVID2",
+	4:  "SPEED",
+	5:  "DEVS1",
+	6:  "DEVS2",
+	8:  "STAT2",
+	14: "PKGID1",
+	15: "PKGID2",
+}
+
+// mmdRegisterNames are device specific register names by DEVAD and address.
+var mmdRegisterNames = map[[2]uint16]string{
+	{1, 7}:  "CTRL2",
+	{1, 11}: "EXTABLE",
+	{3, 7}:  "CTRL2",
+	{3, 20}: "EEE_ABLE",
+	{3, 22}: "EEE_WKERR",
+	{7, 16}: "ADV",
+	{7, 19}: "LPA",
+	{7, 32}: "10GBT_CTRL",
+	{7, 33}: "10GBT_STAT",
+	{7, 60}: "EEE_ADV",
+	{7, 61}: "EEE_LPABLE",
+}
+
+// mmdRegisterName returns the name of register addr of MMD dev, prefixed by the device name.
+func mmdRegisterName(dev uint8, addr uint16) string {
+	devName := mmdNames[dev&0x1f]
+	if devName == "" {
+		return ""
+	}
+	name, ok := mmdRegisterNames[[2]uint16{uint16(dev), addr}]
+	if !ok {
+		name = mmdCommonNames[addr]
+	}
+	if name == "" {
+		return ""
+	}
+	return devName + " " + name
+}
+
+// MDIO can be used to decode Clause 22 and Clause 45 management frames used to
+// configure Ethernet PHYs over MDC and MDIO, also known as SMI. Clause 45 register
+// addresses, including those accessed indirectly through Clause 22 registers 13 and 14,
+// are tracked so that reads and writes are labeled with their register.
+type MDIO struct {
+	// Preamble is the minimum number of preamble bits. Frames with shorter preambles
+	// are decoded and flagged. Zero means 32, set to 1 for PHYs with preamble suppression.
+	Preamble int
+}
+
+// Scan decodes all management frames found on mdc and mdio.
+func (m *MDIO) Scan(mdc, mdio *saleae.DigitalFile) (txs []TxMDIO, err error) {
+	if mdc == nil || mdio == nil {
+		return nil, errors.New("mdio: got nil digital file")
+	}
+	minPreamble := m.Preamble
+	if minPreamble == 0 {
+		minPreamble = mdioPreambleBits
+	}
+	if minPreamble < 0 {
+		return nil, errors.New("mdio: negative preamble length")
+	}
+	var (
+		v     []bool
+		times []float64
+		clk   = newSignal(mdc)
+		dio   = newSignal(mdio)
+		t     = math.Inf(-1)
+	)
+	for {
+		t = clk.nextTo(t, true)
+		if math.IsInf(t, 1) {
+			break
+		}
+		v = append(v, dio.at(t))
+		times = append(times, t)
+	}
+	word := func(i, n int) (w uint16) {
+		for j := 0; j < n; j++ {
+			w = w<<1 | uint16(b2u8(v[i+j]))
+		}
+		return w
+	}
+	var (
+		addrs   = make(map[uint16]uint16) // MMD register addresses by port and device.
+		mmdCtrl [32]uint16                // Clause 22 MMD access control register of each PHY.
+		run     int
+	)
+	for i := 0; i < len(v); i++ {
+		if v[i] {
+			run++
+			continue
+		}
+		if run == 0 {
+			continue // Not preceded by a preamble or idle.
+		}
+		preamble := run
+		run = 0
+		if i+32 > len(v) {
+			tx := TxMDIO{Interval: NewInterval(times[i], times[len(times)-1]), Preamble: preamble, Err: errMDIOShort}
+			txs = append(txs, tx)
+			break
+		}
+		tx := TxMDIO{Interval: NewInterval(times[i], times[i+31]), Preamble: preamble}
+		st, op := word(i, 2), word(i+2, 2)
+		tx.PHY, tx.Reg = uint8(word(i+4, 5)), uint8(word(i+9, 5))
+		ta := word(i+14, 2)
+		tx.Data = word(i+16, 16)
+		if st == 0b01 {
+			tx.Clause = MDIOClause22
+			switch op {
+			case 0b01:
+				tx.Op = MDIOOpWrite
+			case 0b10:
+				tx.Op = MDIOOpRead
+			default:
+				tx.Err = errMDIOOp
+			}
+		} else {
+			tx.Clause = MDIOClause45
+			tx.Op = [4]MDIOOp{MDIOOpAddress, MDIOOpWrite, MDIOOpReadInc, MDIOOpRead}[op]
+		}
+		read := tx.Op == MDIOOpRead || tx.Op == MDIOOpReadInc
+		switch {
+		case tx.Err != nil:
+		case read && ta&1 != 0:
+			tx.Err = errMDIONoResponse
+		case !read && ta != 0b10:
+			tx.Err = errMDIOTurnaround
+		case preamble < minPreamble:
+			tx.Err = errMDIOPreamble
+		}
+		if tx.Err != errMDIOOp && tx.Err != errMDIONoResponse {
+			key := uint16(tx.PHY)<<5 | uint16(tx.Reg)
+			switch {
+			case tx.Clause == MDIOClause45:
+				tx.Dev = tx.Reg
+				if tx.Op == MDIOOpAddress {
+					addrs[key] = tx.Data
+				}
+				tx.Addr, tx.HasAddr = addrs[key]
+				if tx.Op == MDIOOpReadInc && tx.HasAddr {
+					addrs[key]++
+				}
+			case tx.Reg == mdioRegMMDControl && tx.Op == MDIOOpWrite:
+				mmdCtrl[tx.PHY] = tx.Data
+			case tx.Reg == mdioRegMMDData:
+				ctrl := mmdCtrl[tx.PHY]
+				fn, dev := ctrl>>14, uint8(ctrl&0x1f)
+				key = uint16(tx.PHY)<<5 | uint16(dev)
+				if fn == 0 {
+					if tx.Op == MDIOOpWrite {
+						addrs[key] = tx.Data
+					}
+					break
+				}
+				tx.Dev = dev
+				tx.Addr, tx.HasAddr = addrs[key]
+				if tx.HasAddr && (fn == 2 || fn == 3 && tx.Op == MDIOOpWrite) {
+					addrs[key]++
+				}
+			}
+		}
+		txs = append(txs, tx)
+		i += 31
+	}
+	return txs, nil
+}
+
+func init() {
+	Register("MDIO", func() Analyzer { return &MDIO{} })
+}
+
+// Channels implements Analyzer.
+func (*MDIO) Channels() []Channel { return []Channel{{Name: "mdc"}, {Name: "mdio"}} }
+
+// Settings implements Analyzer.
+func (m *MDIO) Settings() []Setting {
+	return []Setting{
+		{Name: "preamble", Usage: "minimum preamble bits, 0 means 32", Value: strconv.Itoa(m.Preamble)},
+	}
+}
+
+// Set implements Analyzer.
+func (m *MDIO) Set(name, value string) (err error) {
+	switch name {
+	case "preamble":
+		m.Preamble, err = strconv.Atoi(value)
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Each management frame is returned as a frame of type
+// "read", "write", "address" or "read-inc" with its "clause", "phy", "reg", "data"
+// and "register" name. Frames accessing MMD registers include the "devad" and "address".
+func (m *MDIO) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(m, channels)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := m.Scan(in[0], in[1])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(txs))
+	for i, tx := range txs {
+		data := map[string]any{
+			"clause":   tx.Clause.String(),
+			"phy":      tx.PHY,
+			"reg":      tx.Reg,
+			"data":     tx.Data,
+			"register": tx.Register(),
+		}
+		if tx.HasAddr {
+			data["devad"] = tx.Dev
+			data["address"] = tx.Addr
+		}
+		frames[i] = Frame{Interval: tx.Interval, Type: tx.Op.String(), Data: data, Err: tx.Err}
+	}
+	return frames, nil
+}
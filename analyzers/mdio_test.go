@@ -0,0 +1,88 @@
+package analyzers
+
+import "testing"
+
+// mdioWave builds MDC and MDIO bit strings, one MDC cycle per bit.
+type mdioWave struct {
+	clockWave
+}
+
+func (w *mdioWave) bits(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.cycle(v>>i&1 != 0)
+	}
+}
+
+// frame sends a management frame. ta is the turnaround as sampled on the bus,
+// 0b10 for writes and reads answered by the PHY.
+func (w *mdioWave) frame(preamble int, st, op uint32, phy, reg uint8, ta uint32, data uint16) {
+	for i := 0; i < preamble; i++ {
+		w.bits(1, 1)
+	}
+	w.bits(st, 2)
+	w.bits(op, 2)
+	w.bits(uint32(phy), 5)
+	w.bits(uint32(reg), 5)
+	w.bits(ta, 2)
+	w.bits(uint32(data), 16)
+}
+
+func TestMDIO(t *testing.T) {
+	const (
+		c22, c45          = 0b01, 0b00
+		c22Write, c22Read = 0b01, 0b10
+		c45Addr, c45Write = 0b00, 0b01
+		c45Read, c45Inc   = 0b11, 0b10
+	)
+	var w mdioWave
+	w.frame(32, c22, c22Read, 1, 2, 0b10, 0x0022)
+	w.frame(32, c22, c22Write, 1, 0, 0b10, 0x1200)
+	w.frame(32, c22, c22Write, 1, 13, 0b10, 0x0007) // MMD 7 address.
+	w.frame(32, c22, c22Write, 1, 14, 0b10, 60)
+	w.frame(32, c22, c22Write, 1, 13, 0b10, 0x4007) // MMD 7 data.
+	w.frame(32, c22, c22Write, 1, 14, 0b10, 0x0006)
+	w.frame(32, c45, c45Addr, 2, 1, 0b10, 0)
+	w.frame(32, c45, c45Inc, 2, 1, 0b10, 0x2040)
+	w.frame(32, c45, c45Read, 2, 1, 0b10, 0x0082)
+	w.frame(32, c45, c45Write, 2, 30, 0b10, 0xbeef)
+	w.frame(32, c22, c22Read, 5, 1, 0b11, 0xffff)
+	w.frame(8, c22, c22Write, 1, 4, 0b10, 0x01e1)
+	w.bits(1, 4)
+
+	var m MDIO
+	txs, err := m.Scan(digitalFromBits(w.clk.String(), 1e-6), digitalFromBits(w.line(0), 1e-6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		clause   MDIOClause
+		op       MDIOOp
+		phy, reg uint8
+		data     uint16
+		register string
+		err      error
+	}{
+		{MDIOClause22, MDIOOpRead, 1, 2, 0x0022, "PHYID1", nil},
+		{MDIOClause22, MDIOOpWrite, 1, 0, 0x1200, "BMCR", nil},
+		{MDIOClause22, MDIOOpWrite, 1, 13, 0x0007, "MMDCTRL", nil},
+		{MDIOClause22, MDIOOpWrite, 1, 14, 60, "MMDDATA", nil},
+		{MDIOClause22, MDIOOpWrite, 1, 13, 0x4007, "MMDCTRL", nil},
+		{MDIOClause22, MDIOOpWrite, 1, 14, 0x0006, "AN EEE_ADV", nil},
+		{MDIOClause45, MDIOOpAddress, 2, 1, 0, "PMA/PMD CTRL1", nil},
+		{MDIOClause45, MDIOOpReadInc, 2, 1, 0x2040, "PMA/PMD CTRL1", nil},
+		{MDIOClause45, MDIOOpRead, 2, 1, 0x0082, "PMA/PMD STAT1", nil},
+		{MDIOClause45, MDIOOpWrite, 2, 30, 0xbeef, "", nil},
+		{MDIOClause22, MDIOOpRead, 5, 1, 0xffff, "BMSR", errMDIONoResponse},
+		{MDIOClause22, MDIOOpWrite, 1, 4, 0x01e1, "ANAR", errMDIOPreamble},
+	}
+	if len(txs) != len(want) {
+		t.Fatalf("expected %d frames, got %d: %+v", len(want), len(txs), txs)
+	}
+	for i, w := range want {
+		tx := txs[i]
+		if tx.Clause != w.clause || tx.Op != w.op || tx.PHY != w.phy || tx.Reg != w.reg ||
+			tx.Data != w.data || tx.Register() != w.register || tx.Err != w.err {
+			t.Errorf("frame %d: expected %+v, got %+v (%q)", i, w, tx, tx.Register())
+		}
+	}
+}
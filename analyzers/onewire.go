@@ -0,0 +1,510 @@
+package analyzers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/soypat/saleae"
+)
+
+// OneWireROMCmd is a 1-Wire ROM command, the first byte sent after a reset.
+type OneWireROMCmd uint8
+
+// 1-Wire ROM commands.
+const (
+	OneWireReadROM        OneWireROMCmd = 0x33
+	OneWireMatchROM       OneWireROMCmd = 0x55
+	OneWireSkipROM        OneWireROMCmd = 0xcc
+	OneWireSearchROM      OneWireROMCmd = 0xf0
+	OneWireAlarmSearch    OneWireROMCmd = 0xec
+	OneWireResume         OneWireROMCmd = 0xa5
+	OneWireOverdriveSkip  OneWireROMCmd = 0x3c
+	OneWireOverdriveMatch OneWireROMCmd = 0x69
+)
+
+func (c OneWireROMCmd) String() (s string) {
+	switch c {
+	case OneWireReadROM:
+		s = "READ ROM"
+	case OneWireMatchROM:
+		s = "MATCH ROM"
+	case OneWireSkipROM:
+		s = "SKIP ROM"
+	case OneWireSearchROM:
+		s = "SEARCH ROM"
+	case OneWireAlarmSearch:
+		s = "ALARM SEARCH"
+	case OneWireResume:
+		s = "RESUME"
+	case OneWireOverdriveSkip:
+		s = "OVERDRIVE SKIP ROM"
+	case OneWireOverdriveMatch:
+		s = "OVERDRIVE MATCH ROM"
+	default:
+		s = "unknown(0x" + strconv.FormatUint(uint64(c), 16) + ")"
+	}
+	return s
+}
+
+// OneWireParam is a 1-Wire bus timing parameter as named in Maxim datasheets.
+type OneWireParam uint8
+
+const (
+	// Reset low time.
+	OneWireTRstL OneWireParam = iota
+	// Reset high time from the end of the reset pulse to the first time slot.
+	OneWireTRstH
+	// Presence detect high time from the end of the reset pulse to the presence pulse.
+	OneWireTPdH
+	// Presence pulse low time.
+	OneWireTPdL
+	// Time slot duration between consecutive slot starts.
+	OneWireTSlot
+	// Recovery time between time slots.
+	OneWireTRec
+	// Low time of write-1 and read slots.
+	OneWireTLow1
+	// Low time of write-0 slots.
+	OneWireTLow0
+	// Read data valid time. The slave holds the bus low in read-0 slots at least
+	// until the master samples it at tRDV, and releases it before the end of the slot.
+	OneWireTRdv
+	numOneWireParams
+)
+
+func (p OneWireParam) String() (s string) {
+	switch p {
+	case OneWireTRstL:
+		s = "tRSTL"
+	case OneWireTRstH:
+		s = "tRSTH"
+	case OneWireTPdH:
+		s = "tPDH"
+	case OneWireTPdL:
+		s = "tPDL"
+	case OneWireTSlot:
+		s = "tSLOT"
+	case OneWireTRec:
+		s = "tREC"
+	case OneWireTLow1:
+		s = "tLOW1"
+	case OneWireTLow0:
+		s = "tLOW0"
+	case OneWireTRdv:
+		s = "tRDV"
+	default:
+		s = "unknown"
+	}
+	return s
+}
+
+// OneWireTimingSpec contains the timing limits of a 1-Wire bus speed in seconds.
+type OneWireTimingSpec struct {
+	Name string
+	// Min contains the minimum value for each parameter.
+	Min [numOneWireParams]float64
+	// Max contains the maximum value for each parameter. Zero means no maximum.
+	// The maximum of tLOW1 also separates 1 bits from 0 bits.
+	Max [numOneWireParams]float64
+}
+
+var (
+	// Standard speed.
+	OneWireStandard = OneWireTimingSpec{
+		Name: "standard",
+		Min: [numOneWireParams]float64{
+			OneWireTRstL: 480e-6, OneWireTRstH: 480e-6, OneWireTPdH: 15e-6, OneWireTPdL: 60e-6,
+			OneWireTSlot: 60e-6, OneWireTRec: 1e-6, OneWireTLow1: 1e-6, OneWireTLow0: 60e-6,
+			OneWireTRdv: 15e-6,
+		},
+		Max: [numOneWireParams]float64{
+			OneWireTPdH: 60e-6, OneWireTPdL: 240e-6, OneWireTLow1: 15e-6, OneWireTLow0: 120e-6,
+			OneWireTRdv: 120e-6,
+		},
+	}
+	// Overdrive speed.
+	OneWireOverdrive = OneWireTimingSpec{
+		Name: "overdrive",
+		Min: [numOneWireParams]float64{
+			OneWireTRstL: 48e-6, OneWireTRstH: 48e-6, OneWireTPdH: 2e-6, OneWireTPdL: 8e-6,
+			OneWireTSlot: 6e-6, OneWireTRec: 1e-6, OneWireTLow1: 1e-6, OneWireTLow0: 6e-6,
+			OneWireTRdv: 2e-6,
+		},
+		Max: [numOneWireParams]float64{
+			OneWireTRstL: 80e-6, OneWireTPdH: 6e-6, OneWireTPdL: 24e-6, OneWireTLow1: 2e-6, OneWireTLow0: 16e-6,
+			OneWireTRdv: 16e-6,
+		},
+	}
+)
+
+// OneWireViolation is a measured 1-Wire timing parameter outside of the specification limits.
+// The interval spans the measured time.
+type OneWireViolation struct {
+	Interval
+	Param OneWireParam
+	// Measured value of the parameter in seconds.
+	Measured float64
+	// Limit that was violated.
+	Limit float64
+	// Set if the measurement exceeded a maximum limit.
+	AboveMax bool
+}
+
+var (
+	errOneWireROMCRC        = errors.New("onewire: ROM ID CRC8 mismatch")
+	errOneWireScratchpadCRC = errors.New("onewire: scratchpad CRC8 mismatch")
+	errOneWireSearch        = errors.New("onewire: no device responded to search")
+	errOneWireTiming        = errors.New("onewire: timing violation")
+)
+
+// oneWireReadScratchpad is the DS18B20 family READ SCRATCHPAD function command.
+const oneWireReadScratchpad = 0xbe
+
+// TxOneWire is a 1-Wire transaction. It begins with a reset pulse and ends
+// at the next reset pulse.
+type TxOneWire struct {
+	Interval
+	// Overdrive is set if the transaction began with an overdrive speed reset.
+	Overdrive bool
+	// Presence is set if a device answered the reset with a presence pulse.
+	Presence  bool
+	ROMCmd    OneWireROMCmd
+	HasROMCmd bool
+	// ROM is the 64-bit ROM ID read, matched or found by search. ROM[0] is the family code.
+	ROM    [8]byte
+	HasROM bool
+	// Data contains the bytes following the ROM command and ROM ID,
+	// usually a function command followed by its data.
+	Data []byte
+	// Violations contains the timing violations found during the transaction.
+	Violations []OneWireViolation
+	// Err is non-nil if a CRC mismatch or timing violation was found.
+	Err     error
+	timings []Interval
+}
+
+// ByteInterval returns the interval during which the i'th data byte was transferred.
+func (t TxOneWire) ByteInterval(i int) Interval {
+	return t.timings[i]
+}
+
+// check records a violation if the parameter measured between start and end is outside spec.
+func (t *TxOneWire) check(spec *OneWireTimingSpec, param OneWireParam, start, end float64) {
+	v := OneWireViolation{Param: param, Measured: end - start}
+	v.start = start
+	v.end = end
+	if min := spec.Min[param]; v.Measured < min {
+		v.Limit = min
+	} else if max := spec.Max[param]; max > 0 && v.Measured > max {
+		v.Limit = max
+		v.AboveMax = true
+	} else {
+		return
+	}
+	t.Violations = append(t.Violations, v)
+	if t.Err == nil {
+		t.Err = errOneWireTiming
+	}
+}
+
+// OneWire can be used to analyze a 1-Wire bus. Speed is switched to overdrive
+// by the overdrive ROM commands and back to standard by a standard speed reset.
+// Slots before the first reset are ignored.
+type OneWire struct {
+	// Overdrive is set if the bus is at overdrive speed at the start of the capture.
+	Overdrive bool
+}
+
+// oneWirePhase is the part of a transaction being decoded.
+type oneWirePhase uint8
+
+const (
+	oneWirePhaseCmd oneWirePhase = iota
+	oneWirePhaseROM
+	oneWirePhaseSearch
+	oneWirePhaseData
+	oneWirePhaseDone
+)
+
+// oneWireDecoder groups the bits of a transaction into ROM command, ROM ID and data.
+type oneWireDecoder struct {
+	tx        *TxOneWire
+	phase     oneWirePhase
+	b         byte
+	nbits     int
+	byteStart float64
+	nrom      int
+	triplet   [3]bool
+}
+
+// oneWireSlot is the direction of a time slot.
+type oneWireSlot uint8
+
+const (
+	oneWireSlotUnknown oneWireSlot = iota
+	oneWireSlotWrite
+	oneWireSlotRead
+)
+
+// slot returns the direction of the next time slot as far as the protocol
+// defines it. Function command data may go either way.
+func (d *oneWireDecoder) slot() oneWireSlot {
+	switch {
+	case d.phase == oneWirePhaseCmd:
+		return oneWireSlotWrite
+	case d.phase == oneWirePhaseROM && d.tx.ROMCmd == OneWireReadROM:
+		return oneWireSlotRead
+	case d.phase == oneWirePhaseROM:
+		return oneWireSlotWrite
+	case d.phase == oneWirePhaseSearch && d.nbits%3 < 2:
+		return oneWireSlotRead
+	case d.phase == oneWirePhaseSearch:
+		return oneWireSlotWrite
+	}
+	return oneWireSlotUnknown
+}
+
+// bit adds a bit transferred in the slot between start and end. It returns true
+// if the bus switches to overdrive speed after the bit.
+func (d *oneWireDecoder) bit(v bool, start, end float64) (overdrive bool) {
+	tx := d.tx
+	switch d.phase {
+	case oneWirePhaseSearch:
+		d.triplet[d.nbits%3] = v
+		d.nbits++
+		if d.nbits%3 != 0 {
+			return false
+		}
+		if d.triplet[0] && d.triplet[1] {
+			if tx.Err == nil {
+				tx.Err = errOneWireSearch
+			}
+			d.phase = oneWirePhaseDone
+			return false
+		}
+		romBit := d.nbits/3 - 1
+		if d.triplet[2] {
+			tx.ROM[romBit/8] |= 1 << (romBit % 8)
+		}
+		if romBit == 63 {
+			d.endROM()
+		}
+		return false
+	case oneWirePhaseDone:
+		return false
+	}
+	if d.nbits == 0 {
+		d.byteStart = start
+	}
+	if v {
+		d.b |= 1 << d.nbits
+	}
+	d.nbits++
+	if d.nbits < 8 {
+		return false
+	}
+	b := d.b
+	d.b, d.nbits = 0, 0
+	switch d.phase {
+	case oneWirePhaseCmd:
+		tx.ROMCmd = OneWireROMCmd(b)
+		tx.HasROMCmd = true
+		d.phase = oneWirePhaseData
+		switch tx.ROMCmd {
+		case OneWireReadROM, OneWireMatchROM, OneWireOverdriveMatch:
+			d.phase = oneWirePhaseROM
+		case OneWireSearchROM, OneWireAlarmSearch:
+			d.phase = oneWirePhaseSearch
+		}
+		return tx.ROMCmd == OneWireOverdriveSkip || tx.ROMCmd == OneWireOverdriveMatch
+	case oneWirePhaseROM:
+		tx.ROM[d.nrom] = b
+		d.nrom++
+		if d.nrom == len(tx.ROM) {
+			d.endROM()
+		}
+	case oneWirePhaseData:
+		tx.Data = append(tx.Data, b)
+		tx.timings = append(tx.timings, Interval{start: d.byteStart, end: end})
+	}
+	return false
+}
+
+// endROM checks the ROM ID CRC once all 64 bits are known.
+func (d *oneWireDecoder) endROM() {
+	d.tx.HasROM = true
+	d.phase = oneWirePhaseData
+	d.nbits = 0
+	if crc8Maxim(d.tx.ROM[:]) != 0 && d.tx.Err == nil {
+		d.tx.Err = errOneWireROMCRC
+	}
+}
+
+// end checks the scratchpad CRC of DS18B20 family READ SCRATCHPAD commands.
+func (d *oneWireDecoder) end() {
+	tx := d.tx
+	if len(tx.Data) >= 10 && tx.Data[0] == oneWireReadScratchpad && crc8Maxim(tx.Data[1:10]) != 0 && tx.Err == nil {
+		tx.Err = errOneWireScratchpadCRC
+	}
+}
+
+// crc8Maxim computes the Dallas/Maxim CRC8 of data. It returns zero if data
+// ends with its own CRC.
+func crc8Maxim(data []byte) (crc uint8) {
+	for _, b := range data {
+		for i := 0; i < 8; i++ {
+			mix := (crc ^ b) & 1
+			crc >>= 1
+			if mix != 0 {
+				crc ^= 0x8c
+			}
+			b >>= 1
+		}
+	}
+	return crc
+}
+
+// Scan decodes all 1-Wire transactions found on the bus.
+func (a *OneWire) Scan(bus *saleae.DigitalFile) (txs []TxOneWire, err error) {
+	if bus == nil {
+		return nil, errors.New("onewire: got nil digital file")
+	}
+	spec := &OneWireStandard
+	if a.Overdrive {
+		spec = &OneWireOverdrive
+	}
+	var (
+		d               = bus.Data
+		i               = 0
+		inTx            bool
+		tx              TxOneWire
+		dec             oneWireDecoder
+		nan             = math.NaN()
+		prevFall        = nan
+		prevRise        = nan
+		resetRise       = nan
+		waitingPresence bool
+	)
+	if bus.Header.InitialState == 0 {
+		i = 1 // Skip the end of a low period which started before the capture.
+	}
+	for ; i+1 < len(d); i += 2 {
+		fall, rise := d[i], d[i+1]
+		low := rise - fall
+		switch {
+		case inTx && waitingPresence && fall-resetRise < spec.Min[OneWireTRstH]:
+			tx.Presence = true
+			tx.check(spec, OneWireTPdH, resetRise, fall)
+			tx.check(spec, OneWireTPdL, fall, rise)
+			tx.end = rise
+			continue
+
+		case low >= OneWireStandard.Min[OneWireTRstL] || low > spec.Max[OneWireTLow0]:
+			// Reset pulse. Resets at standard speed return the bus to standard speed.
+			if low >= OneWireStandard.Min[OneWireTRstL] {
+				spec = &OneWireStandard
+			}
+			if inTx {
+				dec.end()
+				txs = append(txs, tx)
+			}
+			tx = TxOneWire{Overdrive: spec == &OneWireOverdrive}
+			tx.start = fall
+			tx.end = rise
+			tx.check(spec, OneWireTRstL, fall, rise)
+			dec = oneWireDecoder{tx: &tx}
+			inTx = true
+			waitingPresence = true
+			resetRise = rise
+			prevFall, prevRise = nan, nan
+			continue
+
+		case !inTx:
+			continue
+		}
+		if waitingPresence {
+			waitingPresence = false
+			tx.check(spec, OneWireTRstH, resetRise, fall)
+		} else if !math.IsNaN(prevFall) {
+			tx.check(spec, OneWireTSlot, prevFall, fall)
+			tx.check(spec, OneWireTRec, prevRise, fall)
+		}
+		// Read-0 slots are held low by the slave for less than the write-0
+		// minimum, so data slots of unknown direction shorter than it are reads.
+		bit := low <= spec.Max[OneWireTLow1]
+		slot := dec.slot()
+		switch {
+		case bit:
+			tx.check(spec, OneWireTLow1, fall, rise)
+		case slot == oneWireSlotRead || slot == oneWireSlotUnknown && low < spec.Min[OneWireTLow0]:
+			tx.check(spec, OneWireTRdv, fall, rise)
+		default:
+			tx.check(spec, OneWireTLow0, fall, rise)
+		}
+		prevFall, prevRise = fall, rise
+		tx.end = rise
+		if dec.bit(bit, fall, rise) {
+			spec = &OneWireOverdrive
+		}
+	}
+	if inTx {
+		dec.end()
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+func init() {
+	Register("1-Wire", func() Analyzer { return &OneWire{} })
+}
+
+// Channels implements Analyzer.
+func (*OneWire) Channels() []Channel { return []Channel{{Name: "dq"}} }
+
+// Settings implements Analyzer.
+func (a *OneWire) Settings() []Setting {
+	return []Setting{
+		{Name: "overdrive", Usage: "bus is at overdrive speed at start", Value: strconv.FormatBool(a.Overdrive)},
+	}
+}
+
+// Set implements Analyzer.
+func (a *OneWire) Set(name, value string) (err error) {
+	switch name {
+	case "overdrive":
+		a.Overdrive, err = strconv.ParseBool(value)
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Each transaction is returned as a frame of type "reset"
+// with "presence", "overdrive" and the ROM command, ROM ID and data when present.
+func (a *OneWire) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(a, channels)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := a.Scan(in[0])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(txs))
+	for i, tx := range txs {
+		data := map[string]any{
+			"presence":  tx.Presence,
+			"overdrive": tx.Overdrive,
+			"data":      tx.Data,
+		}
+		if tx.HasROMCmd {
+			data["rom_command"] = tx.ROMCmd.String()
+		}
+		if tx.HasROM {
+			data["rom"] = tx.ROM[:]
+		}
+		frames[i] = Frame{Interval: tx.Interval, Type: "reset", Data: data, Err: tx.Err}
+	}
+	return frames, nil
+}
@@ -0,0 +1,139 @@
+package analyzers
+
+import (
+	"math"
+	"testing"
+
+	"github.com/soypat/saleae"
+)
+
+// oneWireBuilder builds a 1-Wire bus waveform from low pulses.
+type oneWireBuilder struct {
+	t    float64
+	spec *OneWireTimingSpec
+	df   saleae.DigitalFile
+}
+
+func (b *oneWireBuilder) low(d, high float64) {
+	b.df.Data = append(b.df.Data, b.t, b.t+d)
+	b.t += d + high
+}
+
+func (b *oneWireBuilder) reset(presence bool) {
+	s := b.spec
+	if !presence {
+		b.low(s.Min[OneWireTRstL]*1.1, s.Min[OneWireTRstH]*1.1)
+		return
+	}
+	pdh := (s.Min[OneWireTPdH] + s.Max[OneWireTPdH]) / 2
+	pdl := (s.Min[OneWireTPdL] + s.Max[OneWireTPdL]) / 2
+	b.low(s.Min[OneWireTRstL]*1.1, pdh)
+	b.low(pdl, s.Min[OneWireTRstH]*1.1-pdh-pdl)
+}
+
+func (b *oneWireBuilder) bit(v bool) {
+	s := b.spec
+	slot := s.Min[OneWireTSlot] * 1.2
+	d := s.Min[OneWireTLow0] * 1.1
+	if v {
+		d = s.Min[OneWireTLow1] * 1.5
+	}
+	b.low(d, math.Max(slot-d, 2*s.Min[OneWireTRec]))
+}
+
+// read adds a read slot. For 0 bits the slave holds the bus low past tRDV.
+func (b *oneWireBuilder) read(v bool) {
+	if v {
+		b.bit(true)
+		return
+	}
+	s := b.spec
+	slot := s.Min[OneWireTSlot] * 1.2
+	d := s.Min[OneWireTRdv] * 2
+	b.low(d, math.Max(slot-d, 2*s.Min[OneWireTRec]))
+}
+
+func (b *oneWireBuilder) readBytes(data ...byte) {
+	for _, v := range data {
+		for i := 0; i < 8; i++ {
+			b.read(v&(1<<i) != 0)
+		}
+	}
+}
+
+func (b *oneWireBuilder) bytes(data ...byte) {
+	for _, v := range data {
+		for i := 0; i < 8; i++ {
+			b.bit(v&(1<<i) != 0)
+		}
+	}
+}
+
+func (b *oneWireBuilder) file() *saleae.DigitalFile {
+	b.df.Header.InitialState = 1
+	b.df.Header.End = b.t
+	b.df.Header.NumTransitions = uint64(len(b.df.Data))
+	return &b.df
+}
+
+func TestOneWire(t *testing.T) {
+	rom := []byte{0x28, 0xff, 0x4c, 0x3b, 0x91, 0x16, 0x03, 0}
+	rom[7] = crc8Maxim(rom[:7])
+	scratch := []byte{0x50, 0x05, 0x4b, 0x46, 0x7f, 0xff, 0x0c, 0x10, 0}
+	scratch[8] = crc8Maxim(scratch[:8])
+	if crc8Maxim(rom) != 0 || crc8Maxim(scratch) != 0 {
+		t.Fatal("bad CRC8 implementation")
+	}
+	b := oneWireBuilder{t: 100e-6, spec: &OneWireStandard}
+	b.reset(true)
+	b.bytes(byte(OneWireReadROM))
+	b.readBytes(rom...)
+	b.reset(true)
+	b.bytes(byte(OneWireMatchROM))
+	b.bytes(rom...)
+	b.bytes(oneWireReadScratchpad)
+	b.readBytes(scratch...)
+	b.reset(true)
+	b.bytes(byte(OneWireSkipROM), oneWireReadScratchpad)
+	b.readBytes(scratch[:8]...)
+	b.readBytes(scratch[8] + 1)
+	b.reset(false)
+	b.bytes(byte(OneWireOverdriveSkip))
+	b.spec = &OneWireOverdrive
+	b.reset(true)
+	b.bytes(byte(OneWireSkipROM), 0x44)
+	b.low(0.5e-6, 10e-6) // Too short slot low time.
+
+	var ow OneWire
+	txs, err := ow.Scan(b.file())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 5 {
+		t.Fatalf("got %d transactions, want 5", len(txs))
+	}
+	if !txs[0].Presence || txs[0].ROMCmd != OneWireReadROM || !txs[0].HasROM || string(txs[0].ROM[:]) != string(rom) || txs[0].Err != nil {
+		t.Errorf("READ ROM: got %+v", txs[0])
+	}
+	if txs[1].ROMCmd != OneWireMatchROM || len(txs[1].Data) != 10 || txs[1].Err != nil {
+		t.Errorf("MATCH ROM: got %+v", txs[1])
+	}
+	if txs[2].Err != errOneWireScratchpadCRC {
+		t.Errorf("expected scratchpad CRC error, got %v", txs[2].Err)
+	}
+	if txs[3].Presence || txs[3].ROMCmd != OneWireOverdriveSkip || txs[3].Overdrive {
+		t.Errorf("OVERDRIVE SKIP ROM: got %+v", txs[3])
+	}
+	od := txs[4]
+	if !od.Overdrive || !od.Presence || od.ROMCmd != OneWireSkipROM || len(od.Data) != 1 || od.Data[0] != 0x44 {
+		t.Errorf("overdrive: got %+v", od)
+	}
+	if len(od.Violations) != 1 || od.Violations[0].Param != OneWireTLow1 || od.Err != errOneWireTiming {
+		t.Errorf("expected tLOW1 violation, got %+v", od.Violations)
+	}
+	for _, tx := range txs[:4] {
+		if len(tx.Violations) != 0 {
+			t.Errorf("unexpected violations %+v", tx.Violations)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package analyzers
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/soypat/saleae"
+)
+
+var (
+	errPWMIrregular = errors.New("pwm: irregular period")
+	errPWMMissing   = errors.New("pwm: missing pulse")
+	errPWMServo     = errors.New("pwm: servo pulse width out of range")
+)
+
+// SamplePWM is a single PWM period measured from a pulse start to the next.
+type SamplePWM struct {
+	Interval
+	Frequency float64
+	// Duty cycle in range 0..1.
+	Duty float64
+	// HighWidth is the active pulse width in seconds.
+	HighWidth float64
+	// Angle is the servo angle in degrees, set if the servo interpretation is enabled.
+	Angle float64
+	// OutOfRange is set if the servo interpretation is enabled and the pulse
+	// width is outside ServoMin..ServoMax.
+	OutOfRange bool
+	// Missing is the number of pulses missing in this period, estimated
+	// from the median period.
+	Missing int
+	// Irregular is set if the period deviates from the median by more than the tolerance.
+	Irregular bool
+}
+
+// PWM can be used to measure pulse width modulated signals period by period.
+type PWM struct {
+	// Inverted is set if pulses are active low.
+	Inverted bool
+	// Tolerance is the maximum relative deviation of a period from the
+	// median period before it is flagged irregular. Zero means 0.1.
+	Tolerance float64
+	// Servo enables the interpretation of pulse widths as servo angles.
+	Servo bool
+	// ServoMin and ServoMax are the pulse widths of the servo end positions
+	// in seconds. Zero means 1ms and 2ms.
+	ServoMin, ServoMax float64
+	// ServoRange is the angle in degrees between end positions. Zero means 180.
+	ServoRange float64
+}
+
+// ServoAngle returns the servo angle in degrees for a pulse width, linearly
+// mapping ServoMin to 0 and ServoMax to ServoRange. Widths outside the range
+// give angles outside it.
+func (p *PWM) ServoAngle(width float64) float64 {
+	lo, hi, rng := p.servoRange()
+	return (width - lo) / (hi - lo) * rng
+}
+
+// servoRange returns the servo end position pulse widths and angle range with defaults applied.
+func (p *PWM) servoRange() (lo, hi, rng float64) {
+	lo, hi, rng = p.ServoMin, p.ServoMax, p.ServoRange
+	if lo == 0 {
+		lo = 1e-3
+	}
+	if hi == 0 {
+		hi = 2e-3
+	}
+	if rng == 0 {
+		rng = 180
+	}
+	return lo, hi, rng
+}
+
+// Scan measures all complete periods found on d.
+func (p *PWM) Scan(d *saleae.DigitalFile) (samples []SamplePWM, err error) {
+	if d == nil {
+		return nil, errors.New("pwm: got nil digital file")
+	}
+	tol := p.Tolerance
+	if tol == 0 {
+		tol = 0.1
+	}
+	if tol < 0 {
+		return nil, errors.New("pwm: negative tolerance")
+	}
+	active := !p.Inverted
+	var starts []int // Indices of pulse start edges.
+	for i, t := range d.Data {
+		if levelAt(d, t) == active {
+			starts = append(starts, i)
+		}
+	}
+	if len(starts) < 2 {
+		return nil, nil
+	}
+	periods := make([]float64, len(starts)-1)
+	for i := range periods {
+		periods[i] = d.Data[starts[i+1]] - d.Data[starts[i]]
+	}
+	sorted := append([]float64(nil), periods...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	samples = make([]SamplePWM, len(periods))
+	for i, period := range periods {
+		s := &samples[i]
+		k := starts[i]
+		s.start, s.end = d.Data[k], d.Data[k]+period
+		s.HighWidth = d.Data[k+1] - d.Data[k]
+		s.Frequency = 1 / period
+		s.Duty = s.HighWidth / period
+		s.Irregular = math.Abs(period-median) > tol*median
+		if n := int(math.Round(period/median)) - 1; n > 0 {
+			s.Missing = n
+		}
+		if p.Servo {
+			_, _, rng := p.servoRange()
+			s.Angle = p.ServoAngle(s.HighWidth)
+			s.OutOfRange = s.Angle < 0 || s.Angle > rng
+		}
+	}
+	return samples, nil
+}
+
+func init() {
+	Register("PWM", func() Analyzer { return &PWM{} })
+}
+
+// Channels implements Analyzer.
+func (*PWM) Channels() []Channel { return []Channel{{Name: "pwm"}} }
+
+// Settings implements Analyzer.
+func (p *PWM) Settings() []Setting {
+	return []Setting{
+		{Name: "inverted", Usage: "pulses are active low", Value: strconv.FormatBool(p.Inverted)},
+		{Name: "tolerance", Usage: "relative period deviation flagged irregular, 0 means 0.1", Value: formatFloat(p.Tolerance)},
+		{Name: "servo", Usage: "interpret pulse widths as servo angles", Value: strconv.FormatBool(p.Servo)},
+		{Name: "servomin", Usage: "servo pulse width at 0 degrees in seconds, 0 means 1ms", Value: formatFloat(p.ServoMin)},
+		{Name: "servomax", Usage: "servo pulse width at full range in seconds, 0 means 2ms", Value: formatFloat(p.ServoMax)},
+		{Name: "servorange", Usage: "servo range in degrees, 0 means 180", Value: formatFloat(p.ServoRange)},
+	}
+}
+
+// Set implements Analyzer.
+func (p *PWM) Set(name, value string) (err error) {
+	switch name {
+	case "inverted":
+		p.Inverted, err = strconv.ParseBool(value)
+	case "tolerance":
+		p.Tolerance, err = strconv.ParseFloat(value, 64)
+	case "servo":
+		p.Servo, err = strconv.ParseBool(value)
+	case "servomin":
+		p.ServoMin, err = strconv.ParseFloat(value, 64)
+	case "servomax":
+		p.ServoMax, err = strconv.ParseFloat(value, 64)
+	case "servorange":
+		p.ServoRange, err = strconv.ParseFloat(value, 64)
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Each period is returned as a frame of type "period"
+// with its "frequency", "duty" and "width", and the servo "angle" if enabled.
+// Periods with missing pulses, irregular periods and servo pulses out of range
+// are returned with an error.
+func (p *PWM) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(p, channels)
+	if err != nil {
+		return nil, err
+	}
+	samples, err := p.Scan(in[0])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(samples))
+	for i, s := range samples {
+		f := Frame{
+			Interval: s.Interval,
+			Type:     "period",
+			Data:     map[string]any{"frequency": s.Frequency, "duty": s.Duty, "width": s.HighWidth},
+		}
+		switch {
+		case s.Missing > 0:
+			f.Err = errPWMMissing
+		case s.Irregular:
+			f.Err = errPWMIrregular
+		}
+		if p.Servo {
+			f.Data["angle"] = s.Angle
+			if s.OutOfRange && f.Err == nil {
+				f.Err = errPWMServo
+			}
+		}
+		frames[i] = f
+	}
+	return frames, nil
+}
@@ -0,0 +1,71 @@
+package analyzers
+
+import (
+	"math"
+	"testing"
+
+	"github.com/soypat/saleae"
+)
+
+func TestPWMServo(t *testing.T) {
+	const period = 20e-3
+	var df saleae.DigitalFile
+	var widths []float64
+	tm := 1e-3
+	for i := 0; i <= 10; i++ {
+		w := 1e-3 + float64(i)*0.1e-3
+		if i == 3 {
+			w = 0.8e-3 // Out of servo range.
+		}
+		if i == 5 {
+			tm += period // Missing pulse.
+			continue
+		}
+		widths = append(widths, w)
+		df.Data = append(df.Data, tm, tm+w)
+		tm += period
+		if i == 8 {
+			tm += 3e-3 // Late pulse.
+		}
+	}
+	p := PWM{Servo: true}
+	samples, err := p.Scan(&df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != len(widths)-1 {
+		t.Fatalf("expected %d samples, got %d", len(widths)-1, len(samples))
+	}
+	for i, s := range samples {
+		if math.Abs(s.HighWidth-widths[i]) > 1e-12 {
+			t.Errorf("sample %d: expected width %g, got %g", i, widths[i], s.HighWidth)
+		}
+		wantAngle := (widths[i] - 1e-3) / 1e-3 * 180
+		if math.Abs(s.Angle-wantAngle) > 1e-6 {
+			t.Errorf("sample %d: expected angle %g, got %g", i, wantAngle, s.Angle)
+		}
+		if s.OutOfRange != (i == 3) {
+			t.Errorf("sample %d: expected out of range %v, got %v", i, i == 3, s.OutOfRange)
+		}
+		wantMissing, wantIrregular := 0, false
+		switch i {
+		case 4:
+			wantMissing, wantIrregular = 1, true
+		case 7:
+			wantIrregular = true
+		}
+		if s.Missing != wantMissing || s.Irregular != wantIrregular {
+			t.Errorf("sample %d: expected missing %d irregular %v, got %d %v", i, wantMissing, wantIrregular, s.Missing, s.Irregular)
+		}
+		if !s.Irregular && (math.Abs(s.Frequency-50) > 1e-6 || math.Abs(s.Duty-widths[i]/period) > 1e-9) {
+			t.Errorf("sample %d: unexpected frequency %g duty %g", i, s.Frequency, s.Duty)
+		}
+	}
+	frames, err := p.Analyze(map[string]*saleae.DigitalFile{"pwm": &df})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames[3].Err != errPWMServo || frames[2].Err != nil {
+		t.Errorf("expected servo range error on frame 3 only, got %v and %v", frames[3].Err, frames[2].Err)
+	}
+}
@@ -0,0 +1,221 @@
+package analyzers
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/soypat/saleae"
+)
+
+var (
+	errQuadIllegal = errors.New("quadrature: illegal transition, both channels changed")
+	errQuadIndex   = errors.New("quadrature: counts between index pulses differ from counts per revolution")
+)
+
+// SampleQuadrature is a change of the decoded encoder position, an index
+// pulse or an illegal transition.
+type SampleQuadrature struct {
+	Interval
+	// Position in counts after the event.
+	Position int
+	// Direction is 1 if A leads B, -1 if B leads A and 0 for index pulses
+	// and illegal transitions.
+	Direction int
+	// Velocity in counts per second over the velocity window ending at the sample.
+	Velocity float64
+	// Index is set for the rising edge of the index pulse.
+	Index bool
+	Err   error
+	// count is the total signed count, unaffected by index resets.
+	count int
+}
+
+// Quadrature can be used to decode incremental encoder A and B channels and
+// an optional index channel.
+type Quadrature struct {
+	// Resolution is the decoding mode: 1 counts rising edges of A, 2 counts
+	// both edges of A and 4 counts every edge of A and B. Zero means 4.
+	Resolution int
+	// Window is the time in seconds over which velocity is calculated. Zero means 10ms.
+	Window float64
+	// IndexReset zeroes the position on the rising edge of the index pulse.
+	IndexReset bool
+	// CountsPerRev, if non-zero, is checked against the counts between index pulses.
+	CountsPerRev int
+}
+
+// quadGray maps the A and B levels to their position in the forward
+// sequence 00, 10, 11, 01.
+func quadGray(a, b bool) int {
+	switch {
+	case !a && !b:
+		return 0
+	case a && !b:
+		return 1
+	case a && b:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Scan decodes the encoder channels. index may be nil.
+func (q *Quadrature) Scan(a, b, index *saleae.DigitalFile) (samples []SampleQuadrature, err error) {
+	if a == nil || b == nil {
+		return nil, errors.New("quadrature: got nil digital file")
+	}
+	res := q.Resolution
+	if res == 0 {
+		res = 4
+	}
+	if res != 1 && res != 2 && res != 4 {
+		return nil, errors.New("quadrature: resolution must be 1, 2 or 4")
+	}
+	window := q.Window
+	if window == 0 {
+		window = 10e-3
+	}
+	if window < 0 {
+		return nil, errors.New("quadrature: negative velocity window")
+	}
+	times := append(append([]float64(nil), a.Data...), b.Data...)
+	if index != nil {
+		times = append(times, index.Data...)
+	}
+	sort.Float64s(times)
+	var (
+		la        = levelAt(a, math.Inf(-1))
+		state     = quadGray(la, levelAt(b, math.Inf(-1)))
+		pos       int
+		count     int
+		lastIndex = math.NaN()
+		posIndex  int // Position at the last index pulse.
+	)
+	// velocity returns the count change over the window ending at t.
+	velocity := func(t float64) float64 {
+		before := 0
+		i := sort.Search(len(samples), func(i int) bool { return samples[i].start >= t-window })
+		if i > 0 {
+			before = samples[i-1].count
+		}
+		return float64(count-before) / window
+	}
+	for i, t := range times {
+		if i > 0 && t == times[i-1] {
+			continue
+		}
+		if index != nil && levelAt(index, t) && !levelAt(index, math.Nextafter(t, math.Inf(-1))) {
+			s := SampleQuadrature{Index: true}
+			s.start, s.end = t, t
+			if q.CountsPerRev != 0 && !math.IsNaN(lastIndex) {
+				if n := pos - posIndex; n != q.CountsPerRev && n != -q.CountsPerRev {
+					s.Err = errQuadIndex
+				}
+			}
+			if q.IndexReset {
+				pos = 0
+			}
+			lastIndex, posIndex = t, pos
+			s.Position, s.count = pos, count
+			s.Velocity = velocity(t)
+			samples = append(samples, s)
+		}
+		na, nb := levelAt(a, t), levelAt(b, t)
+		next := quadGray(na, nb)
+		diff := (next - state + 4) % 4
+		if diff == 0 {
+			continue
+		}
+		var dir int
+		switch diff {
+		case 1:
+			dir = 1
+		case 3:
+			dir = -1
+		}
+		// x1 counts forward on A rising and backward on A falling, which is the
+		// same physical edge, so dithering across it does not accumulate counts.
+		counted := res == 4 || res == 2 && na != la ||
+			res == 1 && (dir == 1 && na && !la || dir == -1 && !na && la)
+		la, state = na, next
+		if dir != 0 && !counted {
+			continue
+		}
+		s := SampleQuadrature{Direction: dir}
+		s.start, s.end = t, t
+		if dir == 0 {
+			s.Err = errQuadIllegal
+		}
+		pos += dir
+		count += dir
+		s.Position, s.count = pos, count
+		s.Velocity = velocity(t)
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+func init() {
+	Register("Quadrature", func() Analyzer { return &Quadrature{} })
+}
+
+// Channels implements Analyzer.
+func (*Quadrature) Channels() []Channel {
+	return []Channel{{Name: "a"}, {Name: "b"}, {Name: "index", Optional: true}}
+}
+
+// Settings implements Analyzer.
+func (q *Quadrature) Settings() []Setting {
+	return []Setting{
+		{Name: "resolution", Usage: "counts per cycle: 1, 2 or 4, 0 means 4", Value: strconv.Itoa(q.Resolution)},
+		{Name: "window", Usage: "velocity window in seconds, 0 means 10ms", Value: formatFloat(q.Window)},
+		{Name: "indexreset", Usage: "zero position on index pulse", Value: strconv.FormatBool(q.IndexReset)},
+		{Name: "cpr", Usage: "expected counts between index pulses, 0 disables the check", Value: strconv.Itoa(q.CountsPerRev)},
+	}
+}
+
+// Set implements Analyzer.
+func (q *Quadrature) Set(name, value string) (err error) {
+	switch name {
+	case "resolution":
+		q.Resolution, err = strconv.Atoi(value)
+	case "window":
+		q.Window, err = strconv.ParseFloat(value, 64)
+	case "indexreset":
+		q.IndexReset, err = strconv.ParseBool(value)
+	case "cpr":
+		q.CountsPerRev, err = strconv.Atoi(value)
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Position changes are returned as frames of type
+// "count" and index pulses as frames of type "index", both with the "position",
+// "direction" and "velocity".
+func (q *Quadrature) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(q, channels)
+	if err != nil {
+		return nil, err
+	}
+	samples, err := q.Scan(in[0], in[1], in[2])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(samples))
+	for i, s := range samples {
+		frames[i] = Frame{
+			Interval: s.Interval,
+			Type:     "count",
+			Data:     map[string]any{"position": s.Position, "direction": s.Direction, "velocity": s.Velocity},
+			Err:      s.Err,
+		}
+		if s.Index {
+			frames[i].Type = "index"
+		}
+	}
+	return frames, nil
+}
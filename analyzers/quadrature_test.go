@@ -0,0 +1,116 @@
+package analyzers
+
+import (
+	"math"
+	"testing"
+
+	"github.com/soypat/saleae"
+)
+
+// quadWave builds encoder channels one quarter cycle at a time.
+type quadWave struct {
+	t        float64
+	state    int
+	a, b, ix saleae.DigitalFile
+}
+
+func (w *quadWave) step(dir int, dt float64) {
+	w.t += dt
+	next := (w.state + dir + 4) % 4
+	// Forward sequence 00, 10, 11, 01: A changes between 0-1 and 2-3.
+	if (w.state == 0) != (next == 0) && (w.state == 1) != (next == 1) || (w.state == 2) != (next == 2) && (w.state == 3) != (next == 3) {
+		w.a.Data = append(w.a.Data, w.t)
+	} else {
+		w.b.Data = append(w.b.Data, w.t)
+	}
+	w.state = next
+}
+
+func (w *quadWave) index() {
+	w.ix.Data = append(w.ix.Data, w.t+1e-6, w.t+2e-6)
+}
+
+func TestQuadrature(t *testing.T) {
+	const dt = 1e-3
+	var w quadWave
+	for i := 0; i < 40; i++ {
+		w.step(1, dt)
+		if i == 19 {
+			w.index()
+		}
+	}
+	for i := 0; i < 8; i++ {
+		w.step(-1, dt)
+	}
+	// Both channels changing at once.
+	w.t += dt
+	w.a.Data = append(w.a.Data, w.t)
+	w.b.Data = append(w.b.Data, w.t)
+
+	for _, res := range []int{1, 2, 4} {
+		q := Quadrature{Resolution: res}
+		samples, err := q.Scan(&w.a, &w.b, &w.ix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var counts, indices int
+		var last SampleQuadrature
+		for _, s := range samples {
+			if s.Index {
+				indices++
+				continue
+			}
+			if s.Err == nil {
+				counts++
+				last = s
+			}
+		}
+		if want := 48 * res / 4; counts != want {
+			t.Errorf("x%d: expected %d counts, got %d", res, want, counts)
+		}
+		if want := 32 * res / 4; last.Position != want || last.Direction != -1 {
+			t.Errorf("x%d: expected position %d reversing, got %d %d", res, want, last.Position, last.Direction)
+		}
+		if end := samples[len(samples)-1]; end.Err != errQuadIllegal || indices != 1 {
+			t.Errorf("x%d: expected illegal transition at end and 1 index, got %v and %d", res, end.Err, indices)
+		}
+	}
+
+	q := Quadrature{IndexReset: true}
+	samples, err := q.Scan(&w.a, &w.b, &w.ix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range samples {
+		if s.Index && s.Position != 0 {
+			t.Errorf("expected position reset at index, got %d", s.Position)
+		}
+	}
+	// 10 counts per 10ms window forward at 1 count per millisecond.
+	if v := samples[30].Velocity; math.Abs(v-1000) > 1e-6 {
+		t.Errorf("expected velocity 1000 counts/s, got %g", v)
+	}
+	if got := samples[len(samples)-2].Position; got != 20-8 {
+		t.Errorf("expected position 12 after index reset, got %d", got)
+	}
+}
+
+func TestQuadratureDither(t *testing.T) {
+	// Shaft dithering across a single A edge with B low.
+	var w quadWave
+	for i := 0; i < 10; i++ {
+		w.step(1-2*(i%2), 1e-3)
+	}
+	for _, res := range []int{1, 2, 4} {
+		q := Quadrature{Resolution: res}
+		samples, err := q.Scan(&w.a, &w.b, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(samples) != 10 {
+			t.Errorf("x%d: expected 10 counts, got %d", res, len(samples))
+		} else if end := samples[len(samples)-1]; end.Position != 0 {
+			t.Errorf("x%d: expected position 0 after dithering, got %d", res, end.Position)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package analyzers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/soypat/saleae"
+)
+
+// RegisterMap declares the registers of a peripheral accessed over SPI where the first
+// byte of each transaction holds the register address and a read/write bit.
+type RegisterMap struct {
+	Name string
+	// AddrMask selects the address bits of the first byte of a transaction.
+	AddrMask byte
+	// WriteMask selects the read/write bit of the first byte of a transaction.
+	WriteMask byte
+	// WriteLow is set if the read/write bit is cleared for writes and set for reads.
+	WriteLow bool
+	// AutoIncrement is set if the register address is incremented after each byte
+	// of a burst access. FIFO registers are never incremented.
+	AutoIncrement bool
+	Registers     []RegisterDef
+}
+
+// RegisterDef defines a single register of a register map.
+type RegisterDef struct {
+	Addr uint8
+	Name string
+	// FIFO is set for registers which are accessed repeatedly in burst accesses.
+	FIFO   bool
+	Fields []BitField
+}
+
+// BitField is a bitfield of a register.
+type BitField struct {
+	Name  string
+	Shift uint8
+	Width uint8
+	// Values optionally names the values of the field.
+	Values []string
+}
+
+// Get returns the value of the field in the register value v.
+func (f BitField) Get(v byte) byte {
+	return v >> f.Shift & (1<<f.Width - 1)
+}
+
+// RegisterAccess is a read or write of a register.
+type RegisterAccess struct {
+	Interval
+	Write bool
+	Addr  uint8
+	// Register is the register accessed or nil if it is not in the register map.
+	Register *RegisterDef
+	// Data holds the register value. It contains all bytes of
+	// a burst access for FIFO registers.
+	Data []byte
+}
+
+// Name returns the register name or its address if it is not in the register map.
+func (ra RegisterAccess) Name() string {
+	if ra.Register != nil {
+		return ra.Register.Name
+	}
+	return fmt.Sprintf("0x%02x", ra.Addr)
+}
+
+func (ra RegisterAccess) String() string {
+	op := "R"
+	if ra.Write {
+		op = "W"
+	}
+	s := fmt.Sprintf("%s %s=%#x", op, ra.Name(), ra.Data)
+	if ra.Register == nil || ra.Register.FIFO || len(ra.Register.Fields) == 0 || len(ra.Data) == 0 {
+		return s
+	}
+	fields := make([]string, len(ra.Register.Fields))
+	for i, f := range ra.Register.Fields {
+		v := f.Get(ra.Data[0])
+		if int(v) < len(f.Values) && f.Values[v] != "" {
+			fields[i] = f.Name + "=" + f.Values[v]
+		} else {
+			fields[i] = f.Name + "=" + strconv.Itoa(int(v))
+		}
+	}
+	return s + " {" + strings.Join(fields, " ") + "}"
+}
+
+// Decode decodes SPI transactions into register accesses. Transactions consisting
+// only of an address byte are skipped.
+func (m *RegisterMap) Decode(txs []TxSPI) ([]RegisterAccess, error) {
+	if m.AddrMask == 0 {
+		return nil, errors.New("regmap: address mask not set")
+	}
+	regs := make(map[uint8]*RegisterDef, len(m.Registers))
+	for i := range m.Registers {
+		regs[m.Registers[i].Addr] = &m.Registers[i]
+	}
+	var accesses []RegisterAccess
+	for _, tx := range txs {
+		if len(tx.SDO) < 2 || len(tx.SDI) != len(tx.SDO) {
+			continue
+		}
+		write := tx.SDO[0]&m.WriteMask != 0 != m.WriteLow
+		data := tx.SDI
+		if write {
+			data = tx.SDO
+		}
+		addr := tx.SDO[0] & m.AddrMask
+		for i := 1; i < len(data); i++ {
+			reg := regs[addr]
+			access := RegisterAccess{Write: write, Addr: addr, Register: reg, Data: data[i : i+1]}
+			first := i
+			if (reg != nil && reg.FIFO) || !m.AutoIncrement {
+				// Remaining bytes all access this register.
+				access.Data = data[i:]
+				i = len(data) - 1
+			}
+			if len(tx.timings) == len(data) {
+				access.Interval = Interval{start: tx.timings[first].start, end: tx.timings[i].end}
+			}
+			accesses = append(accesses, access)
+			addr = (addr + 1) & m.AddrMask
+		}
+	}
+	return accesses, nil
+}
+
+// clone returns a copy of m with its own register definitions.
+func (m *RegisterMap) clone() *RegisterMap {
+	c := *m
+	c.Registers = append([]RegisterDef(nil), m.Registers...)
+	return &c
+}
+
+// RegisterMaps contains the built-in register maps keyed by name.
+var RegisterMaps = map[string]*RegisterMap{
+	SX127x.Name: &SX127x,
+}
+
+// Registers decodes register accesses of an SPI peripheral.
+type Registers struct {
+	SPI SPI
+	Map *RegisterMap
+}
+
+// Scan decodes all register accesses found on the SPI signals.
+func (r *Registers) Scan(clock, enable, sdo, sdi *saleae.DigitalFile) ([]RegisterAccess, error) {
+	if r.Map == nil {
+		return nil, errors.New("regmap: register map not set")
+	}
+	txs, err := r.SPI.Scan(clock, enable, sdo, sdi)
+	if err != nil {
+		return nil, err
+	}
+	return r.Map.Decode(txs)
+}
+
+func init() {
+	Register("Registers", func() Analyzer { return &Registers{Map: SX127x.clone()} })
+}
+
+// Channels implements Analyzer.
+func (r *Registers) Channels() []Channel { return r.SPI.Channels() }
+
+// Settings implements Analyzer. The register map is selected by name from RegisterMaps.
+func (r *Registers) Settings() []Setting {
+	name := ""
+	if r.Map != nil {
+		name = r.Map.Name
+	}
+	return []Setting{{Name: "map", Usage: "built-in register map name", Value: name}}
+}
+
+// Set implements Analyzer. The analyzer uses a copy of the built-in register map.
+func (r *Registers) Set(name, value string) error {
+	if name != "map" {
+		return errUnknownSetting(name)
+	}
+	m, ok := RegisterMaps[value]
+	if !ok {
+		return fmt.Errorf("regmap: unknown register map %q", value)
+	}
+	r.Map = m.clone()
+	return nil
+}
+
+// Analyze implements Analyzer. Each register access is returned as a frame of type
+// "read" or "write" with the register "name", "address" and "data". Fields of
+// non-FIFO registers are added as keys with their numeric value.
+func (r *Registers) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(r, channels)
+	if err != nil {
+		return nil, err
+	}
+	accesses, err := r.Scan(in[0], in[1], in[2], in[3])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(accesses))
+	for i, ra := range accesses {
+		typ := "read"
+		if ra.Write {
+			typ = "write"
+		}
+		data := map[string]any{"name": ra.Name(), "address": ra.Addr, "data": ra.Data}
+		if ra.Register != nil && !ra.Register.FIFO {
+			for _, f := range ra.Register.Fields {
+				data[f.Name] = f.Get(ra.Data[0])
+			}
+		}
+		frames[i] = Frame{Interval: ra.Interval, Type: typ, Data: data}
+	}
+	return frames, nil
+}
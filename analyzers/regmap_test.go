@@ -0,0 +1,72 @@
+package analyzers
+
+import "testing"
+
+func TestRegisterMapSX127x(t *testing.T) {
+	zeros := make([]byte, 4)
+	txs := []TxSPI{
+		// Read RegVersion.
+		{SDO: []byte{0x42, 0}, SDI: []byte{0, 0x12}},
+		// Write RegOpMode LoRa sleep.
+		{SDO: []byte{0x81, 0x80}, SDI: zeros[:2]},
+		// Burst write RegFrfMsb..RegFrfLsb for 433MHz.
+		{SDO: []byte{0x86, 0x6c, 0x40, 0x00}, SDI: zeros},
+		// Burst FIFO write.
+		{SDO: []byte{0x80, 'a', 'b', 'c'}, SDI: zeros},
+		// Address byte only.
+		{SDO: []byte{0x12}, SDI: zeros[:1]},
+		// Read unmapped register.
+		{SDO: []byte{0x04, 0}, SDI: []byte{0, 0x1f}},
+	}
+	accesses, err := SX127x.Decode(txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"R RegVersion=0x12",
+		"W RegOpMode=0x80 {LongRangeMode=LoRa AccessSharedReg=0 LowFrequencyModeOn=0 Mode=SLEEP}",
+		"W RegFrfMsb=0x6c",
+		"W RegFrfMid=0x40",
+		"W RegFrfLsb=0x00",
+		"W RegFifo=0x616263",
+		"R 0x04=0x1f",
+	}
+	if len(accesses) != len(want) {
+		t.Fatalf("got %d accesses, want %d: %v", len(accesses), len(want), accesses)
+	}
+	for i, ra := range accesses {
+		if got := ra.String(); got != want[i] {
+			t.Errorf("access %d: got %q, want %q", i, got, want[i])
+		}
+	}
+	// Without auto-increment all bytes of a burst access the addressed register.
+	m := SX127x
+	m.AutoIncrement = false
+	accesses, err = m.Decode(txs[2:3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accesses) != 1 || len(accesses[0].Data) != 3 {
+		t.Errorf("expected single 3 byte access without auto-increment, got %v", accesses)
+	}
+}
+
+func TestRegistersMapCopy(t *testing.T) {
+	a, err := New("Registers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := a.(*Registers)
+	var set Registers
+	err = set.Set("map", SX127x.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []*Registers{registry, &set} {
+		r.Map.AutoIncrement = false
+		r.Map.Registers[0].Name = "modified"
+		if !SX127x.AutoIncrement || SX127x.Registers[0].Name == "modified" {
+			t.Fatal("analyzer modified built-in register map")
+		}
+	}
+}
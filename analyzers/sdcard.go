@@ -0,0 +1,591 @@
+package analyzers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/soypat/saleae"
+)
+
+// CmdSD is an SD card command in SPI mode. SDIO also uses it for the
+// commands of the SD bus, which are declared in sdio.go.
+type CmdSD struct {
+	Index uint8
+	// App is set for application specific commands (ACMD) which are preceded by CMD55.
+	App bool
+}
+
+// SD card commands.
+var (
+	SDGoIdleState        = CmdSD{Index: 0}
+	SDSendOpCond         = CmdSD{Index: 1}
+	SDSwitchFunc         = CmdSD{Index: 6}
+	SDSendIfCond         = CmdSD{Index: 8}
+	SDSendCSD            = CmdSD{Index: 9}
+	SDSendCID            = CmdSD{Index: 10}
+	SDStopTransmission   = CmdSD{Index: 12}
+	SDSendStatus         = CmdSD{Index: 13}
+	SDSetBlockLen        = CmdSD{Index: 16}
+	SDReadSingleBlock    = CmdSD{Index: 17}
+	SDReadMultipleBlock  = CmdSD{Index: 18}
+	SDWriteBlock         = CmdSD{Index: 24}
+	SDWriteMultipleBlock = CmdSD{Index: 25}
+	SDEraseStart         = CmdSD{Index: 32}
+	SDEraseEnd           = CmdSD{Index: 33}
+	SDErase              = CmdSD{Index: 38}
+	SDAppCmd             = CmdSD{Index: 55}
+	SDReadOCR            = CmdSD{Index: 58}
+	SDCRCOnOff           = CmdSD{Index: 59}
+	SDStatus             = CmdSD{Index: 13, App: true}
+	SDSendNumWrBlocks    = CmdSD{Index: 22, App: true}
+	SDSetWrBlkEraseCount = CmdSD{Index: 23, App: true}
+	SDSendOpCondApp      = CmdSD{Index: 41, App: true}
+	SDSendSCR            = CmdSD{Index: 51, App: true}
+)
+
+var sdCmdNames = map[CmdSD]string{
+	SDGoIdleState:        "GO_IDLE_STATE",
+	SDSendOpCond:         "SEND_OP_COND",
+	SDSwitchFunc:         "SWITCH_FUNC",
+	SDSendIfCond:         "SEND_IF_COND",
+	SDSendCSD:            "SEND_CSD",
+	SDSendCID:            "SEND_CID",
+	SDStopTransmission:   "STOP_TRANSMISSION",
+	SDSendStatus:         "SEND_STATUS",
+	SDSetBlockLen:        "SET_BLOCKLEN",
+	SDReadSingleBlock:    "READ_SINGLE_BLOCK",
+	SDReadMultipleBlock:  "READ_MULTIPLE_BLOCK",
+	SDWriteBlock:         "WRITE_BLOCK",
+	SDWriteMultipleBlock: "WRITE_MULTIPLE_BLOCK",
+	SDEraseStart:         "ERASE_WR_BLK_START",
+	SDEraseEnd:           "ERASE_WR_BLK_END",
+	SDErase:              "ERASE",
+	SDAppCmd:             "APP_CMD",
+	SDReadOCR:            "READ_OCR",
+	SDCRCOnOff:           "CRC_ON_OFF",
+	SDStatus:             "SD_STATUS",
+	SDSendNumWrBlocks:    "SEND_NUM_WR_BLOCKS",
+	SDSetWrBlkEraseCount: "SET_WR_BLK_ERASE_COUNT",
+	SDSendOpCondApp:      "SD_SEND_OP_COND",
+	SDSendSCR:            "SEND_SCR",
+}
+
+// Name returns the command name as used in the SD specification.
+func (c CmdSD) Name() string {
+	return sdCmdNames[c]
+}
+
+func (c CmdSD) String() string {
+	s := "CMD"
+	if c.App {
+		s = "ACMD"
+	}
+	return s + strconv.Itoa(int(c.Index))
+}
+
+// R1SD is the R1 response byte sent by an SD card after every command in SPI mode.
+type R1SD uint8
+
+// R1 response bits.
+const (
+	R1Idle           R1SD = 1 << 0
+	R1EraseReset     R1SD = 1 << 1
+	R1IllegalCommand R1SD = 1 << 2
+	R1CRCError       R1SD = 1 << 3
+	R1EraseSeqError  R1SD = 1 << 4
+	R1AddressError   R1SD = 1 << 5
+	R1ParameterError R1SD = 1 << 6
+)
+
+func (r R1SD) String() string {
+	var flags []string
+	for _, f := range []struct {
+		bit  R1SD
+		name string
+	}{
+		{R1Idle, "idle"},
+		{R1EraseReset, "erase_reset"},
+		{R1IllegalCommand, "illegal_cmd"},
+		{R1CRCError, "crc_error"},
+		{R1EraseSeqError, "erase_seq_error"},
+		{R1AddressError, "address_error"},
+		{R1ParameterError, "parameter_error"},
+	} {
+		if r&f.bit != 0 {
+			flags = append(flags, f.name)
+		}
+	}
+	return "r1{" + strings.Join(flags, ",") + "}"
+}
+
+// Data tokens.
+const (
+	sdTokenStartBlock      = 0xfe
+	sdTokenStartMultiWrite = 0xfc
+	sdTokenStopTran        = 0xfd
+	// Maximum number of bytes between command and response (NCR).
+	sdNCRMax = 8
+)
+
+var (
+	errSDNoResponse  = errors.New("sd: no response to command")
+	errSDCRC7        = errors.New("sd: command CRC7 mismatch")
+	errSDDataCRC     = errors.New("sd: data block CRC16 mismatch")
+	errSDDataError   = errors.New("sd: data error token")
+	errSDDataReject  = errors.New("sd: data block rejected by card")
+	errSDNotReset    = errors.New("sd: command before CMD0")
+	errSDNotReady    = errors.New("sd: command before initialization completed")
+	errSDIncomplete  = errors.New("sd: capture ends mid transaction")
+	errSDNoDataStart = errors.New("sd: data block without start token")
+)
+
+// BlockSD is a data block read from or written to an SD card.
+type BlockSD struct {
+	Interval
+	Data []byte
+	CRC  uint16
+	// Token is the start token of the block or the data error token if the read failed.
+	Token byte
+	// Response is the data response token sent by the card after written blocks.
+	Response byte
+	Err      error
+}
+
+// TxSD is an SD card command with its response and data blocks.
+type TxSD struct {
+	Interval
+	Cmd CmdSD
+	Arg uint32
+	CRC uint8
+	// R1 is the first response byte.
+	R1          R1SD
+	HasResponse bool
+	// Response holds the trailing bytes of R2, R3 and R7 responses.
+	Response []byte
+	Blocks   []BlockSD
+	// Err is the first error found while decoding the command, its response or data.
+	Err error
+}
+
+func (tx TxSD) String() string {
+	s := fmt.Sprintf("%s(%#08x)", tx.Cmd, tx.Arg)
+	if name := tx.Cmd.Name(); name != "" {
+		s += " " + name
+	}
+	if tx.HasResponse {
+		s += " " + tx.R1.String()
+	}
+	if len(tx.Response) > 0 {
+		s += fmt.Sprintf(" resp=%#x", tx.Response)
+	}
+	for _, b := range tx.Blocks {
+		s += " block[" + strconv.Itoa(len(b.Data)) + "]"
+	}
+	if tx.Err != nil {
+		s += " err=" + tx.Err.Error()
+	}
+	return s
+}
+
+// SDCard decodes SD card SPI mode commands, responses and data blocks.
+// Initialization state is tracked across commands to flag commands
+// issued out of sequence.
+type SDCard struct {
+	SPI SPI
+	// Initialized is set if the card is initialized at the start of the capture.
+	Initialized bool
+	// CRC is set if CRC checking was enabled with CMD59 before the start of the capture.
+	// CMD0 and CMD8 CRCs are always checked.
+	CRC bool
+	// BlockLen is the data block length. Zero means 512 bytes.
+	BlockLen int
+	reset    bool
+}
+
+// Scan decodes all SD card commands found on the SPI signals.
+func (sd *SDCard) Scan(clock, enable, sdo, sdi *saleae.DigitalFile) ([]TxSD, error) {
+	txs, err := sd.SPI.Scan(clock, enable, sdo, sdi)
+	if err != nil {
+		return nil, err
+	}
+	return sd.Decode(txs)
+}
+
+// sdStream is the concatenation of the bytes of consecutive SPI transactions.
+type sdStream struct {
+	mosi, miso []byte
+	timings    []Interval
+}
+
+func (s *sdStream) isCmd(i int) bool { return s.mosi[i]&0xc0 == 0x40 }
+
+// Decode decodes SPI transactions into SD card commands. Commands, responses and
+// data may span several SPI transactions. The card state starts from sd and is
+// tracked across commands without modifying sd.
+func (sd *SDCard) Decode(txs []TxSPI) ([]TxSD, error) {
+	st := *sd // Card state during the capture.
+	var s sdStream
+	for _, tx := range txs {
+		if len(tx.SDI) != len(tx.SDO) {
+			continue
+		}
+		s.mosi = append(s.mosi, tx.SDO...)
+		s.miso = append(s.miso, tx.SDI...)
+		timings := tx.timings
+		if len(timings) != len(tx.SDO) {
+			timings = make([]Interval, len(tx.SDO))
+		}
+		s.timings = append(s.timings, timings...)
+	}
+	if st.BlockLen == 0 {
+		st.BlockLen = 512
+	}
+	var sdtxs []TxSD
+	app := false
+	for i := 0; i+6 <= len(s.mosi); {
+		if !s.isCmd(i) {
+			i++
+			continue
+		}
+		tx := TxSD{
+			Cmd: CmdSD{Index: s.mosi[i] & 0x3f, App: app},
+			Arg: uint32(s.mosi[i+1])<<24 | uint32(s.mosi[i+2])<<16 | uint32(s.mosi[i+3])<<8 | uint32(s.mosi[i+4]),
+			CRC: s.mosi[i+5] >> 1,
+		}
+		tx.start = s.timings[i].start
+		tx.end = s.timings[i+5].end
+		if (st.CRC || tx.Cmd == SDGoIdleState || tx.Cmd == SDSendIfCond) && crc7(s.mosi[i:i+5]) != tx.CRC {
+			tx.Err = errSDCRC7
+		}
+		if err := st.checkSequence(tx.Cmd); err != nil && tx.Err == nil {
+			tx.Err = err
+		}
+		i = st.decodeResponse(&s, &tx, i+6)
+		app = tx.Cmd == SDAppCmd && tx.HasResponse && tx.R1&^R1Idle == 0
+		st.track(&tx)
+		sdtxs = append(sdtxs, tx)
+	}
+	return sdtxs, nil
+}
+
+// checkSequence returns an error if cmd is not valid in the current initialization state.
+func (sd *SDCard) checkSequence(cmd CmdSD) error {
+	switch {
+	case sd.Initialized || cmd == SDGoIdleState:
+		return nil
+	case !sd.reset:
+		return errSDNotReset
+	}
+	switch cmd {
+	case SDSendOpCond, SDSendIfCond, SDAppCmd, SDSendOpCondApp, SDReadOCR, SDCRCOnOff:
+		return nil
+	}
+	return errSDNotReady
+}
+
+// track updates the card state after a command and its response.
+func (sd *SDCard) track(tx *TxSD) {
+	if !tx.HasResponse {
+		return
+	}
+	switch tx.Cmd {
+	case SDGoIdleState:
+		if tx.R1 == R1Idle {
+			sd.reset = true
+			sd.Initialized = false
+			sd.CRC = false
+		}
+	case SDSendOpCond, SDSendOpCondApp:
+		if tx.R1 == 0 && sd.reset {
+			sd.Initialized = true
+		}
+	case SDCRCOnOff:
+		if tx.R1&^R1Idle == 0 {
+			sd.CRC = tx.Arg&1 != 0
+		}
+	case SDSetBlockLen:
+		if tx.R1 == 0 && tx.Arg > 0 {
+			sd.BlockLen = int(tx.Arg)
+		}
+	}
+}
+
+// decodeResponse decodes the response and data blocks of tx starting at byte i
+// of the stream and returns the index of the first byte after them.
+func (sd *SDCard) decodeResponse(s *sdStream, tx *TxSD, i int) int {
+	if tx.Cmd == SDStopTransmission {
+		i++ // Stuff byte.
+	}
+	end := i + sdNCRMax + 1
+	for ; i < len(s.miso) && i < end && s.miso[i]&0x80 != 0; i++ {
+	}
+	if i >= len(s.miso) || i >= end {
+		if tx.Err == nil {
+			tx.Err = errSDNoResponse
+		}
+		return i
+	}
+	tx.HasResponse = true
+	tx.R1 = R1SD(s.miso[i])
+	i++
+	extra := 0
+	switch tx.Cmd {
+	case SDSendIfCond, SDReadOCR:
+		extra = 4 // R7 and R3.
+	case SDSendStatus, SDStatus:
+		extra = 1 // R2.
+	}
+	if tx.R1&R1IllegalCommand != 0 {
+		extra = 0 // Only R1 is sent for illegal commands.
+	}
+	if i+extra > len(s.miso) {
+		tx.Err = errSDIncomplete
+		return len(s.miso)
+	}
+	tx.Response = s.miso[i : i+extra]
+	i += extra
+	tx.end = s.timings[i-1].end
+	if tx.R1&^R1Idle != 0 {
+		return i // Command not accepted, no data follows.
+	}
+	switch tx.Cmd {
+	case SDReadSingleBlock:
+		return sd.readBlocks(s, tx, i, sd.BlockLen, false)
+	case SDReadMultipleBlock:
+		return sd.readBlocks(s, tx, i, sd.BlockLen, true)
+	case SDSendCSD, SDSendCID:
+		return sd.readBlocks(s, tx, i, 16, false)
+	case SDStatus, SDSwitchFunc:
+		return sd.readBlocks(s, tx, i, 64, false)
+	case SDSendSCR:
+		return sd.readBlocks(s, tx, i, 8, false)
+	case SDSendNumWrBlocks:
+		return sd.readBlocks(s, tx, i, 4, false)
+	case SDWriteBlock:
+		return sd.writeBlocks(s, tx, i, false)
+	case SDWriteMultipleBlock:
+		return sd.writeBlocks(s, tx, i, true)
+	case SDStopTransmission, SDErase:
+		return s.skipBusy(i)
+	}
+	return i
+}
+
+// readBlocks decodes data blocks sent by the card. Multiple block reads
+// end when the host sends a command.
+func (sd *SDCard) readBlocks(s *sdStream, tx *TxSD, i, n int, multi bool) int {
+	for {
+		// Wait for the start or error token.
+		for ; i < len(s.miso) && s.miso[i] == 0xff && !s.isCmd(i); i++ {
+		}
+		if i >= len(s.miso) {
+			if !multi && tx.Err == nil {
+				tx.Err = errSDIncomplete
+			}
+			return i
+		}
+		if s.isCmd(i) {
+			if !multi && tx.Err == nil {
+				tx.Err = errSDNoDataStart
+			}
+			return i
+		}
+		var b BlockSD
+		b.start = s.timings[i].start
+		b.Token = s.miso[i]
+		if b.Token != sdTokenStartBlock {
+			b.end = s.timings[i].end
+			b.Err = errSDDataError
+			if b.Token&0xf0 != 0 {
+				b.Err = errSDNoDataStart
+			}
+			tx.addBlock(b)
+			return i + 1
+		}
+		i++
+		if i+n+2 > len(s.miso) {
+			tx.Err = errSDIncomplete
+			return len(s.miso)
+		}
+		b.Data = s.miso[i : i+n]
+		b.CRC = uint16(s.miso[i+n])<<8 | uint16(s.miso[i+n+1])
+		b.end = s.timings[i+n+1].end
+		if crc16CCITT(b.Data) != b.CRC {
+			b.Err = errSDDataCRC
+		}
+		tx.addBlock(b)
+		i += n + 2
+		if !multi {
+			return i
+		}
+	}
+}
+
+// writeBlocks decodes data blocks sent by the host and the card's data responses.
+// Multiple block writes end with the stop transmission token.
+func (sd *SDCard) writeBlocks(s *sdStream, tx *TxSD, i int, multi bool) int {
+	for {
+		for ; i < len(s.mosi) && s.mosi[i] == 0xff; i++ {
+		}
+		if i >= len(s.mosi) {
+			tx.Err = errSDIncomplete
+			return i
+		}
+		token := s.mosi[i]
+		if multi && token == sdTokenStopTran {
+			return s.skipBusy(i + 2) // Skip the stuff byte after the token.
+		}
+		if (multi && token != sdTokenStartMultiWrite) || (!multi && token != sdTokenStartBlock) {
+			if tx.Err == nil {
+				tx.Err = errSDNoDataStart
+			}
+			return i
+		}
+		b := BlockSD{Token: token}
+		b.start = s.timings[i].start
+		i++
+		n := sd.BlockLen
+		if i+n+2 > len(s.mosi) {
+			tx.Err = errSDIncomplete
+			return len(s.mosi)
+		}
+		b.Data = s.mosi[i : i+n]
+		b.CRC = uint16(s.mosi[i+n])<<8 | uint16(s.mosi[i+n+1])
+		i += n + 2
+		if sd.CRC && crc16CCITT(b.Data) != b.CRC {
+			b.Err = errSDDataCRC
+		}
+		// Data response token xxx0sss1.
+		for end := i + sdNCRMax + 1; i < len(s.miso) && i < end && s.miso[i]&0x11 != 0x01; i++ {
+		}
+		if i < len(s.miso) && s.miso[i]&0x11 == 0x01 {
+			b.Response = s.miso[i]
+			if (b.Response>>1)&0x7 != 0x2 && b.Err == nil {
+				b.Err = errSDDataReject
+			}
+			i++
+		}
+		b.end = s.timings[i-1].end
+		tx.addBlock(b)
+		i = s.skipBusy(i)
+		if !multi {
+			return i
+		}
+	}
+}
+
+// skipBusy skips the busy bytes the card sends while programming.
+func (s *sdStream) skipBusy(i int) int {
+	for ; i < len(s.miso) && s.miso[i] == 0 && !s.isCmd(i); i++ {
+	}
+	return i
+}
+
+func (tx *TxSD) addBlock(b BlockSD) {
+	tx.Blocks = append(tx.Blocks, b)
+	tx.end = b.end
+	if b.Err != nil && tx.Err == nil {
+		tx.Err = b.Err
+	}
+}
+
+// crc7 computes the CRC7 used by SD card commands.
+func crc7(data []byte) uint8 {
+	var crc uint8
+	for _, b := range data {
+		for i := 7; i >= 0; i-- {
+			bit := (b>>i)&1 ^ crc>>6
+			crc = crc << 1 & 0x7f
+			if bit != 0 {
+				crc ^= 0x09
+			}
+		}
+	}
+	return crc
+}
+
+// crc16CCITT computes the CRC16 used by SD card data blocks.
+func crc16CCITT(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func init() {
+	Register("SD", func() Analyzer { return &SDCard{} })
+}
+
+// Channels implements Analyzer.
+func (sd *SDCard) Channels() []Channel { return sd.SPI.Channels() }
+
+// Settings implements Analyzer.
+func (sd *SDCard) Settings() []Setting {
+	return []Setting{
+		{Name: "initialized", Usage: "card is initialized at start", Value: strconv.FormatBool(sd.Initialized)},
+		{Name: "crc", Usage: "CRC checking enabled at start", Value: strconv.FormatBool(sd.CRC)},
+		{Name: "blocklen", Usage: "data block length, 0 means 512", Value: strconv.Itoa(sd.BlockLen)},
+	}
+}
+
+// Set implements Analyzer.
+func (sd *SDCard) Set(name, value string) (err error) {
+	switch name {
+	case "initialized":
+		sd.Initialized, err = strconv.ParseBool(value)
+	case "crc":
+		sd.CRC, err = strconv.ParseBool(value)
+	case "blocklen":
+		sd.BlockLen, err = strconv.Atoi(value)
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Each command is returned as a frame of type "command"
+// followed by a frame of type "block" for each data block.
+func (sd *SDCard) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(sd, channels)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := sd.Scan(in[0], in[1], in[2], in[3])
+	if err != nil {
+		return nil, err
+	}
+	var frames []Frame
+	for _, tx := range txs {
+		data := map[string]any{
+			"command":  tx.Cmd.String(),
+			"name":     tx.Cmd.Name(),
+			"argument": tx.Arg,
+		}
+		if tx.HasResponse {
+			data["r1"] = uint8(tx.R1)
+		}
+		if len(tx.Response) > 0 {
+			data["response"] = tx.Response
+		}
+		frames = append(frames, Frame{Interval: tx.Interval, Type: "command", Data: data, Err: tx.Err})
+		for _, b := range tx.Blocks {
+			frames = append(frames, Frame{
+				Interval: b.Interval,
+				Type:     "block",
+				Data:     map[string]any{"token": b.Token, "data": b.Data, "crc": b.CRC},
+				Err:      b.Err,
+			})
+		}
+	}
+	return frames, nil
+}
@@ -0,0 +1,145 @@
+package analyzers
+
+import "testing"
+
+// sdBuilder builds the byte stream of an SD card SPI session.
+type sdBuilder struct {
+	mosi, miso []byte
+}
+
+func (b *sdBuilder) host(data ...byte) {
+	b.mosi = append(b.mosi, data...)
+	for range data {
+		b.miso = append(b.miso, 0xff)
+	}
+}
+
+func (b *sdBuilder) card(data ...byte) {
+	b.miso = append(b.miso, data...)
+	for range data {
+		b.mosi = append(b.mosi, 0xff)
+	}
+}
+
+func (b *sdBuilder) cmd(index uint8, arg uint32, r1 byte, resp ...byte) {
+	c := []byte{0x40 | index, byte(arg >> 24), byte(arg >> 16), byte(arg >> 8), byte(arg)}
+	b.host(append(c, crc7(c)<<1|1)...)
+	b.card(0xff, r1)
+	b.card(resp...)
+}
+
+func (b *sdBuilder) tx() []TxSPI {
+	return []TxSPI{{SDO: b.mosi, SDI: b.miso}}
+}
+
+func TestSDCard(t *testing.T) {
+	block := make([]byte, 512)
+	for i := range block {
+		block[i] = byte(i)
+	}
+	crc := crc16CCITT(block)
+	var b sdBuilder
+	b.cmd(0, 0, 0x01)
+	b.cmd(8, 0x1aa, 0x01, 0x00, 0x00, 0x01, 0xaa)
+	b.cmd(55, 0, 0x01)
+	b.cmd(41, 1<<30, 0x00)
+	b.cmd(17, 0, 0x00)
+	b.card(0xff, 0xff, 0xfe)
+	b.card(block...)
+	b.card(byte(crc>>8), byte(crc))
+	b.cmd(24, 1, 0x00)
+	b.host(0xff, 0xfe)
+	b.host(block...)
+	b.host(byte(crc>>8), byte(crc))
+	b.card(0x05, 0, 0, 0, 0xff)
+	b.cmd(17, 2, 0x00)
+	b.card(0xfe)
+	b.card(block...)
+	b.card(byte(crc>>8), byte(crc)+1)
+
+	var sd SDCard
+	txs, err := sd.Decode(b.tx())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		cmd    CmdSD
+		r1     R1SD
+		blocks int
+		err    error
+	}{
+		{cmd: SDGoIdleState, r1: R1Idle},
+		{cmd: SDSendIfCond, r1: R1Idle},
+		{cmd: SDAppCmd, r1: R1Idle},
+		{cmd: SDSendOpCondApp},
+		{cmd: SDReadSingleBlock, blocks: 1},
+		{cmd: SDWriteBlock, blocks: 1},
+		{cmd: SDReadSingleBlock, blocks: 1, err: errSDDataCRC},
+	}
+	if len(txs) != len(want) {
+		t.Fatalf("got %d commands, want %d: %v", len(txs), len(want), txs)
+	}
+	for i, tx := range txs {
+		w := want[i]
+		if tx.Cmd != w.cmd || tx.R1 != w.r1 || len(tx.Blocks) != w.blocks || tx.Err != w.err {
+			t.Errorf("command %d: got %v", i, tx)
+		}
+	}
+	if sd.Initialized || sd.BlockLen != 0 {
+		t.Errorf("decoding modified start of capture state: %+v", sd)
+	}
+	if string(txs[1].Response) != "\x00\x00\x01\xaa" {
+		t.Errorf("got R7 %#x", txs[1].Response)
+	}
+	if string(txs[4].Blocks[0].Data) != string(block) || txs[5].Blocks[0].Response != 0x05 {
+		t.Error("data block mismatch")
+	}
+}
+
+func TestSDCardSequence(t *testing.T) {
+	var b sdBuilder
+	b.cmd(8, 0x1aa, 0x05)
+	b.cmd(0, 0, 0x01)
+	b.cmd(17, 0, 0x05)
+	b.host(0x40, 0, 0, 0, 0, 0x01) // CMD0 with bad CRC.
+	b.card(0xff, 0x09)
+	var sd SDCard
+	txs, err := sd.Decode(b.tx())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []error{errSDNotReset, nil, errSDNotReady, errSDCRC7}
+	if len(txs) != len(want) {
+		t.Fatalf("got %d commands, want %d: %v", len(txs), len(want), txs)
+	}
+	for i, tx := range txs {
+		if tx.Err != want[i] {
+			t.Errorf("command %d: got error %v, want %v", i, tx.Err, want[i])
+		}
+	}
+}
+
+func TestSDCardNCR(t *testing.T) {
+	var b sdBuilder
+	b.cmd(0, 0, 0x01)
+	c := []byte{0x40, 0, 0, 0, 0}
+	b.host(append(c, crc7(c)<<1|1)...)
+	b.card(0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01) // NCR = 8.
+	c = []byte{0x40 | 8, 0, 0, 0x01, 0xaa}
+	b.host(append(c, crc7(c)<<1|1)...)
+	b.card(0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01) // NCR = 9.
+	var sd SDCard
+	txs, err := sd.Decode(b.tx())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 3 {
+		t.Fatalf("got %d commands, want 3: %v", len(txs), txs)
+	}
+	if tx := txs[1]; !tx.HasResponse || tx.R1 != R1Idle || tx.Err != nil {
+		t.Errorf("expected response after 8 bytes, got %v", tx)
+	}
+	if tx := txs[2]; tx.HasResponse || tx.Err != errSDNoResponse {
+		t.Errorf("expected no response after 9 bytes, got %v", tx)
+	}
+}
@@ -0,0 +1,645 @@
+package analyzers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/soypat/saleae"
+)
+
+// SD bus and SDIO commands.
+var (
+	SDAllSendCID       = CmdSD{Index: 2}
+	SDSendRelativeAddr = CmdSD{Index: 3}
+	SDIOSendOpCond     = CmdSD{Index: 5}
+	SDSelectCard       = CmdSD{Index: 7}
+	SDIORWDirect       = CmdSD{Index: 52}
+	SDIORWExtended     = CmdSD{Index: 53}
+	SDSetBusWidth      = CmdSD{Index: 6, App: true}
+)
+
+func init() {
+	for cmd, name := range map[CmdSD]string{
+		SDAllSendCID:       "ALL_SEND_CID",
+		SDSendRelativeAddr: "SEND_RELATIVE_ADDR",
+		SDIOSendOpCond:     "IO_SEND_OP_COND",
+		SDSelectCard:       "SELECT_CARD",
+		SDIORWDirect:       "IO_RW_DIRECT",
+		SDIORWExtended:     "IO_RW_EXTENDED",
+		SDSetBusWidth:      "SET_BUS_WIDTH",
+	} {
+		sdCmdNames[cmd] = name
+	}
+}
+
+// CmdSDIO is the decoded argument of an IO_RW_DIRECT (CMD52) or
+// IO_RW_EXTENDED (CMD53) command.
+type CmdSDIO struct {
+	Write bool
+	// AutoInc is the CMD53 OP code, set for incrementing addresses.
+	AutoInc bool
+	// Block is set for CMD53 block mode transfers.
+	Block bool
+	// RAW is the CMD52 read after write flag.
+	RAW  bool
+	Fn   Function
+	Addr uint32
+	// Size is the CMD53 byte or block count. Zero means 512 bytes in byte
+	// mode and an infinite number of blocks in block mode. Size is 1 for CMD52.
+	Size uint32
+	// Data is the byte written by CMD52.
+	Data uint8
+}
+
+// DecodeCmd52SDIO decodes a CMD52 argument.
+func DecodeCmd52SDIO(arg uint32) (cmd CmdSDIO) {
+	cmd.Write = arg&(1<<31) != 0
+	cmd.Fn = Function(arg>>28) & 0b111
+	cmd.RAW = arg&(1<<27) != 0
+	cmd.Addr = (arg >> 9) & 0x1ffff
+	cmd.Size = 1
+	cmd.Data = uint8(arg)
+	return cmd
+}
+
+// DecodeCmd53SDIO decodes a CMD53 argument.
+func DecodeCmd53SDIO(arg uint32) (cmd CmdSDIO) {
+	cmd.Write = arg&(1<<31) != 0
+	cmd.Fn = Function(arg>>28) & 0b111
+	cmd.Block = arg&(1<<27) != 0
+	cmd.AutoInc = arg&(1<<26) != 0
+	cmd.Addr = (arg >> 9) & 0x1ffff
+	cmd.Size = arg & 0x1ff
+	return cmd
+}
+
+func (cmd CmdSDIO) String() string {
+	return fmt.Sprintf("addr=%#7x  fn=%9s  sz=%4v write=%5v autoinc=%5v block=%5v",
+		cmd.Addr, cmd.Fn.String(), cmd.Size, cmd.Write, cmd.AutoInc, cmd.Block)
+}
+
+// R5SDIO holds the response flags of CMD52 and CMD53 responses.
+type R5SDIO uint8
+
+// R5 response flags.
+const (
+	R5OutOfRange     R5SDIO = 1 << 0
+	R5FunctionNumber R5SDIO = 1 << 1
+	R5Error          R5SDIO = 1 << 3
+	R5IllegalCommand R5SDIO = 1 << 6
+	R5CRCError       R5SDIO = 1 << 7
+	r5Errors                = R5OutOfRange | R5FunctionNumber | R5Error | R5IllegalCommand | R5CRCError
+)
+
+// State returns the IO_CURRENT_STATE field: 0 disabled, 1 command and 2 transfer.
+func (r R5SDIO) State() uint8 { return uint8(r>>4) & 0b11 }
+
+func (r R5SDIO) String() string {
+	flags := []string{"state=" + [...]string{"dis", "cmd", "trn", "rfu"}[r.State()]}
+	for _, f := range []struct {
+		bit  R5SDIO
+		name string
+	}{
+		{R5OutOfRange, "out_of_range"},
+		{R5FunctionNumber, "function_number"},
+		{R5Error, "error"},
+		{R5IllegalCommand, "illegal_cmd"},
+		{R5CRCError, "crc_error"},
+	} {
+		if r&f.bit != 0 {
+			flags = append(flags, f.name)
+		}
+	}
+	return "r5{" + strings.Join(flags, ",") + "}"
+}
+
+// CCCR and FBR registers of function 0 which change how transfers are decoded.
+const (
+	sdioRegBusInterface  = 0x07
+	sdioBusWidthMask     = 0b11
+	sdioBusWidth4        = 0b10
+	sdioRegBlockSizeLow  = 0x10 // Offset within the CCCR or FBR of each function.
+	sdioRegBlockSizeHigh = 0x11
+	// Clocks between the end bit of a written block and the CRC status token.
+	sdioNCRCMax = 8
+)
+
+var (
+	errSDIOEnd         = errors.New("sdio: missing end bit")
+	errSDIOCRC7        = errors.New("sdio: CRC7 mismatch")
+	errSDIORespIndex   = errors.New("sdio: response index differs from command")
+	errSDIOResponse    = errors.New("sdio: response flags report an error")
+	errSDIODataCRC     = errors.New("sdio: data block CRC16 mismatch")
+	errSDIOShort       = errors.New("sdio: capture ends within data block")
+	errSDIOMissing     = errors.New("sdio: fewer data blocks than command count")
+	errSDIOCRCStatus   = errors.New("sdio: card rejected written block")
+	errSDIONoCRCStatus = errors.New("sdio: no CRC status after written block")
+	errSDIONoDATLines  = errors.New("sdio: 4-bit transfer without DAT1-DAT3 channels")
+)
+
+// BlockSDIO is a data block transferred on the DAT lines.
+type BlockSDIO struct {
+	Interval
+	Data []byte
+	// CRC is the CRC16 received on each DAT line. Only CRC[0] is used on 1-bit buses.
+	CRC [4]uint16
+	// Status is the CRC status token sent by the card after written blocks:
+	// 0b010 if accepted, 0b101 for a CRC error and 0b110 for a write error.
+	Status uint8
+	Err    error
+}
+
+// TxSDIO is a command on the SD bus with its response and data blocks.
+type TxSDIO struct {
+	Interval
+	Command CmdSD
+	Arg     uint32
+	// Cmd holds the decoded argument of CMD52 and CMD53.
+	Cmd         CmdSDIO
+	HasResponse bool
+	// Response is the argument of 48-bit responses. For CMD52 and CMD53 it
+	// holds the R5 flags and the data read by CMD52.
+	Response uint32
+	// LongResponse holds the CID or CSD register of R2 responses.
+	LongResponse []byte
+	// Data is the byte written or read by CMD52 or the data blocks of CMD53.
+	Data   []byte
+	Blocks []BlockSDIO
+	// BackplaneAddr is the full 32-bit backplane address accessed
+	// when HasBackplaneAddr is set.
+	BackplaneAddr    uint32
+	HasBackplaneAddr bool
+	// Err is the first error found while decoding the command, its response or data.
+	Err error
+}
+
+// IsIO returns true for CMD52 and CMD53.
+func (tx TxSDIO) IsIO() bool { return tx.Command == SDIORWDirect || tx.Command == SDIORWExtended }
+
+// R5 returns the response flags of CMD52 and CMD53.
+func (tx TxSDIO) R5() R5SDIO { return R5SDIO(tx.Response >> 8) }
+
+func (tx TxSDIO) String() string {
+	s := fmt.Sprintf("%s(%#08x)", tx.Command, tx.Arg)
+	if name := tx.Command.Name(); name != "" {
+		s += " " + name
+	}
+	if tx.IsIO() {
+		s += " " + tx.Cmd.String() + fmt.Sprintf(" data=%#x", tx.Data)
+		if tx.HasResponse {
+			s += " " + tx.R5().String()
+		}
+	} else if tx.HasResponse {
+		s += fmt.Sprintf(" resp=%#08x", tx.Response)
+	}
+	if tx.Err != nil {
+		s += " err=" + tx.Err.Error()
+	}
+	return s
+}
+
+func (tx *TxSDIO) fail(err error) {
+	if tx.Err == nil {
+		tx.Err = err
+	}
+}
+
+// SDIO decodes SD bus commands, responses and data blocks on 1-bit and 4-bit buses,
+// with CMD52 and CMD53 arguments decoded as CYW43439 function accesses. The fields
+// describe the bus configuration at the start of the capture. Bus width, block sizes
+// and the backplane window are then tracked as the host writes to the CCCR, FBR and
+// backplane window registers, without modifying the fields.
+type SDIO struct {
+	// Wide is set if the bus is in 4-bit mode.
+	Wide bool
+	// BlockSize is the CMD53 block size of all functions. Zero means 512.
+	BlockSize int
+	// BackplaneWindow is the base address of the backplane window.
+	BackplaneWindow uint32
+}
+
+// sdioBits holds the CMD and DAT lines sampled on CLK rising edges.
+type sdioBits struct {
+	times []float64
+	cmd   []bool
+	// dat holds DAT0 to DAT3 in bits 0 to 3.
+	dat []uint8
+}
+
+func (b *sdioBits) bytes(start, n int) []byte {
+	buf := make([]byte, (n+7)/8)
+	for i := 0; i < n; i++ {
+		if b.cmd[start+i] {
+			buf[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return buf
+}
+
+// sdioToken is a command or response on the CMD line starting at sample start.
+type sdioToken struct {
+	start, end int
+	host       bool
+	raw        []byte
+}
+
+// Scan decodes all commands found on the SD bus. DAT1 to DAT3 may be nil for 1-bit buses.
+func (s *SDIO) Scan(clk, cmd, dat0, dat1, dat2, dat3 *saleae.DigitalFile) (txs []TxSDIO, err error) {
+	if clk == nil || cmd == nil || dat0 == nil {
+		return nil, errors.New("sdio: got nil digital file")
+	}
+	hasDAT := dat1 != nil && dat2 != nil && dat3 != nil
+	if s.Wide && !hasDAT {
+		return nil, errSDIONoDATLines
+	}
+	if s.BlockSize < 0 || s.BlockSize > 2048 {
+		return nil, errors.New("sdio: block size must be in range 0..2048")
+	}
+	var (
+		b     sdioBits
+		sclk  = newSignal(clk)
+		scmd  = newSignal(cmd)
+		sdat  [4]*signal
+		t     = math.Inf(-1)
+		files = [4]*saleae.DigitalFile{dat0, dat1, dat2, dat3}
+	)
+	for i, f := range files {
+		if f != nil {
+			sig := newSignal(f)
+			sdat[i] = &sig
+		}
+	}
+	for {
+		t = sclk.nextTo(t, true)
+		if math.IsInf(t, 1) {
+			break
+		}
+		var nib uint8
+		for i, d := range sdat {
+			if d == nil || d.at(t) {
+				nib |= 1 << i
+			}
+		}
+		b.times = append(b.times, t)
+		b.cmd = append(b.cmd, scmd.at(t))
+		b.dat = append(b.dat, nib)
+	}
+	tokens := b.tokens()
+
+	st := sdioState{wide: s.Wide, window: s.BackplaneWindow}
+	for i := range st.blockSize {
+		st.blockSize[i] = s.BlockSize
+		if st.blockSize[i] == 0 {
+			st.blockSize[i] = 512
+		}
+	}
+	app := false
+	for k := 0; k < len(tokens); k++ {
+		tok := tokens[k]
+		if !tok.host {
+			continue // Response without command.
+		}
+		tx := TxSDIO{Interval: NewInterval(b.times[tok.start], b.times[tok.end])}
+		tx.Command = CmdSD{Index: tok.raw[0] & 0x3f, App: app}
+		tx.Arg = binary.BigEndian.Uint32(tok.raw[1:])
+		app = tx.Command == SDAppCmd
+		if !b.cmd[tok.end] {
+			tx.fail(errSDIOEnd)
+		}
+		if crc7(tok.raw[:5]) != tok.raw[5]>>1 {
+			tx.fail(errSDIOCRC7)
+		}
+		if k+1 < len(tokens) && !tokens[k+1].host {
+			k++
+			s.decodeResponse(&b, &tx, tokens[k])
+		}
+		switch tx.Command {
+		case SDIORWDirect:
+			tx.Cmd = DecodeCmd52SDIO(tx.Arg)
+			if tx.Cmd.Write {
+				tx.Data = []byte{tx.Cmd.Data}
+			} else if tx.HasResponse {
+				tx.Data = []byte{uint8(tx.Response)}
+			}
+		case SDIORWExtended:
+			tx.Cmd = DecodeCmd53SDIO(tx.Arg)
+			limit := len(b.times)
+			for _, next := range tokens[k+1:] {
+				if next.host && next.raw[0]&0x3f == SDIORWExtended.Index {
+					limit = next.start
+					break
+				}
+			}
+			if st.wide && !hasDAT {
+				tx.fail(errSDIONoDATLines)
+				break
+			}
+			n, size := 1, int(tx.Cmd.Size)
+			if tx.Cmd.Block {
+				n, size = size, st.blockSize[tx.Cmd.Fn]
+			} else if size == 0 {
+				size = 512
+			}
+			b.readBlocks(&tx, tok.end+1, limit, n, size, st.wide)
+		}
+		if tx.IsIO() && tx.HasResponse && tx.R5()&r5Errors != 0 {
+			tx.fail(errSDIOResponse)
+		}
+		if tx.IsIO() && tx.Cmd.Fn == FuncBackplane && tx.Cmd.Addr < 0x10000 {
+			tx.HasBackplaneAddr = true
+			tx.BackplaneAddr = st.window&^gspiBackplaneWindowMask | tx.Cmd.Addr&gspiBackplaneWindowMask
+		}
+		if tx.IsIO() && tx.Cmd.Write {
+			st.track(tx.Cmd, tx.Data)
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+// tokens splits the CMD line into commands and responses. Responses following
+// CMD2, CMD9 and CMD10 are 136-bit R2 responses.
+func (b *sdioBits) tokens() (tokens []sdioToken) {
+	var last uint8 = 0xff // Index of the last command.
+	for i := 0; i+1 < len(b.cmd); {
+		if b.cmd[i] {
+			i++
+			continue
+		}
+		host := b.cmd[i+1]
+		n := 48
+		if !host && (last == SDAllSendCID.Index || last == SDSendCSD.Index || last == SDSendCID.Index) {
+			n = 136
+		}
+		if i+n > len(b.cmd) {
+			break
+		}
+		tok := sdioToken{start: i, end: i + n - 1, host: host, raw: b.bytes(i, n)}
+		if host {
+			last = tok.raw[0] & 0x3f
+		}
+		tokens = append(tokens, tok)
+		i += n
+	}
+	return tokens
+}
+
+func (s *SDIO) decodeResponse(b *sdioBits, tx *TxSDIO, tok sdioToken) {
+	tx.HasResponse = true
+	tx.end = b.times[tok.end]
+	if !b.cmd[tok.end] {
+		tx.fail(errSDIOEnd)
+	}
+	if len(tok.raw) == 17 {
+		// R2: CID or CSD register with its own CRC7.
+		tx.LongResponse = tok.raw[1:]
+		if crc7(tok.raw[1:16]) != tok.raw[16]>>1 {
+			tx.fail(errSDIOCRC7)
+		}
+		return
+	}
+	tx.Response = binary.BigEndian.Uint32(tok.raw[1:])
+	index := tok.raw[0] & 0x3f
+	if index == 0x3f {
+		return // R3 and R4 responses have no index and CRC.
+	}
+	if index != tx.Command.Index {
+		tx.fail(errSDIORespIndex)
+	}
+	if crc7(tok.raw[:5]) != tok.raw[5]>>1 {
+		tx.fail(errSDIOCRC7)
+	}
+}
+
+// readBlocks decodes up to n blocks of size bytes starting after sample i and before
+// sample limit. A block count of zero reads blocks until limit.
+func (b *sdioBits) readBlocks(tx *TxSDIO, i, limit, n, size int, wide bool) {
+	var mask uint8 = 0b0001
+	if wide {
+		mask = 0b1111
+	}
+	for count := 0; n == 0 || count < n; count++ {
+		for i < limit && b.dat[i]&mask != 0 {
+			i++
+		}
+		if i >= limit {
+			if n != 0 {
+				tx.fail(errSDIOMissing)
+			}
+			return
+		}
+		var blk BlockSDIO
+		blk, i = b.block(i, size, wide)
+		if tx.Cmd.Write && blk.Err != errSDIOShort {
+			i = b.crcStatus(&blk, i)
+		}
+		tx.Blocks = append(tx.Blocks, blk)
+		tx.Data = append(tx.Data, blk.Data...)
+		tx.fail(blk.Err)
+		if blk.Err == errSDIOShort {
+			return
+		}
+	}
+}
+
+// block decodes a data block of size bytes with its start bit at sample i and
+// returns the sample following its end bit.
+func (b *sdioBits) block(i, size int, wide bool) (blk BlockSDIO, next int) {
+	lines, clocks := 1, size*8
+	if wide {
+		lines, clocks = 4, size*2
+	}
+	end := i + clocks + 17
+	if end >= len(b.dat) {
+		blk.Interval = NewInterval(b.times[i], b.times[len(b.times)-1])
+		blk.Err = errSDIOShort
+		return blk, len(b.dat)
+	}
+	blk.Interval = NewInterval(b.times[i], b.times[end])
+	blk.Data = make([]byte, size)
+	var crc [4]uint16
+	for k := 0; k < clocks; k++ {
+		v := b.dat[i+1+k]
+		if wide {
+			blk.Data[k/2] |= (v & 0xf) << (4 * (1 - k%2))
+		} else {
+			blk.Data[k/8] |= (v & 1) << (7 - k%8)
+		}
+		for line := 0; line < lines; line++ {
+			crc[line] = crc16Bit(crc[line], v>>line&1 != 0)
+		}
+	}
+	for k := 0; k < 16; k++ {
+		v := b.dat[i+1+clocks+k]
+		for line := 0; line < lines; line++ {
+			blk.CRC[line] = blk.CRC[line]<<1 | uint16(v>>line&1)
+		}
+	}
+	if crc != blk.CRC {
+		blk.Err = errSDIODataCRC
+	}
+	if b.dat[end]&1 == 0 {
+		blk.Err = errSDIOEnd
+	}
+	return blk, end + 1
+}
+
+// crcStatus decodes the CRC status token sent on DAT0 after a written block at
+// sample i and skips the busy signal that follows. It returns the sample after busy.
+func (b *sdioBits) crcStatus(blk *BlockSDIO, i int) int {
+	j := i
+	for j < len(b.dat) && j < i+sdioNCRCMax && b.dat[j]&1 != 0 {
+		j++
+	}
+	if j >= len(b.dat) || j == i+sdioNCRCMax || j+4 >= len(b.dat) {
+		if blk.Err == nil {
+			blk.Err = errSDIONoCRCStatus
+		}
+		return i
+	}
+	for k := 1; k <= 3; k++ {
+		blk.Status = blk.Status<<1 | b.dat[j+k]&1
+	}
+	blk.end = b.times[j+4]
+	if blk.Status != 0b010 && blk.Err == nil {
+		blk.Err = errSDIOCRCStatus
+	}
+	j += 5
+	for j < len(b.dat) && b.dat[j]&1 == 0 {
+		j++ // Busy.
+	}
+	return j
+}
+
+// crc16Bit shifts a single bit into the CRC16 used by SD bus data lines.
+func crc16Bit(crc uint16, bit bool) uint16 {
+	if (crc&0x8000 != 0) != bit {
+		return crc<<1 ^ 0x1021
+	}
+	return crc << 1
+}
+
+// sdioState is the bus configuration tracked during a capture.
+type sdioState struct {
+	wide      bool
+	window    uint32
+	blockSize [8]int
+}
+
+// track updates the bus configuration with data written by CMD52 and CMD53.
+func (st *sdioState) track(cmd CmdSDIO, data []byte) {
+	for i, v := range data {
+		addr := cmd.Addr
+		if cmd.AutoInc {
+			addr += uint32(i)
+		}
+		switch {
+		case cmd.Fn == FuncBus && addr == sdioRegBusInterface:
+			st.wide = v&sdioBusWidthMask == sdioBusWidth4
+		case cmd.Fn == FuncBus && addr < 0x800 && addr&0xff == sdioRegBlockSizeLow:
+			fn := addr >> 8
+			st.blockSize[fn] = st.blockSize[fn]&^0xff | int(v)
+		case cmd.Fn == FuncBus && addr < 0x800 && addr&0xff == sdioRegBlockSizeHigh:
+			fn := addr >> 8
+			st.blockSize[fn] = st.blockSize[fn]&0xff | int(v)<<8
+		case cmd.Fn == FuncBackplane && addr == gspiRegBackplaneAddrLow:
+			st.window = st.window&^0xff00 | uint32(v)<<8
+		case cmd.Fn == FuncBackplane && addr == gspiRegBackplaneAddrMid:
+			st.window = st.window&^0xff0000 | uint32(v)<<16
+		case cmd.Fn == FuncBackplane && addr == gspiRegBackplaneAddrHigh:
+			st.window = st.window&^0xff000000 | uint32(v)<<24
+		}
+	}
+}
+
+func init() {
+	Register("SDIO", func() Analyzer { return &SDIO{} })
+}
+
+// Channels implements Analyzer.
+func (*SDIO) Channels() []Channel {
+	return []Channel{
+		{Name: "clk"}, {Name: "cmd"}, {Name: "dat0"},
+		{Name: "dat1", Optional: true}, {Name: "dat2", Optional: true}, {Name: "dat3", Optional: true},
+	}
+}
+
+// Settings implements Analyzer.
+func (s *SDIO) Settings() []Setting {
+	return []Setting{
+		{Name: "wide", Usage: "bus starts in 4-bit mode", Value: strconv.FormatBool(s.Wide)},
+		{Name: "blocksize", Usage: "CMD53 block size at start, 0 means 512", Value: strconv.Itoa(s.BlockSize)},
+		{Name: "window", Usage: "backplane window base address at start", Value: "0x" + strconv.FormatUint(uint64(s.BackplaneWindow), 16)},
+	}
+}
+
+// Set implements Analyzer.
+func (s *SDIO) Set(name, value string) (err error) {
+	switch name {
+	case "wide":
+		s.Wide, err = strconv.ParseBool(value)
+	case "blocksize":
+		s.BlockSize, err = strconv.Atoi(value)
+	case "window":
+		var v uint64
+		v, err = strconv.ParseUint(value, 0, 32)
+		s.BackplaneWindow = uint32(v)
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Each command is returned as a frame of type "command"
+// with its response, followed by a frame of type "block" for each data block. CMD52
+// and CMD53 frames include the decoded function, address, size and data.
+func (s *SDIO) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(s, channels)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := s.Scan(in[0], in[1], in[2], in[3], in[4], in[5])
+	if err != nil {
+		return nil, err
+	}
+	var frames []Frame
+	for _, tx := range txs {
+		data := map[string]any{
+			"command":  tx.Command.String(),
+			"name":     tx.Command.Name(),
+			"argument": tx.Arg,
+		}
+		if tx.HasResponse {
+			data["response"] = tx.Response
+		}
+		if len(tx.LongResponse) > 0 {
+			data["long_response"] = tx.LongResponse
+		}
+		if tx.IsIO() {
+			data["write"] = tx.Cmd.Write
+			data["autoinc"] = tx.Cmd.AutoInc
+			data["block"] = tx.Cmd.Block
+			data["function"] = tx.Cmd.Fn.String()
+			data["address"] = tx.Cmd.Addr
+			data["size"] = tx.Cmd.Size
+			data["data"] = tx.Data
+		}
+		if tx.HasBackplaneAddr {
+			data["backplane_address"] = tx.BackplaneAddr
+		}
+		frames = append(frames, Frame{Interval: tx.Interval, Type: "command", Data: data, Err: tx.Err})
+		for _, b := range tx.Blocks {
+			frames = append(frames, Frame{
+				Interval: b.Interval,
+				Type:     "block",
+				Data:     map[string]any{"data": b.Data, "crc": b.CRC[:]},
+				Err:      b.Err,
+			})
+		}
+	}
+	return frames, nil
+}
@@ -0,0 +1,190 @@
+package analyzers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/soypat/saleae"
+)
+
+// sdioWave builds CLK, CMD and DAT0..3 bit strings, one CLK cycle per bit.
+type sdioWave struct {
+	clockWave
+}
+
+func (w *sdioWave) clock(cmd bool, dat uint8) {
+	w.cycle(cmd, dat&1 != 0, dat&2 != 0, dat&4 != 0, dat&8 != 0)
+}
+
+func (w *sdioWave) idle(n int) {
+	for i := 0; i < n; i++ {
+		w.clock(true, 0xf)
+	}
+}
+
+// token sends a 48-bit command or response. R4 responses have no index and CRC.
+func (w *sdioWave) token(host bool, index uint8, arg uint32) {
+	raw := []byte{index, byte(arg >> 24), byte(arg >> 16), byte(arg >> 8), byte(arg)}
+	if host {
+		raw[0] |= 0x40
+	}
+	raw = append(raw, crc7(raw)<<1|1)
+	if index == 0x3f {
+		raw[5] = 0xff
+	}
+	for _, b := range raw {
+		for i := 7; i >= 0; i-- {
+			w.clock(b>>i&1 != 0, 0xf)
+		}
+	}
+	w.idle(2)
+}
+
+func (w *sdioWave) block(data []byte, wide bool, corrupt bool) {
+	w.clock(true, 0)
+	var crc [4]uint16
+	var nibs []uint8
+	for _, b := range data {
+		if wide {
+			nibs = append(nibs, b>>4, b&0xf)
+			continue
+		}
+		for i := 7; i >= 0; i-- {
+			nibs = append(nibs, 0xe|b>>i&1)
+		}
+	}
+	for _, v := range nibs {
+		for line := range crc {
+			crc[line] = crc16Bit(crc[line], v>>line&1 != 0)
+		}
+		w.clock(true, v)
+	}
+	if !wide {
+		crc[0] = crc16CCITT(data)
+	}
+	if corrupt {
+		crc[2] ^= 1
+	}
+	for i := 15; i >= 0; i-- {
+		var v uint8 = 0xf
+		if wide {
+			v = 0
+			for line := range crc {
+				v |= uint8(crc[line]>>i&1) << line
+			}
+		} else {
+			v = 0xe | uint8(crc[0]>>i&1)
+		}
+		w.clock(true, v)
+	}
+	w.clock(true, 0xf)
+}
+
+// status sends a CRC status token on DAT0 followed by busy.
+func (w *sdioWave) status(status uint8, busy int) {
+	w.idle(2)
+	w.clock(true, 0xe)
+	for i := 2; i >= 0; i-- {
+		w.clock(true, 0xe|status>>i&1)
+	}
+	w.clock(true, 0xf)
+	for i := 0; i < busy; i++ {
+		w.clock(true, 0xe)
+	}
+	w.idle(2)
+}
+
+func TestSDIO(t *testing.T) {
+	const (
+		r5Trn = 2 << 4 << 8 // Transfer state.
+		r5Cmd = 1 << 4 << 8 // Command state.
+	)
+	cmd52 := func(write bool, fn Function, addr uint32, data uint8) uint32 {
+		return uint32(b2u8(write))<<31 | uint32(fn)<<28 | addr<<9 | uint32(data)
+	}
+	cmd53 := func(write bool, fn Function, block bool, addr uint32, count uint32) uint32 {
+		return uint32(b2u8(write))<<31 | uint32(fn)<<28 | uint32(b2u8(block))<<27 | 1<<26 | addr<<9 | count
+	}
+	var w sdioWave
+	w.idle(8)
+	w.token(true, 5, 0)                     // IO_SEND_OP_COND.
+	w.token(false, 0x3f, 0x90ff8000)        // R4.
+	read1 := []byte{0xde, 0xad, 0xbe, 0xef} // 1-bit read.
+	w.token(true, 53, cmd53(false, FuncBackplane, false, 0x4, 4))
+	w.token(false, 53, r5Trn)
+	w.block(read1, false, false)
+	w.idle(4)
+	w.token(true, 52, cmd52(true, FuncBus, 0x07, 0x02)) // 4-bit bus.
+	w.token(false, 52, r5Cmd|0x02)
+	w.token(true, 52, cmd52(true, FuncBus, 0x210, 8)) // F2 block size.
+	w.token(false, 52, r5Cmd|8)
+	w.token(true, 52, cmd52(true, FuncBus, 0x211, 0))
+	w.token(false, 52, r5Cmd)
+	w.token(true, 52, cmd52(true, FuncBackplane, 0x1000a, 0x80)) // Window 0x8000.
+	w.token(false, 52, r5Cmd|0x80)
+	read4 := []byte{1, 2, 3, 4, 5, 6}
+	w.token(true, 53, cmd53(false, FuncBackplane, false, 0x10, 6))
+	w.token(false, 53, r5Trn)
+	w.block(read4, true, false)
+	w.idle(4)
+	write := []byte("0123456789abcdef")
+	w.token(true, 53, cmd53(true, FuncWLAN, true, 0, 2))
+	w.token(false, 53, r5Trn)
+	w.idle(2)
+	w.block(write[:8], true, false)
+	w.status(0b010, 5)
+	w.block(write[8:], true, true)
+	w.status(0b101, 0)
+	w.token(true, 52, cmd52(false, FuncBus, 0x1ffff, 0))
+	w.token(false, 52, r5Cmd|uint32(R5OutOfRange)<<8)
+	w.idle(4)
+
+	var dat [4]*saleae.DigitalFile
+	for i := range dat {
+		dat[i] = digitalFromBits(w.line(1+i), 1e-6)
+	}
+	var s SDIO
+	txs, err := s.Scan(digitalFromBits(w.clk.String(), 1e-6), digitalFromBits(w.line(0), 1e-6), dat[0], dat[1], dat[2], dat[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 9 {
+		for _, tx := range txs {
+			t.Log(tx)
+		}
+		t.Fatalf("expected 9 commands, got %d", len(txs))
+	}
+	if tx := txs[0]; tx.Command != SDIOSendOpCond || !tx.HasResponse || tx.Response != 0x90ff8000 || tx.Err != nil {
+		t.Errorf("unexpected CMD5: %v", tx)
+	}
+	if tx := txs[1]; !bytes.Equal(tx.Data, read1) || len(tx.Blocks) != 1 || tx.Err != nil ||
+		tx.Cmd.Fn != FuncBackplane || tx.Cmd.Addr != 0x4 || !tx.HasBackplaneAddr || tx.BackplaneAddr != 0x4 {
+		t.Errorf("unexpected 1-bit read: %v", tx)
+	}
+	if tx := txs[2]; !tx.Cmd.Write || tx.Cmd.Addr != 0x07 || tx.R5().State() != 1 || tx.Err != nil {
+		t.Errorf("unexpected CMD52 write: %v", tx)
+	}
+	if tx := txs[6]; !bytes.Equal(tx.Data, read4) || tx.BackplaneAddr != 0x8010 || tx.Err != nil {
+		t.Errorf("unexpected 4-bit read: %v", tx)
+	}
+	tx := txs[7]
+	if len(tx.Blocks) != 2 || !bytes.Equal(tx.Data, write) {
+		t.Fatalf("unexpected block write: %v", tx)
+	}
+	if b := tx.Blocks[0]; b.Status != 0b010 || b.Err != nil {
+		t.Errorf("first block: expected accepted status, got %#b %v", b.Status, b.Err)
+	}
+	if b := tx.Blocks[1]; b.Status != 0b101 || b.Err != errSDIODataCRC || tx.Err != errSDIODataCRC {
+		t.Errorf("second block: expected CRC error, got %#b %v", b.Status, b.Err)
+	}
+	if tx := txs[8]; tx.Err != errSDIOResponse || tx.R5()&R5OutOfRange == 0 {
+		t.Errorf("expected out of range response, got %v", tx)
+	}
+	if s.Wide || s.BackplaneWindow != 0 {
+		t.Errorf("scan modified start of capture state: %+v", s)
+	}
+	again, err := s.Scan(digitalFromBits(w.clk.String(), 1e-6), digitalFromBits(w.line(0), 1e-6), dat[0], dat[1], dat[2], dat[3])
+	if err != nil || len(again) != len(txs) || !bytes.Equal(again[1].Data, read1) {
+		t.Errorf("second scan differs: %v", err)
+	}
+}
@@ -0,0 +1,486 @@
+package analyzers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/soypat/saleae"
+)
+
+// ChannelSDPCM is the SDPCM channel a frame is sent on.
+type ChannelSDPCM uint8
+
+const (
+	// Control channel carrying CDC ioctl requests and responses.
+	SDPCMControl ChannelSDPCM = 0
+	// Asynchronous event channel.
+	SDPCMEvent ChannelSDPCM = 1
+	// Network data channel.
+	SDPCMData ChannelSDPCM = 2
+)
+
+func (c ChannelSDPCM) String() (s string) {
+	switch c {
+	case SDPCMControl:
+		s = "ctl"
+	case SDPCMEvent:
+		s = "evt"
+	case SDPCMData:
+		s = "dat"
+	default:
+		s = "ch" + strconv.Itoa(int(c))
+	}
+	return s
+}
+
+const (
+	sdpcmHeaderSize = 12
+	cdcHeaderSize   = 16
+	bdcHeaderSize   = 4
+	// Ethernet header, Broadcom ethernet header and event message.
+	eventHeaderSize = 14 + 10 + 48
+)
+
+// HeaderSDPCM is the header preceding all frames on the CYW43439 WLAN function.
+type HeaderSDPCM struct {
+	// Size of the frame including the header.
+	Size     uint16
+	Sequence uint8
+	Channel  ChannelSDPCM
+	// Upper 4 bits of the channel byte.
+	Flags      uint8
+	NextLength uint8
+	// HeaderLength is the offset of the payload from the start of the frame.
+	HeaderLength uint8
+	FlowControl  uint8
+	// Credit is the highest sequence number the device is ready to receive.
+	Credit uint8
+}
+
+// IoctlCmd is a WLC ioctl command number.
+type IoctlCmd uint32
+
+const (
+	IoctlGetMagic           IoctlCmd = 0
+	IoctlGetVersion         IoctlCmd = 1
+	IoctlUp                 IoctlCmd = 2
+	IoctlDown               IoctlCmd = 3
+	IoctlGetInfra           IoctlCmd = 19
+	IoctlSetInfra           IoctlCmd = 20
+	IoctlGetAuth            IoctlCmd = 21
+	IoctlSetAuth            IoctlCmd = 22
+	IoctlGetBSSID           IoctlCmd = 23
+	IoctlSetBSSID           IoctlCmd = 24
+	IoctlGetSSID            IoctlCmd = 25
+	IoctlSetSSID            IoctlCmd = 26
+	IoctlGetChannel         IoctlCmd = 29
+	IoctlSetChannel         IoctlCmd = 30
+	IoctlDisassoc           IoctlCmd = 52
+	IoctlGetAntDiv          IoctlCmd = 63
+	IoctlSetAntDiv          IoctlCmd = 64
+	IoctlSetDTIMPeriod      IoctlCmd = 78
+	IoctlGetPM              IoctlCmd = 85
+	IoctlSetPM              IoctlCmd = 86
+	IoctlSetGMode           IoctlCmd = 110
+	IoctlSetAP              IoctlCmd = 118
+	IoctlGetRSSI            IoctlCmd = 127
+	IoctlSetWsec            IoctlCmd = 134
+	IoctlSetBand            IoctlCmd = 142
+	IoctlSetWPAAuth         IoctlCmd = 165
+	IoctlSetScanChannelTime IoctlCmd = 185
+	IoctlGetVar             IoctlCmd = 262
+	IoctlSetVar             IoctlCmd = 263
+	IoctlSetWsecPMK         IoctlCmd = 268
+)
+
+var ioctlNames = map[IoctlCmd]string{
+	IoctlGetMagic: "GET_MAGIC", IoctlGetVersion: "GET_VERSION", IoctlUp: "UP", IoctlDown: "DOWN",
+	IoctlGetInfra: "GET_INFRA", IoctlSetInfra: "SET_INFRA", IoctlGetAuth: "GET_AUTH", IoctlSetAuth: "SET_AUTH",
+	IoctlGetBSSID: "GET_BSSID", IoctlSetBSSID: "SET_BSSID", IoctlGetSSID: "GET_SSID", IoctlSetSSID: "SET_SSID",
+	IoctlGetChannel: "GET_CHANNEL", IoctlSetChannel: "SET_CHANNEL", IoctlDisassoc: "DISASSOC",
+	IoctlGetAntDiv: "GET_ANTDIV", IoctlSetAntDiv: "SET_ANTDIV", IoctlSetDTIMPeriod: "SET_DTIMPRD",
+	IoctlGetPM: "GET_PM", IoctlSetPM: "SET_PM", IoctlSetGMode: "SET_GMODE", IoctlSetAP: "SET_AP",
+	IoctlGetRSSI: "GET_RSSI", IoctlSetWsec: "SET_WSEC", IoctlSetBand: "SET_BAND", IoctlSetWPAAuth: "SET_WPA_AUTH",
+	IoctlSetScanChannelTime: "SET_SCAN_CHANNEL_TIME", IoctlGetVar: "GET_VAR", IoctlSetVar: "SET_VAR",
+	IoctlSetWsecPMK: "SET_WSEC_PMK",
+}
+
+func (c IoctlCmd) String() string {
+	if name, ok := ioctlNames[c]; ok {
+		return name
+	}
+	return "IOCTL_" + strconv.Itoa(int(c))
+}
+
+// HeaderCDC is the header of control channel frames.
+type HeaderCDC struct {
+	Cmd IoctlCmd
+	// Length of the request and response buffers.
+	OutLen, InLen uint16
+	Flags         uint32
+	Status        int32
+}
+
+// ID returns the request identifier used to pair requests with responses.
+func (h HeaderCDC) ID() uint16 { return uint16(h.Flags >> 16) }
+
+// Set returns true for set requests and false for get requests.
+func (h HeaderCDC) Set() bool { return h.Flags&(1<<1) != 0 }
+
+// Error returns true if the device reported the ioctl failed.
+func (h HeaderCDC) Error() bool { return h.Flags&(1<<0) != 0 }
+
+// Interface returns the interface index the ioctl is addressed to.
+func (h HeaderCDC) Interface() uint8 { return uint8(h.Flags>>12) & 0xf }
+
+// EventType is a WLC event type.
+type EventType uint32
+
+var eventNames = [...]string{
+	0: "SET_SSID", 1: "JOIN", 2: "START", 3: "AUTH", 4: "AUTH_IND", 5: "DEAUTH", 6: "DEAUTH_IND",
+	7: "ASSOC", 8: "ASSOC_IND", 9: "REASSOC", 10: "REASSOC_IND", 11: "DISASSOC", 12: "DISASSOC_IND",
+	13: "QUIET_START", 14: "QUIET_END", 15: "BEACON_RX", 16: "LINK", 17: "MIC_ERROR", 18: "NDIS_LINK",
+	19: "ROAM", 20: "TXFAIL", 21: "PMKID_CACHE", 22: "RETROGRADE_TSF", 23: "PRUNE", 24: "AUTOAUTH",
+	25: "EAPOL_MSG", 26: "SCAN_COMPLETE", 27: "ADDTS_IND", 28: "DELTS_IND", 29: "BCNSENT_IND",
+	30: "BCNRX_MSG", 31: "BCNLOST_MSG", 32: "ROAM_PREP", 33: "PFN_NET_FOUND", 34: "PFN_NET_LOST",
+	35: "RESET_COMPLETE", 36: "JOIN_START", 37: "ROAM_START", 38: "ASSOC_START", 39: "IBSS_ASSOC",
+	40: "RADIO", 44: "PROBREQ_MSG", 46: "PSK_SUP", 54: "IF", 56: "RSSI", 69: "ESCAN_RESULT",
+}
+
+func (e EventType) String() string {
+	if int(e) < len(eventNames) && eventNames[e] != "" {
+		return eventNames[e]
+	}
+	return "EVENT_" + strconv.Itoa(int(e))
+}
+
+// EventWLAN is an asynchronous event sent by the device on the event channel.
+type EventWLAN struct {
+	Type      EventType
+	Flags     uint16
+	Status    uint32
+	Reason    uint32
+	AuthType  uint32
+	Addr      [6]byte
+	IfName    string
+	IfIdx     uint8
+	BssCfgIdx uint8
+	// Event specific data.
+	Data []byte
+}
+
+var (
+	errSDPCMSize      = errors.New("sdpcm: size and size complement mismatch")
+	errSDPCMTruncated = errors.New("sdpcm: frame truncated")
+)
+
+// FrameSDPCM is an SDPCM frame sent over the CYW43439 WLAN function.
+type FrameSDPCM struct {
+	Interval
+	// Write is set for frames sent by the host to the device.
+	Write  bool
+	Header HeaderSDPCM
+	// Payload following the SDPCM header. For control frames it follows the CDC header and
+	// for event and data frames it follows the BDC header.
+	Payload []byte
+	// CDC is the header of control channel frames.
+	CDC *HeaderCDC
+	// Iovar is the variable name of GET_VAR and SET_VAR requests.
+	Iovar string
+	// Event is the decoded event of event channel frames.
+	Event *EventWLAN
+	Err   error
+}
+
+func (f FrameSDPCM) String() string {
+	dir := "rx"
+	if f.Write {
+		dir = "tx"
+	}
+	s := fmt.Sprintf("%s %s seq=%d", dir, f.Header.Channel, f.Header.Sequence)
+	switch {
+	case f.Err != nil:
+		s += " " + f.Err.Error()
+	case f.CDC != nil:
+		s += fmt.Sprintf(" ioctl %s id=%d", f.CDC.Cmd, f.CDC.ID())
+		if f.Iovar != "" {
+			s += " " + strconv.Quote(f.Iovar)
+		}
+		if !f.Write && f.CDC.Error() {
+			s += " status=" + strconv.Itoa(int(f.CDC.Status))
+		}
+	case f.Event != nil:
+		s += fmt.Sprintf(" event %s status=%d reason=%d", f.Event.Type, f.Event.Status, f.Event.Reason)
+	}
+	return s + " len=" + strconv.Itoa(len(f.Payload))
+}
+
+// Ioctl is a CDC ioctl request paired with its response.
+type Ioctl struct {
+	// Interval spans from the start of the request to the end of the response.
+	Interval
+	Cmd   IoctlCmd
+	Set   bool
+	Iovar string
+	ID    uint16
+	// Request and response payloads. Iovar names are not included in Request.
+	Request  []byte
+	Response []byte
+	// HasResponse is set if a response with the request ID was found.
+	HasResponse bool
+	// Status returned by the device. Negative values are errors.
+	Status int32
+}
+
+func (io Ioctl) String() string {
+	s := "ioctl " + io.Cmd.String()
+	if io.Iovar != "" {
+		s += " " + strconv.Quote(io.Iovar)
+	}
+	s += fmt.Sprintf(" req=%#x", io.Request)
+	if !io.HasResponse {
+		return s + " no response"
+	}
+	return s + fmt.Sprintf(" resp=%#x status=%d", io.Response, io.Status)
+}
+
+// SDPCM decodes the SDPCM frames carried by CYW43439 WLAN function (F2) transfers.
+type SDPCM struct {
+	GSPI GSPI
+}
+
+// Scan decodes all SDPCM frames found on the SPI signals.
+func (s *SDPCM) Scan(clock, enable, sdo, sdi *saleae.DigitalFile) ([]FrameSDPCM, error) {
+	txs, err := s.GSPI.Scan(clock, enable, sdo, sdi)
+	if err != nil {
+		return nil, err
+	}
+	return s.Decode(txs)
+}
+
+// Decode reassembles WLAN function transfers into SDPCM frames. Frames split
+// across several transfers in the same direction are joined.
+func (s *SDPCM) Decode(txs []TxGSPI) (frames []FrameSDPCM, err error) {
+	// Pending partial frame for each direction, indexed by write.
+	var pending [2]struct {
+		buf   []byte
+		start float64
+	}
+	for _, tx := range txs {
+		if tx.Cmd.Fn != FuncWLAN {
+			continue
+		}
+		p := &pending[b2u8(tx.Cmd.Write)]
+		start := tx.StartTime()
+		buf := tx.Data
+		if len(p.buf) > 0 {
+			buf = append(p.buf, buf...)
+			start = p.start
+			p.buf = nil
+		}
+		for len(buf) >= 4 {
+			size := binary.LittleEndian.Uint16(buf)
+			sizecom := binary.LittleEndian.Uint16(buf[2:])
+			if size == 0 && sizecom == 0 {
+				break // Padding.
+			}
+			frame := FrameSDPCM{Write: tx.Cmd.Write, Interval: NewInterval(start, tx.EndTime())}
+			if size^sizecom != 0xffff || size < sdpcmHeaderSize {
+				frame.Err = errSDPCMSize
+				frames = append(frames, frame)
+				break
+			}
+			if int(size) > len(buf) {
+				// Frame continues in next transfer.
+				p.buf = append([]byte(nil), buf...)
+				p.start = start
+				break
+			}
+			decodeSDPCM(&frame, buf[:size])
+			frames = append(frames, frame)
+			buf = buf[size:]
+		}
+	}
+	for i := range pending {
+		if len(pending[i].buf) > 0 {
+			frame := FrameSDPCM{Write: i == 1, Err: errSDPCMTruncated}
+			frame.start = pending[i].start
+			frame.end = frame.start
+			frames = append(frames, frame)
+		}
+	}
+	return frames, nil
+}
+
+func decodeSDPCM(f *FrameSDPCM, b []byte) {
+	f.Header = HeaderSDPCM{
+		Size:         binary.LittleEndian.Uint16(b),
+		Sequence:     b[4],
+		Channel:      ChannelSDPCM(b[5] & 0xf),
+		Flags:        b[5] >> 4,
+		NextLength:   b[6],
+		HeaderLength: b[7],
+		FlowControl:  b[8],
+		Credit:       b[9],
+	}
+	if int(f.Header.HeaderLength) > len(b) || f.Header.HeaderLength < sdpcmHeaderSize {
+		f.Err = errSDPCMTruncated
+		return
+	}
+	payload := b[f.Header.HeaderLength:]
+	switch f.Header.Channel {
+	case SDPCMControl:
+		if len(payload) < cdcHeaderSize {
+			f.Err = errSDPCMTruncated
+			return
+		}
+		outlen := binary.LittleEndian.Uint32(payload[4:])
+		f.CDC = &HeaderCDC{
+			Cmd:    IoctlCmd(binary.LittleEndian.Uint32(payload)),
+			OutLen: uint16(outlen),
+			InLen:  uint16(outlen >> 16),
+			Flags:  binary.LittleEndian.Uint32(payload[8:]),
+			Status: int32(binary.LittleEndian.Uint32(payload[12:])),
+		}
+		payload = payload[cdcHeaderSize:]
+		if f.Write && (f.CDC.Cmd == IoctlGetVar || f.CDC.Cmd == IoctlSetVar) {
+			if i := bytes.IndexByte(payload, 0); i >= 0 {
+				f.Iovar = string(payload[:i])
+			}
+		}
+	case SDPCMEvent, SDPCMData:
+		if len(payload) < bdcHeaderSize {
+			f.Err = errSDPCMTruncated
+			return
+		}
+		off := bdcHeaderSize + 4*int(payload[3])
+		if off > len(payload) {
+			f.Err = errSDPCMTruncated
+			return
+		}
+		payload = payload[off:]
+		if f.Header.Channel == SDPCMEvent {
+			f.Event, f.Err = decodeEventWLAN(payload)
+		}
+	}
+	f.Payload = payload
+}
+
+func decodeEventWLAN(b []byte) (*EventWLAN, error) {
+	if len(b) < eventHeaderSize {
+		return nil, errSDPCMTruncated
+	}
+	msg := b[14+10:] // Skip ethernet and Broadcom ethernet headers.
+	ev := &EventWLAN{
+		Flags:     binary.BigEndian.Uint16(msg[2:]),
+		Type:      EventType(binary.BigEndian.Uint32(msg[4:])),
+		Status:    binary.BigEndian.Uint32(msg[8:]),
+		Reason:    binary.BigEndian.Uint32(msg[12:]),
+		AuthType:  binary.BigEndian.Uint32(msg[16:]),
+		IfIdx:     msg[46],
+		BssCfgIdx: msg[47],
+	}
+	datalen := binary.BigEndian.Uint32(msg[20:])
+	copy(ev.Addr[:], msg[24:30])
+	ev.IfName = string(bytes.TrimRight(msg[30:46], "\x00"))
+	data := msg[48:]
+	if uint32(len(data)) < datalen {
+		return ev, errSDPCMTruncated
+	}
+	ev.Data = data[:datalen]
+	return ev, nil
+}
+
+// PairIoctls pairs the control channel requests in frames with their responses.
+func PairIoctls(frames []FrameSDPCM) []Ioctl {
+	var ioctls []Ioctl
+	pending := make(map[uint16]int) // Request ID to index in ioctls.
+	for _, f := range frames {
+		if f.CDC == nil {
+			continue
+		}
+		id := f.CDC.ID()
+		if f.Write {
+			req := f.Payload
+			if f.Iovar != "" {
+				req = req[len(f.Iovar)+1:]
+			}
+			pending[id] = len(ioctls)
+			ioctls = append(ioctls, Ioctl{
+				Interval: f.Interval,
+				Cmd:      f.CDC.Cmd,
+				Set:      f.CDC.Set(),
+				Iovar:    f.Iovar,
+				ID:       id,
+				Request:  req,
+			})
+			continue
+		}
+		i, ok := pending[id]
+		if !ok {
+			continue // Response to request outside of capture.
+		}
+		delete(pending, id)
+		io := &ioctls[i]
+		io.end = f.EndTime()
+		io.HasResponse = true
+		io.Response = f.Payload
+		io.Status = f.CDC.Status
+	}
+	return ioctls
+}
+
+func init() {
+	Register("SDPCM", func() Analyzer { return &SDPCM{} })
+}
+
+// Channels implements Analyzer.
+func (s *SDPCM) Channels() []Channel { return s.GSPI.Channels() }
+
+// Settings implements Analyzer. The settings are those of the underlying gSPI analyzer.
+func (s *SDPCM) Settings() []Setting { return s.GSPI.Settings() }
+
+// Set implements Analyzer.
+func (s *SDPCM) Set(name, value string) error { return s.GSPI.Set(name, value) }
+
+// Analyze implements Analyzer. Each SDPCM frame is returned as a frame of type
+// "control", "event" or "data" depending on the SDPCM channel.
+func (s *SDPCM) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(s, channels)
+	if err != nil {
+		return nil, err
+	}
+	sframes, err := s.Scan(in[0], in[1], in[2], in[3])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(sframes))
+	for i, sf := range sframes {
+		data := map[string]any{
+			"write":    sf.Write,
+			"sequence": sf.Header.Sequence,
+			"payload":  sf.Payload,
+		}
+		typ := "data"
+		switch {
+		case sf.CDC != nil:
+			typ = "control"
+			data["ioctl"] = sf.CDC.Cmd.String()
+			data["id"] = sf.CDC.ID()
+			data["status"] = sf.CDC.Status
+			if sf.Iovar != "" {
+				data["iovar"] = sf.Iovar
+			}
+		case sf.Event != nil:
+			typ = "event"
+			data["event"] = sf.Event.Type.String()
+			data["status"] = sf.Event.Status
+			data["reason"] = sf.Event.Reason
+		}
+		frames[i] = Frame{Interval: sf.Interval, Type: typ, Data: data, Err: sf.Err}
+	}
+	return frames, nil
+}
@@ -0,0 +1,85 @@
+package analyzers
+
+import (
+	"math"
+	"sort"
+
+	"github.com/soypat/saleae"
+)
+
+// signal walks over the transitions of a digital file in increasing time order
+// keeping track of the logic level of the channel.
+type signal struct {
+	data []float64
+	// idx is the index of the next transition not yet consumed.
+	idx   int
+	state bool
+}
+
+func newSignal(d *saleae.DigitalFile) signal {
+	return signal{data: d.Data, state: d.Header.InitialState != 0}
+}
+
+// at advances the signal to time t and returns the logic level at t. Calls
+// to at must be made with non-decreasing t.
+func (s *signal) at(t float64) bool {
+	for s.idx < len(s.data) && s.data[s.idx] <= t {
+		s.idx++
+		s.state = !s.state
+	}
+	return s.state
+}
+
+// next returns the time of the next transition not yet consumed by at or
+// +Inf if there are no more transitions.
+func (s *signal) next() float64 {
+	if s.idx >= len(s.data) {
+		return math.Inf(1)
+	}
+	return s.data[s.idx]
+}
+
+// nextTo returns the time of the next transition after t that leaves
+// the signal at level. It returns +Inf if there is no such transition.
+// The signal is advanced up to t.
+func (s *signal) nextTo(t float64, level bool) float64 {
+	s.at(t)
+	i := s.idx
+	if s.state == level {
+		i++ // Next transition goes away from level.
+	}
+	if i >= len(s.data) {
+		return math.Inf(1)
+	}
+	return s.data[i]
+}
+
+// levelAt returns the logic level of d at time t. Unlike signal it
+// can be called with times in any order.
+func levelAt(d *saleae.DigitalFile, t float64) bool {
+	n := sort.Search(len(d.Data), func(i int) bool { return d.Data[i] > t })
+	return (d.Header.InitialState != 0) != (n%2 == 1)
+}
+
+// edgeAfter returns the time of the first transition of d after t or +Inf if there is none.
+func edgeAfter(d *saleae.DigitalFile, t float64) float64 {
+	n := sort.Search(len(d.Data), func(i int) bool { return d.Data[i] > t })
+	if n >= len(d.Data) {
+		return math.Inf(1)
+	}
+	return d.Data[n]
+}
+
+// edgeToAfter returns the time of the first transition of d after t which leaves
+// the signal at level or +Inf if there is none.
+func edgeToAfter(d *saleae.DigitalFile, t float64, level bool) float64 {
+	n := sort.Search(len(d.Data), func(i int) bool { return d.Data[i] > t })
+	// Level after transition i is the initial level toggled i+1 times.
+	if (d.Header.InitialState != 0) != (n%2 == 0) != level {
+		n++
+	}
+	if n >= len(d.Data) {
+		return math.Inf(1)
+	}
+	return d.Data[n]
+}
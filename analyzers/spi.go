@@ -28,11 +28,18 @@ func (t TxSPI) EndTime() float64 {
 	return t.timings[len(t.timings)-1].end
 }
 
+// Interval is a span of time in seconds relative to the capture start.
 type Interval struct {
 	start float64
 	end   float64
 }
 
+// StartTime returns the time at which the interval begins.
+func (i Interval) StartTime() float64 { return i.start }
+
+// EndTime returns the time at which the interval ends.
+func (i Interval) EndTime() float64 { return i.end }
+
 // SPI can be used to analyze a digital signal for SPI transactions. For now
 // only supports MODE 0, MSB first, 8 bits per transfer, enable line active low.
 type SPI struct {
@@ -117,6 +124,43 @@ func (*SPI) Scan(clock, enable, mosi, miso *saleae.DigitalFile) (txs []TxSPI, er
 	return txs, nil
 }
 
+func init() {
+	Register("SPI", func() Analyzer { return &SPI{} })
+}
+
+// Channels implements Analyzer.
+func (*SPI) Channels() []Channel {
+	return []Channel{{Name: "clock"}, {Name: "enable"}, {Name: "mosi"}, {Name: "miso"}}
+}
+
+// Settings implements Analyzer. SPI has no settings.
+func (*SPI) Settings() []Setting { return nil }
+
+// Set implements Analyzer.
+func (*SPI) Set(name, value string) error { return errUnknownSetting(name) }
+
+// Analyze implements Analyzer. Each transaction is returned as a frame with
+// "mosi" and "miso" byte slices.
+func (s *SPI) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(s, channels)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := s.Scan(in[0], in[1], in[2], in[3])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(txs))
+	for i, tx := range txs {
+		frames[i] = Frame{
+			Interval: NewInterval(tx.StartTime(), tx.EndTime()),
+			Type:     "transaction",
+			Data:     map[string]any{"mosi": tx.SDO, "miso": tx.SDI},
+		}
+	}
+	return frames, nil
+}
+
 func b2u8(b bool) byte {
 	if b {
 		return 1
@@ -0,0 +1,349 @@
+package analyzers
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"strconv"
+
+	"github.com/soypat/saleae"
+)
+
+// SWDEvent is the kind of an SWD bus event.
+type SWDEvent uint8
+
+const (
+	// SWDEventTransfer is a request packet with its acknowledge and data phase.
+	SWDEventTransfer SWDEvent = iota
+	// SWDEventLineReset is 50 or more SWCLK cycles with SWDIO high.
+	SWDEventLineReset
+	// SWDEventJTAGToSWD is the 0xE79E JTAG to SWD switch sequence.
+	SWDEventJTAGToSWD
+	// SWDEventSWDToJTAG is the 0xE73C SWD to JTAG switch sequence.
+	SWDEventSWDToJTAG
+)
+
+func (e SWDEvent) String() (s string) {
+	switch e {
+	case SWDEventTransfer:
+		s = "transfer"
+	case SWDEventLineReset:
+		s = "line reset"
+	case SWDEventJTAGToSWD:
+		s = "JTAG-to-SWD"
+	case SWDEventSWDToJTAG:
+		s = "SWD-to-JTAG"
+	default:
+		s = "unknown"
+	}
+	return s
+}
+
+// SWDAck is the acknowledge response of an SWD target.
+type SWDAck uint8
+
+const (
+	SWDAckOK    SWDAck = 0b001
+	SWDAckWait  SWDAck = 0b010
+	SWDAckFault SWDAck = 0b100
+)
+
+func (a SWDAck) String() (s string) {
+	switch a {
+	case SWDAckOK:
+		s = "OK"
+	case SWDAckWait:
+		s = "WAIT"
+	case SWDAckFault:
+		s = "FAULT"
+	default:
+		s = "invalid(" + strconv.Itoa(int(a)) + ")"
+	}
+	return s
+}
+
+const (
+	swdLineResetBits = 50
+	swdJTAGToSWD     = 0xe79e
+	swdSWDToJTAG     = 0xe73c
+)
+
+var (
+	errSWDRequestParity = errors.New("swd: request parity error")
+	errSWDDataParity    = errors.New("swd: data parity error")
+	errSWDProtocol      = errors.New("swd: invalid acknowledge, target not responding")
+	errSWDIncomplete    = errors.New("swd: capture ends mid transfer")
+)
+
+// TxSWD is an SWD bus event. Request fields are only valid for transfers.
+type TxSWD struct {
+	Interval
+	Event SWDEvent
+	// APnDP is set for access port accesses.
+	APnDP bool
+	Read  bool
+	// Addr is the register address A[3:2] of the request in bytes.
+	Addr uint8
+	// Bank is the register bank selected by the DP SELECT register at the time
+	// of the transfer: APBANKSEL for AP accesses and DPBANKSEL for DP accesses.
+	Bank uint8
+	// APSel is the access port selected by the DP SELECT register.
+	APSel uint8
+	ACK   SWDAck
+	// Data is the transferred word, valid if ACK is OK. AP reads are posted so
+	// their data is the result of the previous AP read.
+	Data uint32
+	Err  error
+}
+
+// Register returns the name of the DP or MEM-AP register accessed by a transfer.
+func (tx TxSWD) Register() string {
+	if tx.Event != SWDEventTransfer {
+		return ""
+	}
+	if tx.APnDP {
+		if name, ok := memAPRegisters[tx.Bank<<4|tx.Addr]; ok {
+			return name
+		}
+		return "AP[0x" + strconv.FormatUint(uint64(tx.Bank)<<4|uint64(tx.Addr), 16) + "]"
+	}
+	switch tx.Addr {
+	case 0x0:
+		if tx.Read {
+			return "DPIDR"
+		}
+		return "ABORT"
+	case 0x4:
+		switch tx.Bank {
+		case 0:
+			return "CTRL/STAT"
+		case 1:
+			return "DLCR"
+		case 2:
+			return "TARGETID"
+		case 3:
+			return "DLPIDR"
+		case 4:
+			return "EVENTSTAT"
+		}
+		return "DP[0x4 bank " + strconv.Itoa(int(tx.Bank)) + "]"
+	case 0x8:
+		if tx.Read {
+			return "RESEND"
+		}
+		return "SELECT"
+	default:
+		if tx.Read {
+			return "RDBUFF"
+		}
+		return "TARGETSEL"
+	}
+}
+
+// memAPRegisters names MEM-AP registers by address.
+var memAPRegisters = map[uint8]string{
+	0x00: "CSW",
+	0x04: "TAR",
+	0x0c: "DRW",
+	0x10: "BD0",
+	0x14: "BD1",
+	0x18: "BD2",
+	0x1c: "BD3",
+	0xf4: "CFG",
+	0xf8: "BASE",
+	0xfc: "IDR",
+}
+
+// SWD can be used to analyze Serial Wire Debug traffic. SWDIO is sampled on
+// the rising edge of SWCLK for both host and target driven bits.
+type SWD struct {
+	// Turnaround is the number of turnaround cycles. Zero means 1.
+	Turnaround int
+}
+
+// swdBits holds SWDIO sampled on each rising edge of SWCLK.
+type swdBits struct {
+	v     []bool
+	times []float64
+}
+
+func (b *swdBits) word(i, n int) (w uint32) {
+	for j := 0; j < n; j++ {
+		if b.v[i+j] {
+			w |= 1 << j
+		}
+	}
+	return w
+}
+
+// interval returns the interval spanning bits i to j-1.
+func (b *swdBits) interval(i, j int) Interval {
+	return Interval{start: b.times[i], end: b.times[j-1]}
+}
+
+// Scan decodes all SWD events found on swclk and swdio.
+func (s *SWD) Scan(swclk, swdio *saleae.DigitalFile) (txs []TxSWD, err error) {
+	if swclk == nil || swdio == nil {
+		return nil, errors.New("swd: got nil digital file")
+	}
+	trn := s.Turnaround
+	if trn == 0 {
+		trn = 1
+	}
+	var (
+		b    swdBits
+		clk  = newSignal(swclk)
+		dio  = newSignal(swdio)
+		t    = math.Inf(-1)
+		sel  uint32 // DP SELECT register.
+		nbit int
+	)
+	for {
+		t = clk.nextTo(t, true)
+		if math.IsInf(t, 1) {
+			break
+		}
+		b.v = append(b.v, dio.at(t))
+		b.times = append(b.times, t)
+	}
+	nbit = len(b.v)
+	for i := 0; i < nbit; {
+		// Line reset and switch sequences.
+		run := 0
+		for i+run < nbit && b.v[i+run] {
+			run++
+		}
+		if run >= swdLineResetBits {
+			txs = append(txs, TxSWD{Interval: b.interval(i, i+run), Event: SWDEventLineReset})
+			i += run
+			if i+16 <= nbit {
+				switch b.word(i, 16) {
+				case swdJTAGToSWD:
+					txs = append(txs, TxSWD{Interval: b.interval(i, i+16), Event: SWDEventJTAGToSWD})
+					i += 16
+				case swdSWDToJTAG:
+					txs = append(txs, TxSWD{Interval: b.interval(i, i+16), Event: SWDEventSWDToJTAG})
+					i += 16
+				}
+			}
+			continue
+		}
+		// Request: start, APnDP, RnW, A[2:3], parity, stop, park.
+		if !b.v[i] || i+8 > nbit || b.v[i+6] || !b.v[i+7] {
+			i++
+			continue
+		}
+		req := b.word(i, 8)
+		tx := TxSWD{
+			Event: SWDEventTransfer,
+			APnDP: req&(1<<1) != 0,
+			Read:  req&(1<<2) != 0,
+			Addr:  uint8(req>>3&0b11) << 2,
+			APSel: uint8(sel >> 24),
+		}
+		if tx.APnDP {
+			tx.Bank = uint8(sel >> 4 & 0xf)
+		} else {
+			tx.Bank = uint8(sel & 0xf)
+		}
+		if bits.OnesCount32(req>>1&0xf)%2 != int(req>>5&1) {
+			tx.Err = errSWDRequestParity
+		}
+		start := i
+		i += 8 + trn
+		if i+3 > nbit {
+			tx.Interval = b.interval(start, nbit)
+			tx.Err = errSWDIncomplete
+			txs = append(txs, tx)
+			break
+		}
+		tx.ACK = SWDAck(b.word(i, 3))
+		i += 3
+		switch {
+		case tx.ACK != SWDAckOK:
+			if tx.ACK != SWDAckWait && tx.ACK != SWDAckFault && tx.Err == nil {
+				tx.Err = errSWDProtocol
+			}
+			i += trn
+		case i+33+trn > nbit:
+			tx.Err = errSWDIncomplete
+			i = nbit
+		default:
+			if !tx.Read {
+				i += trn
+			}
+			tx.Data = b.word(i, 32)
+			if bits.OnesCount32(tx.Data)%2 != int(b2u8(b.v[i+32])) && tx.Err == nil {
+				tx.Err = errSWDDataParity
+			}
+			i += 33
+			if tx.Read {
+				i += trn
+			}
+			if !tx.APnDP && !tx.Read && tx.Addr == 0x8 {
+				sel = tx.Data
+			}
+		}
+		if i > nbit {
+			i = nbit
+		}
+		tx.Interval = b.interval(start, i)
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+func init() {
+	Register("SWD", func() Analyzer { return &SWD{} })
+}
+
+// Channels implements Analyzer.
+func (*SWD) Channels() []Channel { return []Channel{{Name: "swclk"}, {Name: "swdio"}} }
+
+// Settings implements Analyzer.
+func (s *SWD) Settings() []Setting {
+	return []Setting{
+		{Name: "turnaround", Usage: "turnaround cycles, 0 means 1", Value: strconv.Itoa(s.Turnaround)},
+	}
+}
+
+// Set implements Analyzer.
+func (s *SWD) Set(name, value string) (err error) {
+	switch name {
+	case "turnaround":
+		s.Turnaround, err = strconv.Atoi(value)
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Transfers are returned as frames of type "transfer"
+// with the register name, access and acknowledge. Other events are returned with
+// their name as type.
+func (s *SWD) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(s, channels)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := s.Scan(in[0], in[1])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(txs))
+	for i, tx := range txs {
+		frames[i] = Frame{Interval: tx.Interval, Type: tx.Event.String(), Err: tx.Err}
+		if tx.Event != SWDEventTransfer {
+			continue
+		}
+		frames[i].Data = map[string]any{
+			"ap":       tx.APnDP,
+			"read":     tx.Read,
+			"address":  tx.Addr,
+			"register": tx.Register(),
+			"ack":      tx.ACK.String(),
+			"data":     tx.Data,
+		}
+	}
+	return frames, nil
+}
@@ -0,0 +1,95 @@
+package analyzers
+
+import (
+	"math/bits"
+	"testing"
+)
+
+// swdWave builds SWCLK and SWDIO bit strings, one SWCLK cycle per bit.
+type swdWave struct {
+	clockWave
+}
+
+func (w *swdWave) bits(v uint32, n int) {
+	for i := 0; i < n; i++ {
+		w.cycle(v>>i&1 != 0)
+	}
+}
+
+func (w *swdWave) transfer(ap, read bool, addr uint8, ack SWDAck, data uint32) {
+	req := uint32(b2u8(ap)) | uint32(b2u8(read))<<1 | uint32(addr>>2)<<2
+	w.bits(1|req<<1|uint32(bits.OnesCount32(req)%2)<<5|1<<7, 8)
+	w.bits(1, 1) // Turnaround, line pulled up.
+	w.bits(uint32(ack), 3)
+	if ack == SWDAckOK {
+		if !read {
+			w.bits(1, 1)
+		}
+		w.bits(data, 32)
+		w.bits(uint32(bits.OnesCount32(data)%2), 1)
+		if read {
+			w.bits(1, 1)
+		}
+	} else {
+		w.bits(1, 1)
+	}
+	w.bits(0, 2) // Idle.
+}
+
+func TestSWD(t *testing.T) {
+	var w swdWave
+	w.bits(0, 4)
+	w.bits(0xffffffff, 32)
+	w.bits(0xffffffff, 24)
+	w.bits(swdJTAGToSWD, 16)
+	w.bits(0xffffffff, 32)
+	w.bits(0xffffffff, 24)
+	w.bits(0, 2)
+	w.transfer(false, true, 0x0, SWDAckOK, 0x0bc12477)  // DPIDR.
+	w.transfer(false, false, 0x8, SWDAckOK, 0x000000f0) // SELECT APBANKSEL=0xf.
+	w.transfer(true, true, 0xc, SWDAckOK, 0)            // IDR, posted.
+	w.transfer(false, true, 0xc, SWDAckWait, 0)         // RDBUFF.
+	w.transfer(true, false, 0x4, SWDAckFault, 0)        // AP[0xf4], CFG.
+	w.transfer(false, true, 0xc, 0b111, 0)              // No response.
+	w.transfer(false, true, 0xc, SWDAckOK, 0x24770011)  // RDBUFF.
+	// Corrupt data parity of the last transfer: flip parity bit, 4 cycles before the end.
+	clk, dio := w.clk.String(), []byte(w.line(0))
+	n := len(dio) - 2*(2+1+1)
+	dio[n] ^= 1
+	dio[n+1] ^= 1
+	swclk, swdio := digitalFromBits(clk, 5e-7), digitalFromBits(string(dio), 5e-7)
+
+	var swd SWD
+	txs, err := swd.Scan(swclk, swdio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		event SWDEvent
+		reg   string
+		ack   SWDAck
+		data  uint32
+		err   error
+	}{
+		{event: SWDEventLineReset},
+		{event: SWDEventJTAGToSWD},
+		{event: SWDEventLineReset},
+		{reg: "DPIDR", ack: SWDAckOK, data: 0x0bc12477},
+		{reg: "SELECT", ack: SWDAckOK, data: 0xf0},
+		{reg: "IDR", ack: SWDAckOK},
+		{reg: "RDBUFF", ack: SWDAckWait},
+		{reg: "CFG", ack: SWDAckFault},
+		{reg: "RDBUFF", ack: 0b111, err: errSWDProtocol},
+		{reg: "RDBUFF", ack: SWDAckOK, data: 0x24770011, err: errSWDDataParity},
+	}
+	if len(txs) != len(want) {
+		t.Fatalf("expected %d events, got %d: %+v", len(want), len(txs), txs)
+	}
+	for i, tx := range txs {
+		w := want[i]
+		if tx.Event != w.event || tx.Register() != w.reg || tx.ACK != w.ack || tx.Data != w.data || tx.Err != w.err {
+			t.Errorf("event %d: expected %v %s %v %#x %v, got %v %s %v %#x %v", i,
+				w.event, w.reg, w.ack, w.data, w.err, tx.Event, tx.Register(), tx.ACK, tx.Data, tx.Err)
+		}
+	}
+}
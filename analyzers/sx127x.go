@@ -0,0 +1,133 @@
+package analyzers
+
+// SX127x is the register map of the Semtech SX1276/77/78/79 LoRa transceivers
+// in LoRa mode. The MSB of the address byte is set for writes and burst
+// accesses auto-increment the address except when accessing the FIFO.
+var SX127x = RegisterMap{
+	Name:          "SX127x",
+	AddrMask:      0x7f,
+	WriteMask:     0x80,
+	AutoIncrement: true,
+	Registers: []RegisterDef{
+		{Addr: 0x00, Name: "RegFifo", FIFO: true},
+		{Addr: 0x01, Name: "RegOpMode", Fields: []BitField{
+			{Name: "LongRangeMode", Shift: 7, Width: 1, Values: []string{"FSK", "LoRa"}},
+			{Name: "AccessSharedReg", Shift: 6, Width: 1},
+			{Name: "LowFrequencyModeOn", Shift: 3, Width: 1},
+			{Name: "Mode", Shift: 0, Width: 3, Values: []string{"SLEEP", "STDBY", "FSTX", "TX", "FSRX", "RXCONTINUOUS", "RXSINGLE", "CAD"}},
+		}},
+		{Addr: 0x06, Name: "RegFrfMsb"},
+		{Addr: 0x07, Name: "RegFrfMid"},
+		{Addr: 0x08, Name: "RegFrfLsb"},
+		{Addr: 0x09, Name: "RegPaConfig", Fields: []BitField{
+			{Name: "PaSelect", Shift: 7, Width: 1, Values: []string{"RFO", "PA_BOOST"}},
+			{Name: "MaxPower", Shift: 4, Width: 3},
+			{Name: "OutputPower", Shift: 0, Width: 4},
+		}},
+		{Addr: 0x0a, Name: "RegPaRamp", Fields: []BitField{
+			{Name: "PaRamp", Shift: 0, Width: 4},
+		}},
+		{Addr: 0x0b, Name: "RegOcp", Fields: []BitField{
+			{Name: "OcpOn", Shift: 5, Width: 1},
+			{Name: "OcpTrim", Shift: 0, Width: 5},
+		}},
+		{Addr: 0x0c, Name: "RegLna", Fields: []BitField{
+			{Name: "LnaGain", Shift: 5, Width: 3},
+			{Name: "LnaBoostLf", Shift: 3, Width: 2},
+			{Name: "LnaBoostHf", Shift: 0, Width: 2},
+		}},
+		{Addr: 0x0d, Name: "RegFifoAddrPtr"},
+		{Addr: 0x0e, Name: "RegFifoTxBaseAddr"},
+		{Addr: 0x0f, Name: "RegFifoRxBaseAddr"},
+		{Addr: 0x10, Name: "RegFifoRxCurrentAddr"},
+		{Addr: 0x11, Name: "RegIrqFlagsMask", Fields: sx127xIrqFields},
+		{Addr: 0x12, Name: "RegIrqFlags", Fields: sx127xIrqFields},
+		{Addr: 0x13, Name: "RegRxNbBytes"},
+		{Addr: 0x14, Name: "RegRxHeaderCntValueMsb"},
+		{Addr: 0x15, Name: "RegRxHeaderCntValueLsb"},
+		{Addr: 0x16, Name: "RegRxPacketCntValueMsb"},
+		{Addr: 0x17, Name: "RegRxPacketCntValueLsb"},
+		{Addr: 0x18, Name: "RegModemStat", Fields: []BitField{
+			{Name: "RxCodingRate", Shift: 5, Width: 3},
+			{Name: "ModemStatus", Shift: 0, Width: 5},
+		}},
+		{Addr: 0x19, Name: "RegPktSnrValue"},
+		{Addr: 0x1a, Name: "RegPktRssiValue"},
+		{Addr: 0x1b, Name: "RegRssiValue"},
+		{Addr: 0x1c, Name: "RegHopChannel", Fields: []BitField{
+			{Name: "PllTimeout", Shift: 7, Width: 1},
+			{Name: "CrcOnPayload", Shift: 6, Width: 1},
+			{Name: "FhssPresentChannel", Shift: 0, Width: 6},
+		}},
+		{Addr: 0x1d, Name: "RegModemConfig1", Fields: []BitField{
+			{Name: "Bw", Shift: 4, Width: 4, Values: []string{"7.8kHz", "10.4kHz", "15.6kHz", "20.8kHz", "31.25kHz", "41.7kHz", "62.5kHz", "125kHz", "250kHz", "500kHz"}},
+			{Name: "CodingRate", Shift: 1, Width: 3, Values: []string{1: "4/5", 2: "4/6", 3: "4/7", 4: "4/8"}},
+			{Name: "ImplicitHeaderModeOn", Shift: 0, Width: 1},
+		}},
+		{Addr: 0x1e, Name: "RegModemConfig2", Fields: []BitField{
+			{Name: "SpreadingFactor", Shift: 4, Width: 4},
+			{Name: "TxContinuousMode", Shift: 3, Width: 1},
+			{Name: "RxPayloadCrcOn", Shift: 2, Width: 1},
+			{Name: "SymbTimeoutMsb", Shift: 0, Width: 2},
+		}},
+		{Addr: 0x1f, Name: "RegSymbTimeoutLsb"},
+		{Addr: 0x20, Name: "RegPreambleMsb"},
+		{Addr: 0x21, Name: "RegPreambleLsb"},
+		{Addr: 0x22, Name: "RegPayloadLength"},
+		{Addr: 0x23, Name: "RegMaxPayloadLength"},
+		{Addr: 0x24, Name: "RegHopPeriod"},
+		{Addr: 0x25, Name: "RegFifoRxByteAddr"},
+		{Addr: 0x26, Name: "RegModemConfig3", Fields: []BitField{
+			{Name: "LowDataRateOptimize", Shift: 3, Width: 1},
+			{Name: "AgcAutoOn", Shift: 2, Width: 1},
+		}},
+		{Addr: 0x28, Name: "RegFeiMsb"},
+		{Addr: 0x29, Name: "RegFeiMid"},
+		{Addr: 0x2a, Name: "RegFeiLsb"},
+		{Addr: 0x2c, Name: "RegRssiWideband"},
+		{Addr: 0x31, Name: "RegDetectOptimize", Fields: []BitField{
+			{Name: "DetectionOptimize", Shift: 0, Width: 3},
+		}},
+		{Addr: 0x33, Name: "RegInvertIQ", Fields: []BitField{
+			{Name: "InvertIQRX", Shift: 6, Width: 1},
+			{Name: "InvertIQTX", Shift: 0, Width: 1},
+		}},
+		{Addr: 0x37, Name: "RegDetectionThreshold"},
+		{Addr: 0x39, Name: "RegSyncWord"},
+		{Addr: 0x40, Name: "RegDioMapping1", Fields: []BitField{
+			{Name: "Dio0Mapping", Shift: 6, Width: 2},
+			{Name: "Dio1Mapping", Shift: 4, Width: 2},
+			{Name: "Dio2Mapping", Shift: 2, Width: 2},
+			{Name: "Dio3Mapping", Shift: 0, Width: 2},
+		}},
+		{Addr: 0x41, Name: "RegDioMapping2", Fields: []BitField{
+			{Name: "Dio4Mapping", Shift: 6, Width: 2},
+			{Name: "Dio5Mapping", Shift: 4, Width: 2},
+			{Name: "MapPreambleDetect", Shift: 0, Width: 1},
+		}},
+		{Addr: 0x42, Name: "RegVersion"},
+		{Addr: 0x4b, Name: "RegTcxo", Fields: []BitField{
+			{Name: "TcxoInputOn", Shift: 4, Width: 1},
+		}},
+		{Addr: 0x4d, Name: "RegPaDac", Fields: []BitField{
+			{Name: "PaDac", Shift: 0, Width: 3},
+		}},
+		{Addr: 0x5b, Name: "RegFormerTemp"},
+		{Addr: 0x61, Name: "RegAgcRef"},
+		{Addr: 0x62, Name: "RegAgcThresh1"},
+		{Addr: 0x63, Name: "RegAgcThresh2"},
+		{Addr: 0x64, Name: "RegAgcThresh3"},
+		{Addr: 0x70, Name: "RegPll"},
+	},
+}
+
+var sx127xIrqFields = []BitField{
+	{Name: "RxTimeout", Shift: 7, Width: 1},
+	{Name: "RxDone", Shift: 6, Width: 1},
+	{Name: "PayloadCrcError", Shift: 5, Width: 1},
+	{Name: "ValidHeader", Shift: 4, Width: 1},
+	{Name: "TxDone", Shift: 3, Width: 1},
+	{Name: "CadDone", Shift: 2, Width: 1},
+	{Name: "FhssChangeChannel", Shift: 1, Width: 1},
+	{Name: "CadDetected", Shift: 0, Width: 1},
+}
@@ -0,0 +1,310 @@
+package analyzers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/soypat/saleae"
+)
+
+// Parity is the parity bit configuration of an asynchronous serial frame.
+type Parity uint8
+
+const (
+	ParityNone Parity = iota
+	ParityEven
+	ParityOdd
+	// Parity bit is always 1.
+	ParityMark
+	// Parity bit is always 0.
+	ParitySpace
+)
+
+func (p Parity) String() (s string) {
+	switch p {
+	case ParityNone:
+		s = "none"
+	case ParityEven:
+		s = "even"
+	case ParityOdd:
+		s = "odd"
+	case ParityMark:
+		s = "mark"
+	case ParitySpace:
+		s = "space"
+	default:
+		s = "unknown"
+	}
+	return s
+}
+
+// StopBits is the length of the stop condition of an asynchronous serial frame.
+type StopBits uint8
+
+const (
+	StopBitsOne StopBits = iota
+	StopBitsOneHalf
+	StopBitsTwo
+)
+
+// bits returns the length of the stop condition in bit periods.
+func (sb StopBits) bits() float64 {
+	switch sb {
+	case StopBitsOneHalf:
+		return 1.5
+	case StopBitsTwo:
+		return 2
+	}
+	return 1
+}
+
+func (sb StopBits) String() (s string) {
+	switch sb {
+	case StopBitsOne:
+		s = "1"
+	case StopBitsOneHalf:
+		s = "1.5"
+	case StopBitsTwo:
+		s = "2"
+	default:
+		s = "unknown"
+	}
+	return s
+}
+
+// FrameUART is a single asynchronous serial frame (character).
+type FrameUART struct {
+	Interval
+	Data uint16
+	// Stop bit sampled low.
+	FramingError bool
+	// Parity bit did not match configured parity.
+	ParityError bool
+	// Line held low for the entire frame, including parity and stop bits.
+	Break bool
+}
+
+// Err returns a non-nil error if the frame was received with errors.
+func (f FrameUART) Err() error {
+	switch {
+	case f.Break:
+		return errUARTBreak
+	case f.FramingError:
+		return errUARTFraming
+	case f.ParityError:
+		return errUARTParity
+	}
+	return nil
+}
+
+var (
+	errUARTBreak   = errors.New("uart: break condition")
+	errUARTFraming = errors.New("uart: framing error")
+	errUARTParity  = errors.New("uart: parity error")
+)
+
+// UART can be used to analyze a digital signal for asynchronous serial frames.
+// The zero value decodes 8 data bits, no parity, 1 stop bit, LSB first on a
+// non-inverted line. BaudRate must be set.
+type UART struct {
+	BaudRate float64
+	// Number of data bits in range 5..9. Zero value means 8.
+	DataBits int
+	Parity   Parity
+	StopBits StopBits
+	MSBFirst bool
+	// Inverted is set for lines which idle low, such as a signal
+	// captured on the far side of an RS-232 transceiver.
+	Inverted bool
+}
+
+func (u *UART) validate() error {
+	if u.BaudRate <= 0 || math.IsInf(u.BaudRate, 0) || math.IsNaN(u.BaudRate) {
+		return errors.New("uart: invalid baud rate")
+	}
+	if u.DataBits != 0 && (u.DataBits < 5 || u.DataBits > 9) {
+		return errors.New("uart: data bits must be in range 5..9")
+	}
+	if u.Parity > ParitySpace {
+		return errors.New("uart: invalid parity")
+	}
+	if u.StopBits > StopBitsTwo {
+		return errors.New("uart: invalid stop bits")
+	}
+	return nil
+}
+
+func (u *UART) dataBits() int {
+	if u.DataBits == 0 {
+		return 8
+	}
+	return u.DataBits
+}
+
+// Scan decodes all frames found on rx.
+func (u *UART) Scan(rx *saleae.DigitalFile) (frames []FrameUART, err error) {
+	if rx == nil {
+		return nil, errors.New("uart: got nil digital file")
+	}
+	err = u.validate()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		bitT   = 1 / u.BaudRate
+		nbits  = u.dataBits()
+		sig    = newSignal(rx)
+		idle   = !u.Inverted
+		active = !idle
+	)
+	t := 0.0
+	for {
+		// Find start bit edge.
+		start := sig.nextTo(t, active)
+		if math.IsInf(start, 1) {
+			break
+		}
+		var (
+			frame  FrameUART
+			ones   int
+			anyOne bool
+		)
+		// Sample in the middle of each bit.
+		tbit := start + bitT/2
+		if sig.at(tbit) != active {
+			// Glitch shorter than half a bit; not a start bit.
+			t = tbit
+			continue
+		}
+		for i := 0; i < nbits; i++ {
+			tbit += bitT
+			bit := sig.at(tbit) == idle
+			if !bit {
+				continue
+			}
+			ones++
+			anyOne = true
+			if u.MSBFirst {
+				frame.Data |= 1 << (nbits - 1 - i)
+			} else {
+				frame.Data |= 1 << i
+			}
+		}
+		if u.Parity != ParityNone {
+			tbit += bitT
+			bit := sig.at(tbit) == idle
+			anyOne = anyOne || bit
+			frame.ParityError = bit != u.parityBit(ones)
+		}
+		// Sample stop condition at its start and end so that the frame ends
+		// after the stop bits have been fully transmitted.
+		tbit += bitT
+		stopOK := sig.at(tbit) == idle
+		end := tbit - bitT/2 + bitT*u.StopBits.bits()
+		if u.StopBits != StopBitsOne {
+			stopOK = stopOK && sig.at(end-bitT/2) == idle
+		}
+		frame.FramingError = !stopOK
+		frame.Break = !stopOK && !anyOne
+		frame.start = start
+		frame.end = end
+		frames = append(frames, frame)
+		// Resynchronize on next start bit which can only begin once the
+		// stop bits have been sampled.
+		t = tbit
+		if !stopOK {
+			t = end - bitT/2
+		}
+	}
+	return frames, nil
+}
+
+// parityBit returns the expected parity bit given the number of ones in the data.
+func (u *UART) parityBit(ones int) bool {
+	switch u.Parity {
+	case ParityEven:
+		return ones%2 == 1
+	case ParityOdd:
+		return ones%2 == 0
+	case ParityMark:
+		return true
+	}
+	return false
+}
+
+func init() {
+	Register("UART", func() Analyzer { return &UART{BaudRate: 115200} })
+}
+
+// Channels implements Analyzer.
+func (*UART) Channels() []Channel {
+	return []Channel{{Name: "rx"}}
+}
+
+// Settings implements Analyzer.
+func (u *UART) Settings() []Setting {
+	return []Setting{
+		{Name: "baud", Usage: "baud rate in bits per second", Value: formatFloat(u.BaudRate)},
+		{Name: "databits", Usage: "data bits per frame, 5..9", Value: strconv.Itoa(u.dataBits())},
+		{Name: "parity", Usage: "none, even, odd, mark or space", Value: u.Parity.String()},
+		{Name: "stopbits", Usage: "1, 1.5 or 2", Value: u.StopBits.String()},
+		{Name: "msbfirst", Usage: "most significant bit is sent first", Value: strconv.FormatBool(u.MSBFirst)},
+		{Name: "inverted", Usage: "line idles low", Value: strconv.FormatBool(u.Inverted)},
+	}
+}
+
+// Set implements Analyzer.
+func (u *UART) Set(name, value string) (err error) {
+	switch name {
+	case "baud":
+		u.BaudRate, err = strconv.ParseFloat(value, 64)
+	case "databits":
+		u.DataBits, err = strconv.Atoi(value)
+	case "parity":
+		for p := ParityNone; p <= ParitySpace; p++ {
+			if p.String() == value {
+				u.Parity = p
+				return nil
+			}
+		}
+		err = errors.New("uart: invalid parity " + strconv.Quote(value))
+	case "stopbits":
+		for sb := StopBitsOne; sb <= StopBitsTwo; sb++ {
+			if sb.String() == value {
+				u.StopBits = sb
+				return nil
+			}
+		}
+		err = errors.New("uart: invalid stop bits " + strconv.Quote(value))
+	case "msbfirst":
+		u.MSBFirst, err = strconv.ParseBool(value)
+	case "inverted":
+		u.Inverted, err = strconv.ParseBool(value)
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Each frame is returned with its "data" value.
+func (u *UART) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(u, channels)
+	if err != nil {
+		return nil, err
+	}
+	uframes, err := u.Scan(in[0])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(uframes))
+	for i, f := range uframes {
+		frames[i] = Frame{
+			Interval: f.Interval,
+			Type:     "data",
+			Data:     map[string]any{"data": f.Data},
+			Err:      f.Err(),
+		}
+	}
+	return frames, nil
+}
@@ -0,0 +1,134 @@
+package analyzers
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/soypat/saleae"
+)
+
+func TestUARTFixture(t *testing.T) {
+	rx := readDigitalFile(t, "../testdata/digital_uart.bin")
+	u := UART{BaudRate: 115200}
+	frames, err := u.Scan(rx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	for i, f := range frames {
+		if f.Err() != nil {
+			t.Fatalf("frame %d at %fs: %v", i, f.StartTime(), f.Err())
+		}
+		if f.EndTime() <= f.StartTime() {
+			t.Fatalf("frame %d: bad interval %f..%f", i, f.StartTime(), f.EndTime())
+		}
+		sb.WriteByte(byte(f.Data))
+	}
+	const expectPrefix = "initializing device\r\ngot version Version: 7.95.49 (2271bb6 CY)"
+	got := sb.String()
+	if len(got) < len(expectPrefix) {
+		t.Fatalf("expected output to start with %q, got only %q", expectPrefix, got)
+	}
+	if !strings.HasPrefix(got, expectPrefix) {
+		t.Errorf("expected output to start with %q, got %q", expectPrefix, got[:len(expectPrefix)])
+	}
+}
+
+func TestUARTFormats(t *testing.T) {
+	const baud = 9600
+	for _, test := range []struct {
+		uart        UART
+		bits        string // Line levels, one character per bit.
+		expectData  uint16
+		expectError error
+	}{
+		// 'A' is 0x41, sent LSB first.
+		{uart: UART{}, bits: "1" + "0" + "10000010" + "1" + "1", expectData: 'A'},
+		{uart: UART{MSBFirst: true}, bits: "1" + "0" + "01000001" + "1" + "1", expectData: 'A'},
+		{uart: UART{Inverted: true}, bits: "0" + "1" + "01111101" + "0" + "0", expectData: 'A'},
+		{uart: UART{DataBits: 7, Parity: ParityEven}, bits: "1" + "0" + "1000001" + "0" + "1" + "1", expectData: 'A'},
+		{uart: UART{DataBits: 7, Parity: ParityOdd}, bits: "1" + "0" + "1000001" + "0" + "1" + "1", expectData: 'A', expectError: errUARTParity},
+		{uart: UART{DataBits: 9, StopBits: StopBitsTwo}, bits: "1" + "0" + "100000101" + "11" + "1", expectData: 0x141},
+		{uart: UART{StopBits: StopBitsTwo}, bits: "1" + "0" + "10000010" + "10" + "1", expectData: 'A', expectError: errUARTFraming},
+		{uart: UART{}, bits: "1" + "0" + "10000010" + "0" + "1", expectData: 'A', expectError: errUARTFraming},
+		{uart: UART{}, bits: "1" + "0" + "00000000" + "0" + "0000" + "1", expectError: errUARTBreak},
+	} {
+		test.uart.BaudRate = baud
+		rx := digitalFromBits(test.bits, 1.0/baud)
+		frames, err := test.uart.Scan(rx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frames) != 1 {
+			t.Fatalf("%+v: expected 1 frame, got %d", test.uart, len(frames))
+		}
+		got := frames[0]
+		if got.Data != test.expectData {
+			t.Errorf("%+v: expected data %#x, got %#x", test.uart, test.expectData, got.Data)
+		}
+		if got.Err() != test.expectError {
+			t.Errorf("%+v: expected error %v, got %v", test.uart, test.expectError, got.Err())
+		}
+	}
+}
+
+func readDigitalFile(t *testing.T, path string) *saleae.DigitalFile {
+	t.Helper()
+	fp, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+	df, err := saleae.ReadDigitalFile(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return df
+}
+
+// digitalFromBits returns a digital file with line levels given by a string
+// of '0' and '1' characters, each lasting period seconds.
+func digitalFromBits(bits string, period float64) *saleae.DigitalFile {
+	var df saleae.DigitalFile
+	df.Header.InitialState = uint32(bits[0] - '0')
+	for i := 1; i < len(bits); i++ {
+		if bits[i] != bits[i-1] {
+			df.Data = append(df.Data, float64(i)*period)
+		}
+	}
+	df.Header.NumTransitions = uint64(len(df.Data))
+	df.Header.End = float64(len(bits)) * period
+	return &df
+}
+
+func TestDetectUART(t *testing.T) {
+	rx := readDigitalFile(t, "../testdata/digital_uart.bin")
+	u, est, errCount, err := DetectUART(rx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if est.BaudRate != 115200 || !est.Snapped {
+		t.Errorf("expected snapped baud rate 115200, got %+v", est)
+	}
+	if est.Confidence < 0.9 {
+		t.Errorf("low confidence %+v", est)
+	}
+	if errCount != 0 || u.DataBits != 8 || u.Parity != ParityNone || u.StopBits != StopBitsOne || u.Inverted {
+		t.Errorf("expected 8N1 with no errors, got %+v with %d errors", u, errCount)
+	}
+
+	// 7E1 at 2400 baud, "Hi!" with even parity.
+	const bits = "1111" +
+		"0" + "0001001" + "0" + "1" + // 'H' 0x48
+		"0" + "1001011" + "0" + "1" + // 'i' 0x69
+		"0" + "1000010" + "0" + "1" + // '!' 0x21
+		"1111"
+	u, est, errCount, err = DetectUART(digitalFromBits(bits, 1.0/2400))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if est.BaudRate != 2400 || errCount != 0 || u.DataBits != 7 || u.Parity != ParityEven {
+		t.Errorf("expected 2400 7E1 with no errors, got %+v %+v with %d errors", est, u, errCount)
+	}
+}
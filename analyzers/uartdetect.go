@@ -0,0 +1,175 @@
+package analyzers
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/soypat/saleae"
+)
+
+// StandardBaudRates lists the baud rates EstimateBaudRate snaps to.
+var StandardBaudRates = []float64{
+	300, 600, 1200, 2400, 4800, 9600, 14400, 19200, 28800, 31250, 38400,
+	57600, 76800, 115200, 230400, 250000, 460800, 500000, 921600,
+	1000000, 1500000, 2000000, 3000000,
+}
+
+// BaudEstimate is the result of estimating the baud rate of an asynchronous serial line.
+type BaudEstimate struct {
+	// Estimated baud rate. Snapped to a standard baud rate if Measured is close enough.
+	BaudRate float64
+	// Baud rate calculated from the pulse widths, before snapping.
+	Measured float64
+	// Snapped is true if BaudRate was snapped to a standard baud rate.
+	Snapped bool
+	// Confidence in range 0..1. It is the fraction of pulses up to
+	// a frame long whose width is close to a multiple of the bit period.
+	Confidence float64
+}
+
+const (
+	// Maximum relative deviation from a standard baud rate for snapping to happen.
+	baudSnapTolerance = 0.03
+	// Maximum deviation, in bit periods, of a pulse width from a multiple of the bit period.
+	baudBitTolerance = 0.2
+	// Longest pulse in bit periods that is considered for estimation.
+	baudMaxPulseBits = 12
+)
+
+// shortestPulseCluster returns the mean width of the shortest cluster of pulses
+// which is not a glitch, or zero if there is none. A cluster holds the pulses up to
+// spread times as wide as its shortest pulse. A glitch is a pulse width with too
+// few neighbors of similar width.
+func shortestPulseCluster(widths []float64, spread float64) float64 {
+	sorted := append([]float64(nil), widths...)
+	sort.Float64s(sorted)
+	minCount := len(sorted) / 200
+	if minCount < 2 {
+		minCount = 2
+	}
+	for i, w := range sorted {
+		if w <= 0 {
+			continue
+		}
+		j := i
+		sum := 0.0
+		for ; j < len(sorted) && sorted[j] <= w*spread; j++ {
+			sum += sorted[j]
+		}
+		if j-i >= minCount {
+			return sum / float64(j-i)
+		}
+	}
+	return 0
+}
+
+// EstimateBaudRate estimates the baud rate of rx from the histogram of its shortest pulses.
+// The shortest pulse cluster gives a first approximation of the bit period which is then refined
+// using all pulses that span a whole number of bits.
+func EstimateBaudRate(rx *saleae.DigitalFile) (BaudEstimate, error) {
+	if rx == nil {
+		return BaudEstimate{}, errors.New("uart: got nil digital file")
+	}
+	if len(rx.Data) < 3 {
+		return BaudEstimate{}, errors.New("uart: not enough transitions to estimate baud rate")
+	}
+	widths := make([]float64, len(rx.Data)-1)
+	for i := range widths {
+		widths[i] = rx.Data[i+1] - rx.Data[i]
+	}
+	bitT := shortestPulseCluster(widths, 1.2)
+	if bitT == 0 {
+		return BaudEstimate{}, errors.New("uart: no pulse cluster found")
+	}
+
+	// Refine with pulses that span a whole number of bits.
+	var (
+		sumW, sumK     float64
+		total, matched int
+	)
+	for _, w := range widths {
+		bits := w / bitT
+		if bits > baudMaxPulseBits+0.5 {
+			continue // Idle line.
+		}
+		total++
+		k := math.Round(bits)
+		if k < 1 || math.Abs(bits-k) > baudBitTolerance {
+			continue
+		}
+		matched++
+		sumW += w
+		sumK += k
+	}
+	if matched == 0 {
+		return BaudEstimate{}, errors.New("uart: no pulses matched bit period")
+	}
+	est := BaudEstimate{
+		Measured:   sumK / sumW,
+		Confidence: float64(matched) / float64(total),
+	}
+	est.BaudRate = est.Measured
+	for _, std := range StandardBaudRates {
+		if math.Abs(est.Measured-std)/std <= baudSnapTolerance {
+			est.BaudRate = std
+			est.Snapped = true
+			break
+		}
+	}
+	return est, nil
+}
+
+// uartFormats are the frame formats tried by DetectUART in order of preference.
+// Formats which can be told apart by parity come before formats of the same
+// frame length without parity, since the latter decode the former without errors.
+var uartFormats = []UART{
+	{DataBits: 7, Parity: ParityEven, StopBits: StopBitsOne},
+	{DataBits: 7, Parity: ParityOdd, StopBits: StopBitsOne},
+	{DataBits: 8, Parity: ParityEven, StopBits: StopBitsOne},
+	{DataBits: 8, Parity: ParityOdd, StopBits: StopBitsOne},
+	{DataBits: 7, Parity: ParityNone, StopBits: StopBitsTwo},
+	{DataBits: 8, Parity: ParityNone, StopBits: StopBitsOne},
+	{DataBits: 7, Parity: ParityEven, StopBits: StopBitsTwo},
+	{DataBits: 7, Parity: ParityOdd, StopBits: StopBitsTwo},
+	{DataBits: 8, Parity: ParityNone, StopBits: StopBitsTwo},
+	{DataBits: 7, Parity: ParityNone, StopBits: StopBitsOne},
+	{DataBits: 9, Parity: ParityNone, StopBits: StopBitsOne},
+	{DataBits: 6, Parity: ParityNone, StopBits: StopBitsOne},
+	{DataBits: 5, Parity: ParityNone, StopBits: StopBitsOne},
+}
+
+// DetectUART estimates the baud rate of rx and suggests the frame format that decodes
+// the capture with the fewest errors. Line inversion is inferred from the initial
+// state of rx, which is assumed to be idle. The number of frames with errors
+// decoded with the returned configuration is also returned.
+func DetectUART(rx *saleae.DigitalFile) (u UART, est BaudEstimate, errCount int, err error) {
+	est, err = EstimateBaudRate(rx)
+	if err != nil {
+		return u, est, 0, err
+	}
+	inverted := rx.Header.InitialState == 0
+	errCount = -1
+	for _, format := range uartFormats {
+		format.BaudRate = est.BaudRate
+		format.Inverted = inverted
+		frames, err := format.Scan(rx)
+		if err != nil {
+			return u, est, 0, err
+		}
+		n := 0
+		for i := range frames {
+			if frames[i].Err() != nil {
+				n++
+			}
+		}
+		if errCount < 0 || n < errCount {
+			u = format
+			errCount = n
+		}
+		if n == 0 {
+			break
+		}
+	}
+	return u, est, errCount, nil
+}
@@ -0,0 +1,653 @@
+package analyzers
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/soypat/saleae"
+)
+
+// PIDUSB is the 4-bit type of a USB packet identifier.
+type PIDUSB uint8
+
+const (
+	USBPIDOut   PIDUSB = 0x1
+	USBPIDIn    PIDUSB = 0x9
+	USBPIDSOF   PIDUSB = 0x5
+	USBPIDSetup PIDUSB = 0xd
+	USBPIDData0 PIDUSB = 0x3
+	USBPIDData1 PIDUSB = 0xb
+	USBPIDData2 PIDUSB = 0x7
+	USBPIDMData PIDUSB = 0xf
+	USBPIDAck   PIDUSB = 0x2
+	USBPIDNak   PIDUSB = 0xa
+	USBPIDStall PIDUSB = 0xe
+	USBPIDNyet  PIDUSB = 0x6
+	USBPIDPre   PIDUSB = 0xc
+	USBPIDSplit PIDUSB = 0x8
+	USBPIDPing  PIDUSB = 0x4
+)
+
+func (pid PIDUSB) String() (s string) {
+	switch pid {
+	case USBPIDOut:
+		s = "OUT"
+	case USBPIDIn:
+		s = "IN"
+	case USBPIDSOF:
+		s = "SOF"
+	case USBPIDSetup:
+		s = "SETUP"
+	case USBPIDData0:
+		s = "DATA0"
+	case USBPIDData1:
+		s = "DATA1"
+	case USBPIDData2:
+		s = "DATA2"
+	case USBPIDMData:
+		s = "MDATA"
+	case USBPIDAck:
+		s = "ACK"
+	case USBPIDNak:
+		s = "NAK"
+	case USBPIDStall:
+		s = "STALL"
+	case USBPIDNyet:
+		s = "NYET"
+	case USBPIDPre:
+		s = "PRE"
+	case USBPIDSplit:
+		s = "SPLIT"
+	case USBPIDPing:
+		s = "PING"
+	default:
+		s = "unknown"
+	}
+	return s
+}
+
+// IsToken returns true for OUT, IN, SETUP and PING tokens which start a transaction.
+func (pid PIDUSB) IsToken() bool {
+	return pid == USBPIDOut || pid == USBPIDIn || pid == USBPIDSetup || pid == USBPIDPing
+}
+
+// IsData returns true for data packets.
+func (pid PIDUSB) IsData() bool { return pid&0b11 == 0b11 }
+
+// IsHandshake returns true for ACK, NAK, STALL and NYET.
+func (pid PIDUSB) IsHandshake() bool { return pid&0b11 == 0b10 }
+
+// USBSpeed is the signaling rate of a USB bus.
+type USBSpeed uint8
+
+const (
+	// USBSpeedAuto detects the speed from the idle state of the bus: D+ is
+	// pulled up on full speed buses and D- on low speed buses.
+	USBSpeedAuto USBSpeed = iota
+	// USBLowSpeed is 1.5 Mb/s.
+	USBLowSpeed
+	// USBFullSpeed is 12 Mb/s.
+	USBFullSpeed
+)
+
+func (s USBSpeed) String() (str string) {
+	switch s {
+	case USBSpeedAuto:
+		str = "auto"
+	case USBLowSpeed:
+		str = "low"
+	case USBFullSpeed:
+		str = "full"
+	default:
+		str = "unknown"
+	}
+	return str
+}
+
+// USBEvent is the kind of a USB bus event.
+type USBEvent uint8
+
+const (
+	// USBEventPacket is a packet started by SYNC and ended by EOP.
+	USBEventPacket USBEvent = iota
+	// USBEventSE0 is an SE0 not preceded by a packet and shorter than a
+	// reset, such as a low speed keep-alive.
+	USBEventSE0
+	// USBEventReset is an SE0 of 2.5µs or longer.
+	USBEventReset
+)
+
+func (e USBEvent) String() (s string) {
+	switch e {
+	case USBEventPacket:
+		s = "packet"
+	case USBEventSE0:
+		s = "se0"
+	case USBEventReset:
+		s = "reset"
+	default:
+		s = "unknown"
+	}
+	return s
+}
+
+const (
+	usbResetTime = 2.5e-6
+	// usbMaxBits bounds the bits decoded before an EOP, a 1023 byte
+	// isochronous packet with worst case bit stuffing.
+	usbMaxBits = (1023+4)*8*7/6 + 8
+)
+
+var (
+	errUSBSync     = errors.New("usb: bad SYNC pattern")
+	errUSBPID      = errors.New("usb: PID check bits mismatch")
+	errUSBStuff    = errors.New("usb: bit stuffing violation")
+	errUSBBits     = errors.New("usb: packet is not a whole number of bytes")
+	errUSBLength   = errors.New("usb: bad packet length")
+	errUSBCRC5     = errors.New("usb: CRC5 mismatch")
+	errUSBCRC16    = errors.New("usb: CRC16 mismatch")
+	errUSBEOP      = errors.New("usb: missing EOP")
+	errUSBNoStatus = errors.New("usb: control transfer aborted by new SETUP")
+)
+
+// PacketUSB is a USB packet or bare SE0 event.
+type PacketUSB struct {
+	Interval
+	Event USBEvent
+	PID   PIDUSB
+	// Addr and Endpoint are set for OUT, IN, SETUP and PING tokens.
+	Addr     uint8
+	Endpoint uint8
+	// Frame is the frame number of SOF packets.
+	Frame uint16
+	// Data is the payload of data packets.
+	Data []byte
+	// CRC is the received CRC5 of token packets or CRC16 of data packets.
+	CRC uint16
+	Err error
+}
+
+// USB can be used to analyze low and full speed USB traffic from the D+ and D- lines.
+type USB struct {
+	Speed USBSpeed
+}
+
+// usbLine holds the bus lines with data being the line pulled up in the J state.
+type usbLine struct {
+	data, other *saleae.DigitalFile
+	tb          float64
+	end         float64
+}
+
+// Scan decodes all packets and SE0 events found on dp and dm.
+func (u *USB) Scan(dp, dm *saleae.DigitalFile) (packets []PacketUSB, err error) {
+	if dp == nil || dm == nil {
+		return nil, errors.New("usb: got nil digital file")
+	}
+	speed := u.Speed
+	if speed == USBSpeedAuto {
+		speed = usbDetectSpeed(dp, dm)
+	}
+	l := usbLine{data: dp, other: dm, tb: 1 / 12e6}
+	switch speed {
+	case USBFullSpeed:
+	case USBLowSpeed:
+		l.data, l.other, l.tb = dm, dp, 1/1.5e6
+	case USBSpeedAuto:
+		return nil, errors.New("usb: no idle state found to detect speed")
+	default:
+		return nil, errors.New("usb: invalid speed")
+	}
+	l.end = math.Max(dp.Header.End, dm.Header.End)
+	if l.end <= 0 {
+		for _, df := range []*saleae.DigitalFile{dp, dm} {
+			if n := len(df.Data); n > 0 {
+				l.end = math.Max(l.end, df.Data[n-1]+8*l.tb)
+			}
+		}
+	}
+	t := math.Inf(-1)
+	for {
+		// Leaving J either starts a SYNC (K) or an SE0.
+		start := edgeToAfter(l.data, t, false)
+		if math.IsInf(start, 1) {
+			break
+		}
+		var p PacketUSB
+		if levelAt(l.other, start+l.tb/2) {
+			p = l.packet(start)
+		} else {
+			p.Event = USBEventSE0
+			p.start = start
+			p.end = math.Min(edgeToAfter(l.data, start, true), l.end)
+			if p.end-p.start >= usbResetTime {
+				p.Event = USBEventReset
+			}
+		}
+		packets = append(packets, p)
+		t = p.end
+	}
+	return packets, nil
+}
+
+// usbDetectSpeed returns the speed of the first idle state found, the first
+// state with exactly one line high.
+func usbDetectSpeed(dp, dm *saleae.DigitalFile) USBSpeed {
+	for t := math.Inf(-1); !math.IsInf(t, 1); t = math.Min(edgeAfter(dp, t), edgeAfter(dm, t)) {
+		p, m := levelAt(dp, t), levelAt(dm, t)
+		if p != m {
+			if p {
+				return USBFullSpeed
+			}
+			return USBLowSpeed
+		}
+	}
+	return USBSpeedAuto
+}
+
+// packet decodes a packet whose SYNC field starts at start. Bits are
+// sampled at their center, resynchronizing on every data line edge.
+func (l *usbLine) packet(start float64) (p PacketUSB) {
+	p.start = start
+	var (
+		bits []bool
+		prev = true // Idle J.
+		ones int
+		next = start + l.tb/2
+		last = start
+	)
+	for {
+		if len(bits) > usbMaxBits || next > l.end {
+			p.end = math.Min(next, l.end)
+			p.Err = errUSBEOP
+			return p
+		}
+		if e := edgeAfter(l.data, last); e < next {
+			next = e + l.tb/2
+		}
+		d := levelAt(l.data, next)
+		if !d && !levelAt(l.other, next) {
+			p.end = math.Min(edgeToAfter(l.data, next, true), l.end)
+			break
+		}
+		// NRZI: a transition is a zero, no transition a one.
+		bit := d == prev
+		prev, last = d, next
+		next += l.tb
+		if ones == 6 {
+			ones = 0
+			if bit && p.Err == nil {
+				p.Err = errUSBStuff
+			}
+			continue // Stuffed zero.
+		}
+		bits = append(bits, bit)
+		if bit {
+			ones++
+		} else {
+			ones = 0
+		}
+	}
+	// A single dribble bit before EOP is tolerated.
+	if len(bits)%8 > 1 && p.Err == nil {
+		p.Err = errUSBBits
+	}
+	b := make([]byte, len(bits)/8)
+	for i := range b {
+		for j := 0; j < 8; j++ {
+			b[i] |= b2u8(bits[8*i+j]) << j
+		}
+	}
+	setErr := func(err error) {
+		if p.Err == nil {
+			p.Err = err
+		}
+	}
+	if len(b) < 2 {
+		setErr(errUSBLength)
+		return p
+	}
+	if b[0] != 0x80 {
+		setErr(errUSBSync)
+	}
+	p.PID = PIDUSB(b[1] & 0xf)
+	if b[1]>>4 != ^b[1]&0xf {
+		setErr(errUSBPID)
+	}
+	b = b[2:]
+	switch {
+	case p.PID.IsToken() || p.PID == USBPIDSOF:
+		if len(b) != 2 {
+			setErr(errUSBLength)
+			return p
+		}
+		v := binary.LittleEndian.Uint16(b)
+		p.CRC = v >> 11
+		if p.PID == USBPIDSOF {
+			p.Frame = v & 0x7ff
+		} else {
+			p.Addr = uint8(v & 0x7f)
+			p.Endpoint = uint8(v >> 7 & 0xf)
+		}
+		if usbCRC5(v&0x7ff) != p.CRC {
+			setErr(errUSBCRC5)
+		}
+	case p.PID.IsData():
+		if len(b) < 2 {
+			setErr(errUSBLength)
+			return p
+		}
+		p.Data = b[:len(b)-2]
+		p.CRC = binary.LittleEndian.Uint16(b[len(b)-2:])
+		if usbCRC16(p.Data) != p.CRC {
+			setErr(errUSBCRC16)
+		}
+	case p.PID.IsHandshake() || p.PID == USBPIDPre:
+		if len(b) != 0 {
+			setErr(errUSBLength)
+		}
+	default:
+		p.Data = b
+	}
+	return p
+}
+
+// usbCRC5 returns the CRC5 of the 11-bit token field v as it appears in the
+// token packet.
+func usbCRC5(v uint16) uint16 {
+	crc := uint16(0x1f)
+	for i := 0; i < 11; i++ {
+		if (crc>>4^v>>i)&1 != 0 {
+			crc = (crc<<1 ^ 0x05) & 0x1f
+		} else {
+			crc = crc << 1 & 0x1f
+		}
+	}
+	crc ^= 0x1f
+	// The CRC is sent most significant bit first.
+	var r uint16
+	for i := 0; i < 5; i++ {
+		r |= (crc >> i & 1) << (4 - i)
+	}
+	return r
+}
+
+// usbCRC16 returns the CRC16 of a data packet payload as sent, low byte first.
+func usbCRC16(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return ^crc
+}
+
+// TransactionUSB is a token packet followed by its optional data and handshake packets.
+type TransactionUSB struct {
+	Interval
+	Token        PacketUSB
+	Data         PacketUSB
+	HasData      bool
+	Handshake    PacketUSB
+	HasHandshake bool
+}
+
+// USBTransactions groups packets into transactions. SOF packets, SE0 events
+// and packets not preceded by a token are skipped.
+func USBTransactions(packets []PacketUSB) (txs []TransactionUSB) {
+	isPacket := func(i int, ok func(PIDUSB) bool) bool {
+		return i < len(packets) && packets[i].Event == USBEventPacket && ok(packets[i].PID)
+	}
+	for i := 0; i < len(packets); {
+		if !isPacket(i, PIDUSB.IsToken) {
+			i++
+			continue
+		}
+		tx := TransactionUSB{Token: packets[i]}
+		tx.Interval = packets[i].Interval
+		i++
+		if isPacket(i, PIDUSB.IsData) {
+			tx.Data, tx.HasData = packets[i], true
+			tx.end = packets[i].end
+			i++
+		}
+		if isPacket(i, PIDUSB.IsHandshake) {
+			tx.Handshake, tx.HasHandshake = packets[i], true
+			tx.end = packets[i].end
+			i++
+		}
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+// SetupUSB is the 8-byte request of a control transfer SETUP stage.
+type SetupUSB struct {
+	RequestType uint8
+	Request     uint8
+	Value       uint16
+	Index       uint16
+	Length      uint16
+}
+
+// In returns true if the data stage is device to host.
+func (s SetupUSB) In() bool { return s.RequestType&0x80 != 0 }
+
+// RequestName returns the name of standard and HID class requests.
+func (s SetupUSB) RequestName() string {
+	const (
+		typeStandard       = 0
+		typeClass          = 1
+		recipientInterface = 1
+	)
+	switch {
+	case s.RequestType>>5&3 == typeStandard:
+		switch s.Request {
+		case 0:
+			return "GET_STATUS"
+		case 1:
+			return "CLEAR_FEATURE"
+		case 3:
+			return "SET_FEATURE"
+		case 5:
+			return "SET_ADDRESS"
+		case 6:
+			return "GET_DESCRIPTOR"
+		case 7:
+			return "SET_DESCRIPTOR"
+		case 8:
+			return "GET_CONFIGURATION"
+		case 9:
+			return "SET_CONFIGURATION"
+		case 10:
+			return "GET_INTERFACE"
+		case 11:
+			return "SET_INTERFACE"
+		case 12:
+			return "SYNCH_FRAME"
+		}
+	case s.RequestType>>5&3 == typeClass && s.RequestType&0x1f == recipientInterface:
+		switch s.Request {
+		case 1:
+			return "GET_REPORT"
+		case 2:
+			return "GET_IDLE"
+		case 3:
+			return "GET_PROTOCOL"
+		case 9:
+			return "SET_REPORT"
+		case 10:
+			return "SET_IDLE"
+		case 11:
+			return "SET_PROTOCOL"
+		}
+	}
+	return "0x" + strconv.FormatUint(uint64(s.Request), 16)
+}
+
+func (s SetupUSB) String() string {
+	return s.RequestName() + "(wValue=0x" + strconv.FormatUint(uint64(s.Value), 16) +
+		" wIndex=0x" + strconv.FormatUint(uint64(s.Index), 16) +
+		" wLength=" + strconv.Itoa(int(s.Length)) + ")"
+}
+
+// ControlTransferUSB is a control transfer made up of SETUP, data and status stages.
+type ControlTransferUSB struct {
+	Interval
+	Addr     uint8
+	Endpoint uint8
+	Setup    SetupUSB
+	// Data is the payload of the acknowledged data stage transactions.
+	Data []byte
+	// Status is the handshake that ended the transfer, ACK for a completed
+	// status stage or STALL. Zero if the transfer did not end.
+	Status PIDUSB
+	Err    error
+}
+
+// USBControlTransfers groups transactions into control transfers. NAKed
+// transactions are retried by the host and are skipped.
+func USBControlTransfers(txs []TransactionUSB) (cts []ControlTransferUSB) {
+	active := make(map[uint16]int) // Index into cts by address and endpoint.
+	for _, tx := range txs {
+		key := uint16(tx.Token.Addr)<<4 | uint16(tx.Token.Endpoint)
+		idx, ok := active[key]
+		if tx.Token.PID == USBPIDSetup {
+			if !tx.HasData || len(tx.Data.Data) != 8 || tx.Data.Err != nil ||
+				!tx.HasHandshake || tx.Handshake.PID != USBPIDAck {
+				continue
+			}
+			if ok {
+				cts[idx].Err = errUSBNoStatus
+			}
+			d := tx.Data.Data
+			ct := ControlTransferUSB{
+				Addr:     tx.Token.Addr,
+				Endpoint: tx.Token.Endpoint,
+				Setup: SetupUSB{
+					RequestType: d[0],
+					Request:     d[1],
+					Value:       binary.LittleEndian.Uint16(d[2:]),
+					Index:       binary.LittleEndian.Uint16(d[4:]),
+					Length:      binary.LittleEndian.Uint16(d[6:]),
+				},
+				Interval: tx.Interval,
+			}
+			active[key] = len(cts)
+			cts = append(cts, ct)
+			continue
+		}
+		if !ok || !tx.HasHandshake || (tx.Token.PID != USBPIDIn && tx.Token.PID != USBPIDOut) {
+			continue
+		}
+		ct := &cts[idx]
+		switch tx.Handshake.PID {
+		case USBPIDStall:
+			ct.Status = USBPIDStall
+		case USBPIDAck:
+			if (tx.Token.PID == USBPIDIn) == ct.Setup.In() && ct.Setup.Length > 0 {
+				ct.Data = append(ct.Data, tx.Data.Data...)
+			} else {
+				ct.Status = USBPIDAck
+			}
+		default:
+			continue
+		}
+		ct.end = tx.end
+		if ct.Status != 0 {
+			delete(active, key)
+		}
+	}
+	return cts
+}
+
+func init() {
+	Register("USB", func() Analyzer { return &USB{} })
+}
+
+// Channels implements Analyzer.
+func (*USB) Channels() []Channel { return []Channel{{Name: "dp"}, {Name: "dm"}} }
+
+// Settings implements Analyzer.
+func (u *USB) Settings() []Setting {
+	return []Setting{
+		{Name: "speed", Usage: "bus speed: auto, low or full", Value: u.Speed.String()},
+	}
+}
+
+// Set implements Analyzer.
+func (u *USB) Set(name, value string) (err error) {
+	switch name {
+	case "speed":
+		for s := USBSpeedAuto; s <= USBFullSpeed; s++ {
+			if s.String() == value {
+				u.Speed = s
+				return nil
+			}
+		}
+		err = errors.New("usb: unknown speed " + strconv.Quote(value))
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Packets are returned as frames typed by their PID
+// name and SE0 events as "se0" or "reset" frames. Control transfers are returned as
+// frames of type "control" with the "request", "data" and "status", placed before
+// the SETUP packet that starts them so frames remain ordered by start time.
+func (u *USB) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(u, channels)
+	if err != nil {
+		return nil, err
+	}
+	packets, err := u.Scan(in[0], in[1])
+	if err != nil {
+		return nil, err
+	}
+	cts := USBControlTransfers(USBTransactions(packets))
+	frames := make([]Frame, 0, len(packets)+len(cts))
+	for _, p := range packets {
+		for len(cts) > 0 && cts[0].start <= p.start {
+			frames = append(frames, usbControlFrame(&cts[0]))
+			cts = cts[1:]
+		}
+		f := Frame{Interval: p.Interval, Type: p.Event.String(), Err: p.Err}
+		if p.Event == USBEventPacket {
+			f.Type = p.PID.String()
+			switch {
+			case p.PID == USBPIDSOF:
+				f.Data = map[string]any{"frame": p.Frame}
+			case p.PID.IsToken():
+				f.Data = map[string]any{"addr": p.Addr, "endpoint": p.Endpoint}
+			case p.PID.IsData():
+				f.Data = map[string]any{"data": p.Data}
+			}
+		}
+		frames = append(frames, f)
+	}
+	return frames, nil
+}
+
+func usbControlFrame(ct *ControlTransferUSB) Frame {
+	return Frame{
+		Interval: ct.Interval,
+		Type:     "control",
+		Data: map[string]any{
+			"addr":     ct.Addr,
+			"endpoint": ct.Endpoint,
+			"request":  ct.Setup.String(),
+			"data":     ct.Data,
+			"status":   ct.Status.String(),
+		},
+		Err: ct.Err,
+	}
+}
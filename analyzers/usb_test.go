@@ -0,0 +1,220 @@
+package analyzers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/soypat/saleae"
+)
+
+// usbWave builds D+ and D- bit strings with oversample characters per bit.
+type usbWave struct {
+	low        bool
+	oversample int
+	dp, dm     strings.Builder
+}
+
+// state writes n bits of line state: 'J', 'K' or '0' for SE0.
+func (w *usbWave) state(s byte, n int) {
+	var p, m byte = '0', '0'
+	switch {
+	case s == 'J' && !w.low, s == 'K' && w.low:
+		p = '1'
+	case s == 'K' && !w.low, s == 'J' && w.low:
+		m = '1'
+	}
+	for i := 0; i < n*w.oversample; i++ {
+		w.dp.WriteByte(p)
+		w.dm.WriteByte(m)
+	}
+}
+
+// packet writes SYNC, the NRZI encoded and bit stuffed packet bytes, EOP and one idle bit.
+func (w *usbWave) packet(b ...byte) {
+	level := byte('J')
+	toggle := func() {
+		if level == 'J' {
+			level = 'K'
+		} else {
+			level = 'J'
+		}
+	}
+	ones := 0
+	for _, v := range append([]byte{0x80}, b...) {
+		for i := 0; i < 8; i++ {
+			if v>>i&1 == 0 {
+				toggle()
+				ones = 0
+			} else {
+				ones++
+			}
+			w.state(level, 1)
+			if ones == 6 {
+				toggle()
+				w.state(level, 1)
+				ones = 0
+			}
+		}
+	}
+	w.state('0', 2)
+	w.state('J', 1)
+}
+
+func usbToken(pid PIDUSB, field uint16) []byte {
+	v := field | usbCRC5(field)<<11
+	return []byte{byte(pid) | ^byte(pid)<<4, byte(v), byte(v >> 8)}
+}
+
+func usbData(pid PIDUSB, data []byte) []byte {
+	b := append([]byte{byte(pid) | ^byte(pid)<<4}, data...)
+	return binary.LittleEndian.AppendUint16(b, usbCRC16(data))
+}
+
+func usbHandshake(pid PIDUSB) []byte { return []byte{byte(pid) | ^byte(pid)<<4} }
+
+func TestUSBCRC(t *testing.T) {
+	// Tokens 2d 00 10, 69 01 e8 and 69 81 58 as seen on the bus.
+	for field, want := range map[uint16]uint16{0x000: 0x02, 0x001: 0x1d, 0x081: 0x0b} {
+		if crc := usbCRC5(field); crc != want {
+			t.Errorf("field %#x: expected CRC5 %#x, got %#x", field, want, crc)
+		}
+	}
+	if crc := usbCRC16([]byte("123456789")); crc != 0xb4c8 {
+		t.Errorf("expected CRC16 0xb4c8, got %#x", crc)
+	}
+}
+
+func TestUSBFullSpeed(t *testing.T) {
+	desc := []byte{18, 1, 0x00, 0x02, 0, 0, 0, 64, 0xff, 0xff, 0x01, 0x00, 0x00, 0x01, 1, 2, 3, 1}
+	w := usbWave{oversample: 4}
+	w.state('J', 10)
+	w.state('0', 12*10) // 10µs reset.
+	w.state('J', 20)
+	w.packet(usbToken(USBPIDSOF, 0x123)...)
+	w.state('J', 10)
+	w.packet(usbToken(USBPIDSetup, 0)...)
+	w.packet(usbData(USBPIDData0, []byte{0x80, 6, 0, 1, 0, 0, 18, 0})...)
+	w.packet(usbHandshake(USBPIDAck)...)
+	w.state('J', 10)
+	w.packet(usbToken(USBPIDIn, 0)...)
+	w.packet(usbHandshake(USBPIDNak)...)
+	w.state('J', 10)
+	w.packet(usbToken(USBPIDIn, 0)...)
+	w.packet(usbData(USBPIDData1, desc)...)
+	w.packet(usbHandshake(USBPIDAck)...)
+	w.state('J', 10)
+	w.packet(usbToken(USBPIDOut, 0)...)
+	w.packet(usbData(USBPIDData1, nil)...)
+	w.packet(usbHandshake(USBPIDAck)...)
+	w.state('J', 10)
+	// Bit stuffing and a corrupted CRC.
+	bad := usbData(USBPIDData0, []byte{0xff, 0xff, 0x7e})
+	bad[len(bad)-1] ^= 1
+	w.packet(usbToken(USBPIDOut, 5|1<<7)...)
+	w.packet(bad...)
+	w.state('J', 10)
+
+	// Bit time slightly off nominal to exercise resynchronization.
+	period := 1.01 / 12e6 / float64(w.oversample)
+	dp, dm := digitalFromBits(w.dp.String(), period), digitalFromBits(w.dm.String(), period)
+	var u USB
+	packets, err := u.Scan(dp, dm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		event USBEvent
+		pid   PIDUSB
+		err   error
+	}{
+		{event: USBEventReset},
+		{pid: USBPIDSOF}, {pid: USBPIDSetup}, {pid: USBPIDData0}, {pid: USBPIDAck},
+		{pid: USBPIDIn}, {pid: USBPIDNak},
+		{pid: USBPIDIn}, {pid: USBPIDData1}, {pid: USBPIDAck},
+		{pid: USBPIDOut}, {pid: USBPIDData1}, {pid: USBPIDAck},
+		{pid: USBPIDOut}, {pid: USBPIDData0, err: errUSBCRC16},
+	}
+	if len(packets) != len(want) {
+		t.Fatalf("expected %d packets, got %d: %+v", len(want), len(packets), packets)
+	}
+	for i, p := range packets {
+		if p.Event != want[i].event || p.PID != want[i].pid || p.Err != want[i].err {
+			t.Errorf("packet %d: expected %v %v %v, got %v %v %v", i, want[i].event, want[i].pid, want[i].err, p.Event, p.PID, p.Err)
+		}
+	}
+	if packets[1].Frame != 0x123 {
+		t.Errorf("expected frame 0x123, got %#x", packets[1].Frame)
+	}
+	if p := packets[13]; p.Addr != 5 || p.Endpoint != 1 {
+		t.Errorf("expected address 5 endpoint 1, got %d %d", p.Addr, p.Endpoint)
+	}
+	if !bytes.Equal(packets[14].Data, []byte{0xff, 0xff, 0x7e}) {
+		t.Errorf("unexpected stuffed data % x", packets[14].Data)
+	}
+
+	txs := USBTransactions(packets)
+	if len(txs) != 5 {
+		t.Fatalf("expected 5 transactions, got %d", len(txs))
+	}
+	cts := USBControlTransfers(txs)
+	if len(cts) != 1 {
+		t.Fatalf("expected 1 control transfer, got %d", len(cts))
+	}
+	ct := cts[0]
+	if ct.Setup.String() != "GET_DESCRIPTOR(wValue=0x100 wIndex=0x0 wLength=18)" {
+		t.Errorf("unexpected setup %s", ct.Setup)
+	}
+	if !bytes.Equal(ct.Data, desc) || ct.Status != USBPIDAck || ct.Err != nil {
+		t.Errorf("unexpected control transfer %+v", ct)
+	}
+
+	frames, err := u.Analyze(map[string]*saleae.DigitalFile{"dp": dp, "dm": dm})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != len(packets)+1 {
+		t.Fatalf("expected %d frames, got %d", len(packets)+1, len(frames))
+	}
+	for i := 1; i < len(frames); i++ {
+		if frames[i].StartTime() < frames[i-1].StartTime() {
+			t.Errorf("frame %d %s starts before frame %d %s", i, frames[i].Type, i-1, frames[i-1].Type)
+		}
+	}
+	if frames[2].Type != "control" || frames[3].Type != USBPIDSetup.String() {
+		t.Errorf("expected control frame before SETUP, got %s then %s", frames[2].Type, frames[3].Type)
+	}
+}
+
+func TestUSBLowSpeed(t *testing.T) {
+	w := usbWave{low: true, oversample: 3}
+	w.state('J', 10)
+	w.state('0', 2) // Keep-alive.
+	w.state('J', 10)
+	w.packet(usbToken(USBPIDIn, 3|1<<7)...)
+	w.packet(usbData(USBPIDData0, []byte{0, 0, 4, 0, 0, 0, 0, 0})...)
+	w.packet(usbHandshake(USBPIDAck)...)
+	w.state('J', 10)
+
+	period := 1 / 1.5e6 / float64(w.oversample)
+	var u USB
+	packets, err := u.Scan(digitalFromBits(w.dp.String(), period), digitalFromBits(w.dm.String(), period))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) != 4 || packets[0].Event != USBEventSE0 {
+		t.Fatalf("expected keep-alive and 3 packets, got %+v", packets)
+	}
+	for _, p := range packets[1:] {
+		if p.Err != nil {
+			t.Error(p.PID, p.Err)
+		}
+	}
+	if p := packets[1]; p.PID != USBPIDIn || p.Addr != 3 || p.Endpoint != 1 {
+		t.Errorf("unexpected token %+v", p)
+	}
+	if p := packets[2]; p.PID != USBPIDData0 || len(p.Data) != 8 || p.Data[2] != 4 {
+		t.Errorf("unexpected data %+v", p)
+	}
+}
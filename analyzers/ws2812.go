@@ -0,0 +1,224 @@
+package analyzers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/soypat/saleae"
+)
+
+// TimingWS2812 holds the bit timings of a single wire LED driver, in seconds.
+type TimingWS2812 struct {
+	Name string
+	// High and low times of zero and one bits.
+	T0H, T0L, T1H, T1L float64
+	// Tolerance is the maximum deviation of high and low times.
+	Tolerance float64
+	// Reset is the minimum low time that latches the shifted colors.
+	Reset float64
+}
+
+var (
+	// WS2812BTiming are the WS2812B datasheet timings.
+	WS2812BTiming = TimingWS2812{Name: "ws2812b", T0H: 400e-9, T0L: 850e-9, T1H: 800e-9, T1L: 450e-9, Tolerance: 150e-9, Reset: 50e-6}
+	// SK6812Timing are the SK6812 datasheet timings.
+	SK6812Timing = TimingWS2812{Name: "sk6812", T0H: 300e-9, T0L: 900e-9, T1H: 600e-9, T1L: 600e-9, Tolerance: 150e-9, Reset: 80e-6}
+)
+
+// timingsWS2812 lists the timings selectable by name.
+var timingsWS2812 = []TimingWS2812{WS2812BTiming, SK6812Timing}
+
+var (
+	errWS2812Timing  = errors.New("ws2812: bit timing out of tolerance")
+	errWS2812Partial = errors.New("ws2812: frame ends with a partial pixel")
+)
+
+// PixelWS2812 is the color shifted into one LED.
+type PixelWS2812 struct {
+	Interval
+	R, G, B, W uint8
+	// TimingErr is set if any bit of the pixel was out of tolerance.
+	TimingErr bool
+}
+
+// RGB returns the color as 0xRRGGBB.
+func (p PixelWS2812) RGB() uint32 { return uint32(p.R)<<16 | uint32(p.G)<<8 | uint32(p.B) }
+
+// FrameWS2812 is a sequence of pixels ended by a reset latch.
+type FrameWS2812 struct {
+	Interval
+	Pixels []PixelWS2812
+	// Bits is the number of bits shifted in the frame.
+	Bits int
+	// Latched is set if the frame was followed by a reset. Frames cut off by
+	// the end of the capture are not latched.
+	Latched bool
+	Err     error
+}
+
+// WS2812 can be used to decode single wire LED strips such as WS2812B,
+// SK6812 and compatible. Pixels are shifted in GRB or GRBW order, most
+// significant bit first.
+type WS2812 struct {
+	// Timing used to classify bits. Zero value uses WS2812BTiming.
+	Timing TimingWS2812
+	// RGBW is set for LEDs with a white channel, 32 bits per pixel.
+	RGBW bool
+}
+
+func (ws *WS2812) timing() TimingWS2812 {
+	if ws.Timing == (TimingWS2812{}) {
+		return WS2812BTiming
+	}
+	return ws.Timing
+}
+
+// Scan decodes all frames found on din.
+func (ws *WS2812) Scan(din *saleae.DigitalFile) (frames []FrameWS2812, err error) {
+	if din == nil {
+		return nil, errors.New("ws2812: got nil digital file")
+	}
+	tm := ws.timing()
+	if tm.T0H <= 0 || tm.T1H <= tm.T0H || tm.Reset <= 0 {
+		return nil, errors.New("ws2812: invalid timing")
+	}
+	bitsPerPixel := 24
+	if ws.RGBW {
+		bitsPerPixel = 32
+	}
+	e := din.Data
+	if len(e) == 0 {
+		return nil, nil
+	}
+	var (
+		f       *FrameWS2812
+		pix     PixelWS2812
+		v       uint32
+		capture = math.Max(din.Header.End, e[len(e)-1])
+	)
+	for i := 0; i+1 < len(e); i++ {
+		if !levelAt(din, e[i]) {
+			continue // Not a rising edge.
+		}
+		rise, fall := e[i], e[i+1]
+		next := math.Inf(1)
+		if i+2 < len(e) {
+			next = e[i+2]
+		}
+		high, low := fall-rise, next-fall
+		one := high > (tm.T0H+tm.T1H)/2
+		wantH, wantL := tm.T0H, tm.T0L
+		if one {
+			wantH, wantL = tm.T1H, tm.T1L
+		}
+		latch := low >= tm.Reset
+		if f == nil {
+			frames = append(frames, FrameWS2812{})
+			f = &frames[len(frames)-1]
+			f.start = rise
+		}
+		nbit := f.Bits % bitsPerPixel
+		if nbit == 0 {
+			pix = PixelWS2812{}
+			pix.start = rise
+			v = 0
+		}
+		v = v<<1 | uint32(b2u8(one))
+		if math.Abs(high-wantH) > tm.Tolerance || !latch && math.Abs(low-wantL) > tm.Tolerance && !math.IsInf(low, 1) {
+			pix.TimingErr = true
+			if f.Err == nil {
+				f.Err = errWS2812Timing
+			}
+		}
+		f.Bits++
+		f.end = fall
+		if nbit == bitsPerPixel-1 {
+			pix.end = fall
+			if ws.RGBW {
+				pix.G, pix.R, pix.B, pix.W = uint8(v>>24), uint8(v>>16), uint8(v>>8), uint8(v)
+			} else {
+				pix.G, pix.R, pix.B = uint8(v>>16), uint8(v>>8), uint8(v)
+			}
+			f.Pixels = append(f.Pixels, pix)
+		}
+		if latch || math.IsInf(low, 1) {
+			f.Latched = fall+tm.Reset <= capture
+			if f.Bits%bitsPerPixel != 0 && f.Err == nil {
+				f.Err = errWS2812Partial
+			}
+			f = nil
+		}
+	}
+	return frames, nil
+}
+
+func init() {
+	Register("WS2812", func() Analyzer { return &WS2812{} })
+}
+
+// Channels implements Analyzer.
+func (*WS2812) Channels() []Channel { return []Channel{{Name: "din"}} }
+
+// Settings implements Analyzer.
+func (ws *WS2812) Settings() []Setting {
+	return []Setting{
+		{Name: "chip", Usage: "timing preset: ws2812b or sk6812", Value: ws.timing().Name},
+		{Name: "rgbw", Usage: "pixels have a white channel", Value: strconv.FormatBool(ws.RGBW)},
+	}
+}
+
+// Set implements Analyzer.
+func (ws *WS2812) Set(name, value string) (err error) {
+	switch name {
+	case "chip":
+		for _, tm := range timingsWS2812 {
+			if tm.Name == value {
+				ws.Timing = tm
+				return nil
+			}
+		}
+		err = errors.New("ws2812: unknown chip " + strconv.Quote(value))
+	case "rgbw":
+		ws.RGBW, err = strconv.ParseBool(value)
+	default:
+		err = errUnknownSetting(name)
+	}
+	return err
+}
+
+// Analyze implements Analyzer. Each frame is returned as a frame of type "frame"
+// with the "colors" of its LEDs formatted as #rrggbb, or #rrggbbww for RGBW LEDs.
+func (ws *WS2812) Analyze(channels map[string]*saleae.DigitalFile) ([]Frame, error) {
+	in, err := inputs(ws, channels)
+	if err != nil {
+		return nil, err
+	}
+	wsFrames, err := ws.Scan(in[0])
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(wsFrames))
+	for i, f := range wsFrames {
+		colors := make([]string, len(f.Pixels))
+		for j, p := range f.Pixels {
+			c := uint64(p.RGB())
+			digits := 6
+			if ws.RGBW {
+				c, digits = c<<8|uint64(p.W), 8
+			}
+			s := strconv.FormatUint(c, 16)
+			for len(s) < digits {
+				s = "0" + s
+			}
+			colors[j] = "#" + s
+		}
+		frames[i] = Frame{
+			Interval: f.Interval,
+			Type:     "frame",
+			Data:     map[string]any{"colors": colors, "latched": f.Latched},
+			Err:      f.Err,
+		}
+	}
+	return frames, nil
+}
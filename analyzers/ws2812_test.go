@@ -0,0 +1,83 @@
+package analyzers
+
+import (
+	"testing"
+
+	"github.com/soypat/saleae"
+)
+
+// ws2812Wave builds an LED data line bit by bit.
+type ws2812Wave struct {
+	tm TimingWS2812
+	t  float64
+	df saleae.DigitalFile
+}
+
+func (w *ws2812Wave) bits(v uint32, n int, skew float64) {
+	for i := n - 1; i >= 0; i-- {
+		h, l := w.tm.T0H, w.tm.T0L
+		if v>>i&1 != 0 {
+			h, l = w.tm.T1H, w.tm.T1L
+		}
+		w.df.Data = append(w.df.Data, w.t, w.t+h+skew)
+		w.t += h + l
+	}
+}
+
+func (w *ws2812Wave) reset() { w.t += w.tm.Reset * 1.5 }
+
+func TestWS2812(t *testing.T) {
+	w := ws2812Wave{tm: WS2812BTiming, t: 1e-6}
+	// GRB order: red, green, blue.
+	w.bits(0x00ff00, 24, 0)
+	w.bits(0xff0000, 24, 0)
+	w.bits(0x0000ff, 24, 0)
+	w.reset()
+	w.bits(0x102030, 24, 0)
+	w.bits(0x40, 8, 300e-9) // Partial pixel with stretched highs.
+	w.reset()
+	w.df.Header.End = w.t
+
+	var ws WS2812
+	frames, err := ws.Scan(&w.df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(frames))
+	}
+	f := frames[0]
+	if f.Err != nil || !f.Latched || len(f.Pixels) != 3 {
+		t.Fatalf("unexpected first frame %+v", f)
+	}
+	for i, want := range []uint32{0xff0000, 0x00ff00, 0x0000ff} {
+		if got := f.Pixels[i].RGB(); got != want {
+			t.Errorf("pixel %d: expected %06x, got %06x", i, want, got)
+		}
+	}
+	f = frames[1]
+	if f.Err != errWS2812Timing || f.Bits != 32 || len(f.Pixels) != 1 || f.Pixels[0].TimingErr {
+		t.Errorf("unexpected second frame %+v", f)
+	}
+	if got := f.Pixels[0].RGB(); got != 0x201030 {
+		t.Errorf("expected 201030, got %06x", got)
+	}
+}
+
+func TestWS2812RGBW(t *testing.T) {
+	w := ws2812Wave{tm: SK6812Timing, t: 1e-6}
+	w.bits(0x11223344, 32, 0)
+	w.bits(0xaabbccdd, 32, 0)
+	ws := WS2812{Timing: SK6812Timing, RGBW: true}
+	frames, err := ws.Scan(&w.df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 1 || len(frames[0].Pixels) != 2 || frames[0].Err != nil || frames[0].Latched {
+		t.Fatalf("unexpected frames %+v", frames)
+	}
+	p := frames[0].Pixels[1]
+	if p.G != 0xaa || p.R != 0xbb || p.B != 0xcc || p.W != 0xdd {
+		t.Errorf("unexpected pixel %+v", p)
+	}
+}
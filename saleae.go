@@ -276,6 +276,58 @@ type Capture struct {
 	CaptureStart time.Time
 	AnalogFiles  []AnalogFile
 	DigitalFiles []DigitalFile
+	// DigitalChannels contains the device channel index of each of the DigitalFiles.
+	DigitalChannels []int
+	// Analyzers contains the protocol analyzers configured in the Logic 2 software for the capture.
+	Analyzers []AnalyzerConfig
+}
+
+// Digital returns the digital file of the device channel with index channel or
+// nil if the capture has no such channel.
+func (c *Capture) Digital(channel int) *DigitalFile {
+	for i, ch := range c.DigitalChannels {
+		if ch == channel && i < len(c.DigitalFiles) {
+			return &c.DigitalFiles[i]
+		}
+	}
+	return nil
+}
+
+// AnalyzerConfig is a protocol analyzer configured in the Logic 2 software.
+type AnalyzerConfig struct {
+	// Name given to the analyzer in the Logic 2 software.
+	Name string
+	// Type of analyzer, i.e: "SPI", "I2C", "Async Serial".
+	Type     string
+	Settings []AnalyzerSetting
+}
+
+// Setting returns the setting with the given title.
+func (ac *AnalyzerConfig) Setting(title string) (AnalyzerSetting, bool) {
+	for _, setting := range ac.Settings {
+		if setting.Title == title {
+			return setting, true
+		}
+	}
+	return AnalyzerSetting{}, false
+}
+
+// AnalyzerSetting is a setting of a protocol analyzer configured in the Logic 2 software.
+type AnalyzerSetting struct {
+	Title string
+	// Type of setting, i.e: "Channel", "NumberList".
+	Type     string
+	Disabled bool
+	// Value of the setting as decoded from JSON. Numbers are float64.
+	// For channel settings it is the device channel index.
+	Value any
+}
+
+// Int returns the value of the setting as an integer. ok is false if
+// the value is not a number.
+func (as AnalyzerSetting) Int() (v int, ok bool) {
+	f, ok := as.Value.(float64)
+	return int(f), ok
 }
 
 // ReadCaptureFile reads a capture from a file in .sal format.
@@ -299,24 +351,9 @@ func ReadCapture(r io.ReaderAt, size int64) (*Capture, error) {
 	if err != nil {
 		return nil, err
 	}
-	var metadata metadataV15
-	for _, f := range zr.File {
-		if f.Name != "meta.json" {
-			continue
-		}
-		rc, err := f.Open()
-		if err != nil {
-			return nil, err
-		}
-		err = json.NewDecoder(rc).Decode(&metadata)
-		rc.Close()
-		if err != nil {
-			return nil, err
-		}
-		break
-	}
-	if metadata.Version == 0 {
-		return nil, errors.New("metadata.json not found or invalid version")
+	metadata, err := readMetadata(zr)
+	if err != nil {
+		return nil, err
 	}
 
 	var capture Capture
@@ -346,11 +383,55 @@ func ReadCapture(r io.ReaderAt, size int64) (*Capture, error) {
 				return nil, fmt.Errorf("reading digital file %q: %w", filename, err)
 			}
 			capture.DigitalFiles = append(capture.DigitalFiles, *df)
+			capture.DigitalChannels = append(capture.DigitalChannels, bindata.Index)
 		}
 	}
+	capture.Analyzers = metadata.analyzers()
 	return &capture, nil
 }
 
+// readMetadata decodes the meta.json file of a .sal capture.
+func readMetadata(zr *zip.Reader) (*metadataV15, error) {
+	var metadata metadataV15
+	for _, f := range zr.File {
+		if f.Name != "meta.json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		err = json.NewDecoder(rc).Decode(&metadata)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	if metadata.Version == 0 {
+		return nil, errors.New("metadata.json not found or invalid version")
+	}
+	return &metadata, nil
+}
+
+// analyzers returns the protocol analyzers configured in the metadata.
+func (md *metadataV15) analyzers() []AnalyzerConfig {
+	var configs []AnalyzerConfig
+	for _, analyzer := range md.Data.Analyzers {
+		config := AnalyzerConfig{Name: analyzer.Name, Type: analyzer.Type}
+		for _, setting := range analyzer.Settings {
+			config.Settings = append(config.Settings, AnalyzerSetting{
+				Title:    setting.Title,
+				Type:     setting.Setting.Type,
+				Disabled: setting.Disabled,
+				Value:    setting.Setting.Value,
+			})
+		}
+		configs = append(configs, config)
+	}
+	return configs
+}
+
 type metadataV15 struct {
 	Version int `json:"version"` // Always 15 for this case.
 	Data    struct {
@@ -390,7 +471,7 @@ type metadataV15 struct {
 				Setting  struct {
 					Type            string `json:"type"`
 					ChannelRequired bool   `json:"channelRequired"`
-					Value           int    `json:"value"`
+					Value           any    `json:"value"`
 				} `json:"setting,omitempty"`
 			} `json:"settings"`
 			ShowInDataTable  bool   `json:"showInDataTable"`
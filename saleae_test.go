@@ -0,0 +1,40 @@
+package saleae
+
+import (
+	"archive/zip"
+	"testing"
+)
+
+func TestReadMetadata(t *testing.T) {
+	zr, err := zip.OpenReader("testdata/sx1278_pico.sal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	metadata, err := readMetadata(&zr.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Version != 15 || len(metadata.BinData) != 6 || metadata.BinData[3].Index != 3 {
+		t.Fatalf("unexpected metadata version %d or binary data %+v", metadata.Version, metadata.BinData)
+	}
+	analyzers := metadata.analyzers()
+	if len(analyzers) != 1 || analyzers[0].Type != "SPI" || analyzers[0].Name != "SPI" {
+		t.Fatalf("expected a single SPI analyzer, got %+v", analyzers)
+	}
+	spi := &analyzers[0]
+	for title, want := range map[string]int{"MOSI": 1, "MISO": 0, "Clock": 2, "Enable": 3, "Bits per Transfer": 8} {
+		setting, ok := spi.Setting(title)
+		if !ok {
+			t.Errorf("setting %q not found", title)
+			continue
+		}
+		got, ok := setting.Int()
+		if !ok || got != want {
+			t.Errorf("setting %q: got %v, want %d", title, setting.Value, want)
+		}
+	}
+	if setting, _ := spi.Setting("MOSI"); setting.Type != "Channel" || setting.Disabled {
+		t.Errorf("unexpected MOSI setting %+v", setting)
+	}
+}